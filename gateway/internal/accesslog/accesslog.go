@@ -0,0 +1,157 @@
+package accesslog
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/requestid"
+
+	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/tracing"
+)
+
+// noUpstream — значение label upstream для запросов, не дошедших до upstream
+// (отказ auth, rate limit, разомкнутая цепь).
+const noUpstream = "none"
+
+// Record — сведения о запросе, которые становятся известны глубже по цепочке
+// обработчиков (после auth и балансировщика). Заполняются через SetUser и SetUpstream.
+type Record struct {
+	mu       sync.Mutex
+	userID   string
+	upstream string
+	variant  string
+}
+
+type ctxKey struct{}
+
+// SetUser запоминает ID пользователя, прошедшего проверку токена.
+func SetUser(ctx context.Context, userID string) {
+	if rec, ok := ctx.Value(ctxKey{}).(*Record); ok {
+		rec.mu.Lock()
+		rec.userID = userID
+		rec.mu.Unlock()
+	}
+}
+
+// SetUpstream запоминает экземпляр upstream, обработавший запрос.
+// При повторах остаётся последний.
+func SetUpstream(ctx context.Context, upstream string) {
+	if rec, ok := ctx.Value(ctxKey{}).(*Record); ok {
+		rec.mu.Lock()
+		rec.upstream = upstream
+		rec.mu.Unlock()
+	}
+}
+
+// SetVariant запоминает вариант canary, выбранный для запроса.
+func SetVariant(ctx context.Context, variant string) {
+	if rec, ok := ctx.Value(ctxKey{}).(*Record); ok {
+		rec.mu.Lock()
+		rec.variant = variant
+		rec.mu.Unlock()
+	}
+}
+
+// Middleware пишет одну строку access-лога на запрос и обновляет метрики маршрута.
+func Middleware(route string, logger *log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			inFlight := metrics.RequestsInFlight.WithLabelValues(route)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			rec := &Record{}
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), ctxKey{}, rec)))
+
+			elapsed := time.Since(start)
+			status := sw.Status()
+
+			rec.mu.Lock()
+			userID, upstream, variant := rec.userID, rec.upstream, rec.variant
+			rec.mu.Unlock()
+			if upstream == "" {
+				upstream = noUpstream
+			}
+
+			class := statusClass(status)
+			metrics.Requests.WithLabelValues(route, upstream, class).Inc()
+			metrics.RequestDuration.WithLabelValues(route, upstream, class).Observe(elapsed.Seconds())
+			if variant != "" {
+				metrics.VariantRequests.WithLabelValues(route, variant, class).Inc()
+			}
+
+			fields := map[string]any{
+				"request_id":  requestid.FromContext(r.Context()),
+				"route":       route,
+				"method":      r.Method,
+				"path":        r.URL.Path,
+				"status":      status,
+				"upstream":    upstream,
+				"duration_ms": elapsed.Milliseconds(),
+				"bytes_in":    max(r.ContentLength, 0),
+				"bytes_out":   sw.bytes,
+				"remote_addr": r.RemoteAddr,
+			}
+			if userID != "" {
+				fields["user_id"] = userID
+			}
+			if variant != "" {
+				fields["variant"] = variant
+			}
+			if traceID := tracing.TraceID(r.Context()); traceID != "" {
+				fields["trace_id"] = traceID
+			}
+			logger.WithFields(fields).Info("%s %s %d", r.Method, r.URL.Path, status)
+		})
+	}
+}
+
+// statusClass сворачивает код ответа в класс (2xx, 4xx, ...) для label метрик.
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// statusWriter запоминает код ответа и число записанных байт.
+// Unwrap позволяет http.ResponseController (и ReverseProxy) добраться
+// до Flush и Hijack исходного ResponseWriter.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	// 1xx кроме 101 — промежуточные ответы (103 Early Hints), итоговый код будет позже
+	if w.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status возвращает код ответа; 200, если обработчик ничего не записал.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
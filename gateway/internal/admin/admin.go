@@ -0,0 +1,240 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/cache"
+	"vira-gateway/internal/canary"
+	"vira-gateway/internal/maintenance"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/upstream"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Deps — состояние gateway, которое показывает и меняет admin API.
+type Deps struct {
+	Table       func() *routes.Table // Действующая таблица маршрутов
+	Pools       *upstream.Registry   // Пулы upstream-ов
+	Cache       *cache.Cache         // Кэш ответов
+	Canary      *canary.Weights      // Веса canary
+	Maintenance *maintenance.Switch  // Режим обслуживания маршрутов
+}
+
+// PurgeRequest — тело запроса на сброс кэша. Задаётся одно из полей.
+type PurgeRequest struct {
+	Prefix string `json:"prefix"` // Публичный путь, например /api/dev/courses
+	Route  string `json:"route"`  // Имя маршрута: сбросить весь его кэш
+}
+
+// PurgeResponse — результат сброса кэша.
+type PurgeResponse struct {
+	Purged int `json:"purged"` // Удалено ключей
+}
+
+// WeightsRequest — тело запроса на изменение весов canary.
+type WeightsRequest struct {
+	Weights map[string]int `json:"weights"` // Вес варианта, 0–100
+}
+
+// InstanceRequest — экземпляр upstream-а для смены режима.
+type InstanceRequest struct {
+	Pool string `json:"pool"` // Имя пула: маршрут или маршрут/вариант canary
+	URL  string `json:"url"`  // Адрес экземпляра, как в GET /admin/upstreams
+}
+
+// RouteStatus — маршрут со значениями по умолчанию и режимом обслуживания.
+type RouteStatus struct {
+	routes.Route
+	Maintenance *maintenance.Mode `json:"maintenance,omitempty"`
+}
+
+// RoutesResponse — действующая таблица маршрутов.
+type RoutesResponse struct {
+	Routes       []RouteStatus        `json:"routes"`
+	Compositions []routes.Composition `json:"compositions"`
+}
+
+// Handler — служебный API gateway. Слушает отдельный порт,
+// который не публикуется наружу.
+func Handler(d Deps) http.Handler {
+	r := chi.NewRouter()
+
+	// Действующие маршруты со значениями по умолчанию
+	r.Get("/admin/routes", func(w http.ResponseWriter, r *http.Request) {
+		table := d.Table().WithDefaults()
+		resp := RoutesResponse{Compositions: table.Compositions}
+		for _, route := range table.Routes {
+			st := RouteStatus{Route: route}
+			if m, ok := d.Maintenance.Get(route.Name); ok {
+				st.Maintenance = &m
+			}
+			resp.Routes = append(resp.Routes, st)
+		}
+		writeJSON(w, resp)
+	})
+
+	// Состояние пулов upstream-ов: здоровье, выбросы, нагрузка, цепь
+	r.Get("/admin/upstreams", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, d.Pools.Status())
+	})
+
+	// Режим экземпляра: draining — дождаться текущих запросов,
+	// disabled — вывести из пула, active — вернуть
+	modes := map[string]upstream.Mode{
+		"drain":   upstream.ModeDraining,
+		"disable": upstream.ModeDisabled,
+		"enable":  upstream.ModeActive,
+	}
+	for action, mode := range modes {
+		r.Post("/admin/upstreams/"+action, func(w http.ResponseWriter, r *http.Request) {
+			var req InstanceRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Pool == "" || req.URL == "" {
+				apierror.Write(w, http.StatusBadRequest, "нужны pool и url экземпляра")
+				return
+			}
+
+			err := d.Pools.SetMode(r.Context(), req.Pool, req.URL, mode)
+			switch {
+			case errors.Is(err, upstream.ErrUnknownInstance):
+				apierror.Write(w, http.StatusNotFound, err.Error())
+			case err != nil:
+				apierror.Write(w, http.StatusInternalServerError, err.Error())
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		})
+	}
+
+	// Маршруты в режиме обслуживания
+	r.Get("/admin/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, d.Maintenance.Status())
+	})
+
+	// Включение режима обслуживания: маршрут отвечает 503 с заданным телом
+	r.Put("/admin/maintenance/{route}", func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "route")
+		if _, ok := findRoute(d.Table(), name); !ok {
+			apierror.Write(w, http.StatusNotFound, "маршрут не найден")
+			return
+		}
+
+		var m maintenance.Mode
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+				apierror.Write(w, http.StatusBadRequest, "некорректное тело запроса")
+				return
+			}
+		}
+		if m.RetryAfter < 0 {
+			apierror.Write(w, http.StatusBadRequest, "retry_after не может быть отрицательным")
+			return
+		}
+
+		if err := d.Maintenance.Enable(r.Context(), name, m); err != nil {
+			apierror.Write(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	r.Delete("/admin/maintenance/{route}", func(w http.ResponseWriter, r *http.Request) {
+		if err := d.Maintenance.Disable(r.Context(), chi.URLParam(r, "route")); err != nil {
+			apierror.Write(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Сброс кэша ответов по префиксу пути или маршруту
+	r.Post("/admin/cache/purge", func(w http.ResponseWriter, r *http.Request) {
+		var req PurgeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apierror.Write(w, http.StatusBadRequest, "некорректное тело запроса")
+			return
+		}
+		if req.Route != "" {
+			if req.Prefix != "" {
+				apierror.Write(w, http.StatusBadRequest, "задаётся prefix или route, не оба")
+				return
+			}
+			route, ok := findRoute(d.Table(), req.Route)
+			if !ok {
+				apierror.Write(w, http.StatusNotFound, "маршрут не найден")
+				return
+			}
+			req.Prefix = route.Prefix
+		}
+		if !strings.HasPrefix(req.Prefix, "/") {
+			apierror.Write(w, http.StatusBadRequest, "prefix должен начинаться с /")
+			return
+		}
+
+		n, err := d.Cache.Purge(r.Context(), req.Prefix)
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, PurgeResponse{Purged: n})
+	})
+
+	// Веса вариантов canary по маршрутам
+	r.Get("/admin/canary", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, d.Canary.Status())
+	})
+
+	// Изменение весов canary маршрута (действует на всех репликах)
+	r.Put("/admin/canary/{route}", func(w http.ResponseWriter, r *http.Request) {
+		var req WeightsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Weights) == 0 {
+			apierror.Write(w, http.StatusBadRequest, "некорректное тело запроса")
+			return
+		}
+
+		if err := d.Canary.Set(r.Context(), chi.URLParam(r, "route"), req.Weights); err != nil {
+			writeCanaryError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Возврат весов из таблицы маршрутов
+	r.Delete("/admin/canary/{route}", func(w http.ResponseWriter, r *http.Request) {
+		if err := d.Canary.Reset(r.Context(), chi.URLParam(r, "route")); err != nil {
+			writeCanaryError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return r
+}
+
+func findRoute(table *routes.Table, name string) (routes.Route, bool) {
+	for _, r := range table.Routes {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return routes.Route{}, false
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeCanaryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, canary.ErrUnknownRoute):
+		apierror.Write(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, canary.ErrInvalidWeights):
+		apierror.Write(w, http.StatusBadRequest, err.Error())
+	default:
+		apierror.Write(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"vira-gateway/internal/apierror"
+
+	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/server"
+)
+
+// ErrUnprotected возвращается, если для admin API не задан ни токен, ни mTLS.
+var ErrUnprotected = errors.New("не задан GATEWAY_ADMIN_TOKEN или GATEWAY_ADMIN_CLIENT_CA")
+
+// Config — настройки admin API.
+type Config struct {
+	Addr  string // GATEWAY_ADMIN_ADDR (по умолчанию :9901)
+	Token string // GATEWAY_ADMIN_TOKEN: запросы должны нести Authorization: Bearer <токен>
+
+	TLSCert  string // GATEWAY_ADMIN_TLS_CERT: сертификат сервера (PEM)
+	TLSKey   string // GATEWAY_ADMIN_TLS_KEY: ключ сертификата (PEM)
+	ClientCA string // GATEWAY_ADMIN_CLIENT_CA: CA клиентских сертификатов (mTLS)
+}
+
+// FromEnv собирает настройки admin API из переменных окружения.
+// Без токена и без mTLS возвращает ErrUnprotected: открытый admin API
+// позволил бы любому в сети отключать маршруты.
+func FromEnv() (Config, error) {
+	cfg := Config{
+		Addr:     os.Getenv("GATEWAY_ADMIN_ADDR"),
+		Token:    os.Getenv("GATEWAY_ADMIN_TOKEN"),
+		TLSCert:  os.Getenv("GATEWAY_ADMIN_TLS_CERT"),
+		TLSKey:   os.Getenv("GATEWAY_ADMIN_TLS_KEY"),
+		ClientCA: os.Getenv("GATEWAY_ADMIN_CLIENT_CA"),
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":9901"
+	}
+
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return cfg, errors.New("GATEWAY_ADMIN_TLS_CERT и GATEWAY_ADMIN_TLS_KEY задаются вместе")
+	}
+	if cfg.ClientCA != "" && cfg.TLSCert == "" {
+		return cfg, errors.New("GATEWAY_ADMIN_CLIENT_CA требует GATEWAY_ADMIN_TLS_CERT и GATEWAY_ADMIN_TLS_KEY")
+	}
+	if cfg.Token == "" && cfg.ClientCA == "" {
+		return cfg, ErrUnprotected
+	}
+	return cfg, nil
+}
+
+// NewServer создаёт HTTP сервер admin API. Токен проверяется на каждом
+// запросе, клиентский сертификат — при TLS рукопожатии. Если заданы оба,
+// нужны оба.
+func NewServer(cfg Config, h http.Handler, timeouts server.Config, logger *log.Logger) (*http.Server, error) {
+	if cfg.Token != "" {
+		h = requireToken(cfg.Token, h, logger)
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           h,
+		ReadHeaderTimeout: timeouts.ReadHeaderTimeout,
+		ReadTimeout:       timeouts.ReadTimeout,
+		WriteTimeout:      timeouts.WriteTimeout,
+	}
+	if cfg.TLSCert == "" {
+		return srv, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки сертификата admin API: %w", err)
+	}
+	srv.TLSConfig = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if cfg.ClientCA != "" {
+		pem, err := os.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения CA клиентов admin API: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("в %s нет сертификатов", cfg.ClientCA)
+		}
+		srv.TLSConfig.ClientCAs = pool
+		srv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return srv, nil
+}
+
+// ListenAndServe запускает сервер, созданный NewServer (по TLS, если он настроен).
+func ListenAndServe(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
+
+// requireToken пропускает только запросы с Authorization: Bearer <token>.
+// Токены сравниваются по хэшу за постоянное время.
+func requireToken(token string, next http.Handler, logger *log.Logger) http.Handler {
+	want := sha256.Sum256([]byte(token))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		sum := sha256.Sum256([]byte(got))
+		if !ok || subtle.ConstantTimeCompare(sum[:], want[:]) != 1 {
+			logger.Warn("⛔ Запрос к admin API без верного токена: %s %s от %s", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="vira-gateway-admin"`)
+			apierror.Write(w, http.StatusUnauthorized, "нужен токен admin API")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,20 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Response — единый формат JSON-ошибки, которую gateway отдаёт сам,
+// не доходя до upstream.
+type Response struct {
+	Error string `json:"error"` // Текст ошибки
+}
+
+// Write пишет JSON-ошибку с заданным статусом.
+func Write(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Error: message})
+}
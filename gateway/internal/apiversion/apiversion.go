@@ -0,0 +1,62 @@
+package apiversion
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/respwriter"
+	"vira-gateway/internal/routes"
+)
+
+// unversioned — значение label version для маршрутов без версии.
+const unversioned = "unversioned"
+
+// Middleware считает запросы к версии API и ставит заголовки вывода
+// из эксплуатации: Deprecation, Sunset и Link. Значения upstream заменяются.
+// Если задан enforce_sunset, после даты Sunset маршрут отвечает 410 Gone.
+func Middleware(route routes.Route) func(http.Handler) http.Handler {
+	version := route.Version
+	if version == "" {
+		version = unversioned
+	}
+	d := route.Deprecation
+	deprecated := strconv.FormatBool(d.Deprecated())
+
+	headers := map[string]string{}
+	if !d.Since.IsZero() {
+		headers["Deprecation"] = fmt.Sprintf("@%d", d.Since.Unix())
+	}
+	if !d.Sunset.IsZero() {
+		headers["Sunset"] = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+	if d.Link != "" && d.Deprecated() {
+		headers["Link"] = fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, d.Link)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			metrics.VersionRequests.WithLabelValues(route.Name, version, deprecated).Inc()
+
+			if len(headers) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w = respwriter.OnHeader(w, func(h http.Header) {
+				for name, value := range headers {
+					h.Set(name, value)
+				}
+			})
+
+			if d.EnforceSunset && time.Now().After(d.Sunset.Time) {
+				apierror.Write(w, http.StatusGone, "версия API отключена")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"vira-gateway/internal/accesslog"
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/stream"
+
+	jwt "github.com/skrolikov/vira-jwt"
+	log "github.com/skrolikov/vira-logger"
+)
+
+// Заголовки, которыми gateway передаёт проверенную личность в upstream.
+// Входящие значения этих заголовков от клиента всегда удаляются.
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserRole  = "X-User-Role"
+	HeaderSessionID = "X-Session-ID"
+
+	// HeaderIdentity — подписанная gateway личность (см. пакет identity).
+	// По ней сервис проверяет, что заголовки выше выставил gateway.
+	HeaderIdentity = "X-Vira-Identity"
+)
+
+// IdentityHeaders — все заголовки личности, которые нельзя принимать от клиента.
+var IdentityHeaders = []string{HeaderUserID, HeaderUserRole, HeaderSessionID, HeaderIdentity}
+
+// QueryToken — параметр запроса с access токеном для WebSocket и SSE:
+// браузерные WebSocket и EventSource не умеют ставить заголовок Authorization.
+// В upstream параметр не передаётся.
+const QueryToken = "access_token"
+
+// ErrNoToken возвращается, если в запросе нет Bearer токена.
+var ErrNoToken = errors.New("отсутствует токен")
+
+// ErrInvalidToken возвращается для подделанного, просроченного
+// или не access токена.
+var ErrInvalidToken = errors.New("неверный токен")
+
+// Identity — проверенные данные пользователя из access токена.
+type Identity struct {
+	UserID    string
+	Role      string
+	SessionID string
+}
+
+type ctxKey struct{}
+
+// FromContext достаёт личность, проверенную middleware.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(ctxKey{}).(Identity)
+	return id, ok
+}
+
+// WithIdentity кладёт личность в контекст.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// SetHeaders выставляет заголовки личности для upstream.
+func SetHeaders(h http.Header, id Identity) {
+	h.Set(HeaderUserID, id.UserID)
+	if id.Role != "" {
+		h.Set(HeaderUserRole, id.Role)
+	}
+	if id.SessionID != "" {
+		h.Set(HeaderSessionID, id.SessionID)
+	}
+}
+
+// StripHeaders удаляет заголовки личности, пришедшие от клиента.
+func StripHeaders(h http.Header) {
+	for _, name := range IdentityHeaders {
+		h.Del(name)
+	}
+}
+
+// Verify проверяет Bearer access токен из заголовка Authorization,
+// а для WebSocket и SSE — ещё и из параметра access_token.
+func Verify(r *http.Request, secret string) (Identity, error) {
+	token := bearer(r)
+	if token == "" {
+		return Identity{}, ErrNoToken
+	}
+
+	claims, err := jwt.ParseToken(token, secret)
+	if err != nil || claims == nil || !jwt.IsTokenType(claims, "access") {
+		return Identity{}, ErrInvalidToken
+	}
+
+	userID, _ := claims["user_id"].(string)
+	if userID == "" {
+		return Identity{}, ErrInvalidToken
+	}
+
+	role, _ := claims["role"].(string)
+	sessionID, _ := claims["sid"].(string)
+
+	return Identity{UserID: userID, Role: role, SessionID: sessionID}, nil
+}
+
+func bearer(r *http.Request) string {
+	if token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); found {
+		return strings.TrimSpace(token)
+	}
+	if stream.Detect(r) != stream.None {
+		return r.URL.Query().Get(QueryToken)
+	}
+	return ""
+}
+
+// stripQueryToken убирает токен из адреса, чтобы он не попал в логи upstream.
+func stripQueryToken(r *http.Request) *http.Request {
+	if stream.Detect(r) == stream.None {
+		return r
+	}
+	q := r.URL.Query()
+	if !q.Has(QueryToken) {
+		return r
+	}
+	q.Del(QueryToken)
+
+	r = r.WithContext(r.Context())
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	r.URL = &u
+	return r
+}
+
+// Middleware удаляет поддельные заголовки личности и проверяет access токен.
+// На protected маршрутах без валидного токена отвечает 401 с единым JSON-телом.
+// На public маршрутах валидный токен тоже разбирается, а невалидный игнорируется.
+func Middleware(secret string, mode routes.AuthMode, logger *log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			StripHeaders(r.Header)
+
+			id, err := Verify(r, secret)
+			r = stripQueryToken(r)
+			if err != nil {
+				if mode == routes.AuthProtected {
+					logger.WithContext(r.Context()).WithFields(map[string]any{
+						"path":   r.URL.Path,
+						"method": r.Method,
+					}).Warn("Auth: запрос отклонён: %v", err)
+					apierror.Write(w, http.StatusUnauthorized, "unauthorized")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			accesslog.SetUser(r.Context(), id.UserID)
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen возвращается, когда цепь разомкнута и запрос не отправляется.
+var ErrOpen = errors.New("цепь разомкнута: upstream временно исключён")
+
+// State — состояние автомата.
+type State int
+
+const (
+	// Closed — запросы идут, ошибки считаются.
+	Closed State = iota
+
+	// HalfOpen — пропускается ограниченное число пробных запросов.
+	HalfOpen
+
+	// Open — запросы сразу отклоняются до истечения OpenFor.
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	}
+	return "unknown"
+}
+
+// MarshalText сериализует состояние строкой (для admin API).
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Outcome — итог запроса, пропущенного автоматом.
+type Outcome int
+
+const (
+	// Success — upstream ответил без ошибки.
+	Success Outcome = iota
+
+	// Failure — ошибка соединения, таймаут или 5xx.
+	Failure
+
+	// Ignored — исход не говорит о здоровье upstream (например, клиент отменил запрос).
+	Ignored
+)
+
+// Config — параметры автомата.
+type Config struct {
+	FailureThreshold int           // Ошибок подряд до размыкания
+	OpenFor          time.Duration // Сколько держать цепь разомкнутой
+	HalfOpenRequests int           // Сколько пробных запросов пропускать в half-open
+}
+
+// Breaker — circuit breaker closed → open → half-open → closed.
+type Breaker struct {
+	cfg      Config
+	onChange func(from, to State)
+
+	mu       sync.Mutex
+	state    State
+	gen      uint64 // Растёт при каждой смене состояния
+	failures int
+	openedAt time.Time
+	probes   int // Пробные запросы в обработке (half-open)
+}
+
+// Permit — разрешение на запрос, выданное Allow. Запоминает, в каком
+// состоянии автомата запрос был пропущен и был ли он пробным.
+type Permit struct {
+	gen   uint64
+	probe bool
+}
+
+// New создаёт автомат в состоянии Closed.
+// onChange (может быть nil) вызывается при каждой смене состояния.
+func New(cfg Config, onChange func(from, to State)) *Breaker {
+	return &Breaker{cfg: cfg, onChange: onChange}
+}
+
+// Allow сообщает, можно ли отправить запрос. Каждый разрешённый запрос
+// должен завершиться вызовом Done с полученным Permit.
+func (b *Breaker) Allow() (Permit, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if time.Since(b.openedAt) < b.cfg.OpenFor {
+			return Permit{}, false
+		}
+		b.setState(HalfOpen)
+		fallthrough
+	case HalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return Permit{}, false
+		}
+		b.probes++
+		return Permit{gen: b.gen, probe: true}, true
+	}
+	return Permit{gen: b.gen}, true
+}
+
+// Done учитывает исход запроса, разрешённого Allow. Исход запроса,
+// пропущенного до последней смены состояния, не учитывается: ответ,
+// начатый ещё в closed, ничего не говорит о пробе в half-open.
+func (b *Breaker) Done(p Permit, outcome Outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if p.gen != b.gen {
+		return
+	}
+	if p.probe {
+		b.probes--
+	}
+
+	switch outcome {
+	case Success:
+		b.failures = 0
+		if b.state == HalfOpen {
+			b.setState(Closed)
+		}
+	case Failure:
+		b.failures++
+		if b.state == HalfOpen || (b.state == Closed && b.failures >= b.cfg.FailureThreshold) {
+			b.openedAt = time.Now()
+			b.setState(Open)
+		}
+	}
+}
+
+// State возвращает текущее состояние.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) setState(to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.gen++
+	b.probes = 0
+	if to == Closed {
+		b.failures = 0
+	}
+	if b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
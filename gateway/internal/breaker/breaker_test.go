@@ -0,0 +1,175 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+// allow вызывает Allow и падает, если запрос не пропущен.
+func allow(t *testing.T, b *Breaker) Permit {
+	t.Helper()
+	p, ok := b.Allow()
+	if !ok {
+		t.Fatalf("Allow() = false в состоянии %s, want true", b.State())
+	}
+	return p
+}
+
+// trip размыкает цепь и сразу истекает OpenFor.
+func trip(t *testing.T, b *Breaker) {
+	t.Helper()
+	for range b.cfg.FailureThreshold {
+		b.Done(allow(t, b), Failure)
+	}
+	if b.State() != Open {
+		t.Fatalf("после %d ошибок состояние %s, want open", b.cfg.FailureThreshold, b.State())
+	}
+	b.mu.Lock()
+	b.openedAt = time.Now().Add(-b.cfg.OpenFor)
+	b.mu.Unlock()
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, b *Breaker)
+		want State
+		// Пропустит ли автомат следующий запрос
+		allowNext bool
+	}{
+		{
+			name: "ошибки ниже порога",
+			run: func(t *testing.T, b *Breaker) {
+				b.Done(allow(t, b), Failure)
+				b.Done(allow(t, b), Failure)
+			},
+			want:      Closed,
+			allowNext: true,
+		},
+		{
+			name: "успех сбрасывает счёт ошибок",
+			run: func(t *testing.T, b *Breaker) {
+				b.Done(allow(t, b), Failure)
+				b.Done(allow(t, b), Failure)
+				b.Done(allow(t, b), Success)
+				b.Done(allow(t, b), Failure)
+			},
+			want:      Closed,
+			allowNext: true,
+		},
+		{
+			name: "порог размыкает цепь",
+			run: func(t *testing.T, b *Breaker) {
+				for range 3 {
+					b.Done(allow(t, b), Failure)
+				}
+			},
+			want: Open,
+		},
+		{
+			name: "после OpenFor пропускается одна проба",
+			run: func(t *testing.T, b *Breaker) {
+				trip(t, b)
+				allow(t, b)
+			},
+			want: HalfOpen,
+		},
+		{
+			name: "успешная проба замыкает цепь",
+			run: func(t *testing.T, b *Breaker) {
+				trip(t, b)
+				b.Done(allow(t, b), Success)
+			},
+			want:      Closed,
+			allowNext: true,
+		},
+		{
+			name: "неудачная проба размыкает цепь",
+			run: func(t *testing.T, b *Breaker) {
+				trip(t, b)
+				b.Done(allow(t, b), Failure)
+			},
+			want: Open,
+		},
+		{
+			name: "отменённая проба освобождает место",
+			run: func(t *testing.T, b *Breaker) {
+				trip(t, b)
+				b.Done(allow(t, b), Ignored)
+			},
+			want:      HalfOpen,
+			allowNext: true,
+		},
+		{
+			name: "запрос из closed не освобождает место пробы",
+			run: func(t *testing.T, b *Breaker) {
+				early := allow(t, b)
+				trip(t, b)
+				allow(t, b)
+				b.Done(early, Ignored)
+			},
+			want: HalfOpen,
+		},
+		{
+			name: "успех запроса из closed не замыкает цепь",
+			run: func(t *testing.T, b *Breaker) {
+				early := allow(t, b)
+				trip(t, b)
+				allow(t, b)
+				b.Done(early, Success)
+			},
+			want: HalfOpen,
+		},
+		{
+			name: "ошибка запроса из closed не размыкает цепь заново",
+			run: func(t *testing.T, b *Breaker) {
+				early := allow(t, b)
+				trip(t, b)
+				b.Done(allow(t, b), Success)
+				b.Done(early, Failure)
+				b.Done(allow(t, b), Failure)
+			},
+			want:      Closed,
+			allowNext: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(Config{FailureThreshold: 3, OpenFor: time.Minute, HalfOpenRequests: 1}, nil)
+			tt.run(t, b)
+
+			if got := b.State(); got != tt.want {
+				t.Fatalf("State() = %s, want %s", got, tt.want)
+			}
+			if _, ok := b.Allow(); ok != tt.allowNext {
+				t.Fatalf("следующий Allow() = %t, want %t", ok, tt.allowNext)
+			}
+		})
+	}
+}
+
+func TestBreakerOnChange(t *testing.T) {
+	var changes []State
+	b := New(Config{FailureThreshold: 1, OpenFor: time.Minute, HalfOpenRequests: 2}, func(from, to State) {
+		changes = append(changes, to)
+	})
+
+	trip(t, b)
+	first := allow(t, b)
+	second := allow(t, b)
+	if _, ok := b.Allow(); ok {
+		t.Fatal("третья проба пропущена при HalfOpenRequests = 2")
+	}
+	b.Done(first, Success)
+	b.Done(second, Failure)
+
+	want := []State{Open, HalfOpen, Closed}
+	if len(changes) != len(want) {
+		t.Fatalf("смены состояния = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("смены состояния = %v, want %v", changes, want)
+		}
+	}
+}
@@ -0,0 +1,495 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/canary"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/stream"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+// HeaderStatus — заголовок ответа с результатом обращения к кэшу (HIT или MISS).
+const HeaderStatus = "X-Cache"
+
+// keyPrefix — префикс ключей кэша в Redis. За ним идёт публичный путь запроса,
+// поэтому кэш можно сбросить по префиксу пути через Purge.
+const keyPrefix = "gwcache:"
+
+// purgeBatch — сколько ключей удаляется за одну команду при сбросе.
+const purgeBatch = 500
+
+// Заголовки, которые не сохраняются в кэше: относятся к соединению
+// или к конкретному запросу, а не к ресурсу.
+var skipHeaders = []string{
+	"Connection", "Keep-Alive", "Transfer-Encoding", "Trailer", "Upgrade",
+	"Set-Cookie", "Age", HeaderStatus, "X-Request-Id",
+}
+
+// Заголовки, которые остаются в ответе 304 Not Modified.
+var notModifiedHeaders = []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Vary", "Last-Modified"}
+
+// entry — сохранённый ответ.
+type entry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	Stored time.Time   `json:"stored"`
+}
+
+// meta — как выбирать вариант ответа для адреса: по каким заголовкам
+// запроса (Vary) и отдельно ли для каждого пользователя.
+type meta struct {
+	Vary    []string `json:"vary,omitempty"`
+	Private bool     `json:"private,omitempty"`
+}
+
+// Cache — кэш HTTP ответов в Redis, общий для всех реплик gateway.
+type Cache struct {
+	rdb    *redis.Client
+	logger *log.Logger
+}
+
+// New создаёт Cache.
+func New(rdb *redis.Client, logger *log.Logger) *Cache {
+	return &Cache{rdb: rdb, logger: logger}
+}
+
+// Middleware кэширует GET ответы маршрута.
+//
+// Запрос с Cache-Control: no-store идёт мимо кэша, с no-cache — обновляет его.
+// Условные запросы (If-None-Match, If-Modified-Since) gateway проверяет сам
+// и отвечает 304, а в upstream за свежей копией идёт без условий.
+// Если Redis недоступен, запрос проксируется как обычно.
+func (c *Cache) Middleware(route string, cfg routes.Cache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.Enabled {
+			return next
+		}
+		cfg := cfg.WithDefaults()
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if (r.Method != http.MethodGet && r.Method != http.MethodHead) || stream.Detect(r) != stream.None {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			reqCC := parseControl(r.Header)
+			if reqCC.has("no-store") {
+				metrics.CacheRequests.WithLabelValues(route, "bypass").Inc()
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var userID string
+			if id, ok := auth.FromContext(r.Context()); ok {
+				userID = id.UserID
+			}
+			base := baseKey(r)
+
+			if !reqCC.has("no-cache") {
+				e, err := c.lookup(r.Context(), base, r, userID)
+				if err != nil {
+					metrics.CacheErrors.WithLabelValues(route).Inc()
+					c.logger.WithContext(r.Context()).Warn("Cache: %v", err)
+				}
+				if e != nil {
+					metrics.CacheRequests.WithLabelValues(route, "hit").Inc()
+					serve(w, r, e)
+					return
+				}
+			}
+			metrics.CacheRequests.WithLabelValues(route, "miss").Inc()
+
+			// HEAD не даёт тела, которое можно сохранить
+			if r.Method == http.MethodHead {
+				w.Header().Set(HeaderStatus, "MISS")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &captureWriter{w: w, req: r, header: make(http.Header), cfg: cfg, userID: userID}
+			next.ServeHTTP(cw, unconditional(r))
+
+			if !cw.store || !cw.wrote {
+				return
+			}
+			// Ответ уже отдан клиенту: сохраняем, даже если он ушёл
+			ctx := context.WithoutCancel(r.Context())
+			if err := c.save(ctx, base, r, userID, cw); err != nil {
+				metrics.CacheErrors.WithLabelValues(route).Inc()
+				c.logger.WithContext(ctx).Warn("Cache: %v", err)
+			}
+		})
+	}
+}
+
+// Purge удаляет из кэша ответы для всех адресов, начинающихся с prefix
+// (публичный путь, например /api/dev/courses). Возвращает число удалённых ключей.
+func (c *Cache) Purge(ctx context.Context, prefix string) (int, error) {
+	iter := c.rdb.Scan(ctx, 0, keyPrefix+escapeGlob(prefix)+"*", purgeBatch).Iterator()
+
+	var purged int
+	batch := make([]string, 0, purgeBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := c.rdb.Unlink(ctx, batch...).Result()
+		if err != nil {
+			return fmt.Errorf("ошибка удаления ключей кэша: %w", err)
+		}
+		purged += int(n)
+		batch = batch[:0]
+		return nil
+	}
+
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == purgeBatch {
+			if err := flush(); err != nil {
+				return purged, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return purged, fmt.Errorf("ошибка поиска ключей кэша: %w", err)
+	}
+	return purged, flush()
+}
+
+// lookup ищет сохранённый ответ для запроса.
+func (c *Cache) lookup(ctx context.Context, base string, r *http.Request, userID string) (*entry, error) {
+	raw, err := c.rdb.Get(ctx, metaKey(base, userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения кэша: %w", err)
+	}
+
+	var m meta
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("повреждённая запись кэша: %w", err)
+	}
+	if m.Private && userID == "" {
+		return nil, nil
+	}
+
+	raw, err = c.rdb.Get(ctx, entryKey(base, r, m, userID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения кэша: %w", err)
+	}
+
+	var e entry
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, fmt.Errorf("повреждённая запись кэша: %w", err)
+	}
+	return &e, nil
+}
+
+// save сохраняет ответ и правила выбора варианта для адреса.
+func (c *Cache) save(ctx context.Context, base string, r *http.Request, userID string, cw *captureWriter) error {
+	m := meta{Vary: cw.vary, Private: cw.private}
+	e := entry{Status: cw.status, Header: storedHeader(cw.header), Body: cw.buf.Bytes(), Stored: time.Now()}
+
+	rawMeta, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	rawEntry, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, metaKey(base, userID), rawMeta, cw.ttl)
+		p.Set(ctx, entryKey(base, r, m, userID), rawEntry, cw.ttl)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка записи кэша: %w", err)
+	}
+	return nil
+}
+
+// serve отдаёт сохранённый ответ, а на подходящий условный запрос — 304.
+func serve(w http.ResponseWriter, r *http.Request, e *entry) {
+	h := w.Header()
+	age := strconv.Itoa(int(time.Since(e.Stored).Seconds()))
+
+	if e.Status == http.StatusOK && notModified(r, e.Header) {
+		copyNotModified(h, e.Header)
+		h.Set("Age", age)
+		h.Set(HeaderStatus, "HIT")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	copyHeader(h, e.Header)
+	h.Set("Age", age)
+	h.Set(HeaderStatus, "HIT")
+	w.WriteHeader(e.Status)
+	if r.Method != http.MethodHead {
+		w.Write(e.Body)
+	}
+}
+
+// captureWriter проксирует ответ upstream клиенту и одновременно копит его
+// для кэша. Заголовки upstream держит отдельно, чтобы в кэш не попали
+// заголовки, которые gateway ставит сам (X-Request-ID, CORS, rate limit).
+type captureWriter struct {
+	w      http.ResponseWriter
+	req    *http.Request
+	header http.Header
+	cfg    routes.Cache
+	userID string
+
+	wrote       bool
+	status      int
+	notModified bool // Клиенту ушёл 304, тело копится только для кэша
+	buf         bytes.Buffer
+
+	// Решение о сохранении, принятое по заголовкам ответа
+	store   bool
+	ttl     time.Duration
+	private bool
+	vary    []string
+}
+
+func (cw *captureWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *captureWriter) WriteHeader(status int) {
+	if cw.wrote {
+		return
+	}
+	if status < http.StatusOK {
+		cw.w.WriteHeader(status)
+		return
+	}
+	cw.wrote = true
+	cw.status = status
+	cw.ttl, cw.private, cw.vary, cw.store = decide(cw.cfg, status, cw.header, cw.userID)
+
+	h := cw.w.Header()
+	if cw.store && status == http.StatusOK && notModified(cw.req, cw.header) {
+		cw.notModified = true
+		copyNotModified(h, cw.header)
+		h.Set(HeaderStatus, "MISS")
+		cw.w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	copyHeader(h, cw.header)
+	h.Set(HeaderStatus, "MISS")
+	cw.w.WriteHeader(status)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if !cw.wrote {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.store {
+		if int64(cw.buf.Len()+len(b)) > cw.cfg.MaxBodySize {
+			cw.store = false
+			cw.buf = bytes.Buffer{}
+		} else {
+			cw.buf.Write(b)
+		}
+	}
+	if cw.notModified {
+		return len(b), nil
+	}
+	return cw.w.Write(b)
+}
+
+// Unwrap даёт http.ResponseController доступ к Flush.
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.w
+}
+
+// decide решает по ответу upstream, можно ли его сохранить и на сколько.
+// Ответ на запрос пользователя без Cache-Control: public считается личным
+// и хранится отдельно для этого пользователя.
+func decide(cfg routes.Cache, status int, h http.Header, userID string) (ttl time.Duration, private bool, vary []string, ok bool) {
+	heuristic := false
+	switch status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent:
+		heuristic = true
+	case http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return 0, false, nil, false
+	}
+	if h.Get("Set-Cookie") != "" {
+		return 0, false, nil, false
+	}
+
+	cc := parseControl(h)
+	if cc.has("no-store") || cc.has("no-cache") {
+		return 0, false, nil, false
+	}
+
+	private = cc.has("private") || (userID != "" && !cc.has("public"))
+	if private && userID == "" {
+		return 0, false, nil, false
+	}
+
+	switch {
+	case !private && cc.has("s-maxage"):
+		ttl, ok = cc.seconds("s-maxage")
+	case cc.has("max-age"):
+		ttl, ok = cc.seconds("max-age")
+	case heuristic:
+		ttl, ok = time.Duration(cfg.TTL), true
+	}
+	if !ok || ttl <= 0 {
+		return 0, false, nil, false
+	}
+	if cfg.MaxTTL > 0 {
+		ttl = min(ttl, time.Duration(cfg.MaxTTL))
+	}
+
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			switch name {
+			case "":
+			case "*":
+				return 0, false, nil, false
+			default:
+				vary = append(vary, name)
+			}
+		}
+	}
+	return ttl, private, vary, true
+}
+
+// notModified сообщает, подходит ли сохранённая версия под условия запроса.
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		modified, err := http.ParseTime(h.Get("Last-Modified"))
+		return err == nil && !modified.After(since)
+	}
+	return false
+}
+
+// unconditional убирает условия из запроса в upstream, чтобы получить полный ответ.
+func unconditional(r *http.Request) *http.Request {
+	if r.Header.Get("If-None-Match") == "" && r.Header.Get("If-Modified-Since") == "" {
+		return r
+	}
+	r = r.Clone(r.Context())
+	r.Header.Del("If-None-Match")
+	r.Header.Del("If-Modified-Since")
+	return r
+}
+
+// copyHeader добавляет заголовки ответа к уже выставленным gateway, как ReverseProxy.
+func copyHeader(dst, src http.Header) {
+	for k, vv := range src {
+		for _, v := range vv {
+			dst.Add(k, v)
+		}
+	}
+}
+
+func copyNotModified(dst, src http.Header) {
+	for _, name := range notModifiedHeaders {
+		if v := src.Values(name); len(v) > 0 {
+			dst[name] = v
+		}
+	}
+}
+
+func storedHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range skipHeaders {
+		out.Del(name)
+	}
+	return out
+}
+
+// baseKey — публичный путь запроса с упорядоченными параметрами.
+func baseKey(r *http.Request) string {
+	return r.URL.Path + "?" + r.URL.Query().Encode()
+}
+
+// metaKey — ключ правил выбора варианта. Анонимные запросы и запросы
+// с токеном хранят их раздельно: иначе признак Private, записанный
+// по ответу пользователю, решал бы за анонимных клиентов и наоборот.
+func metaKey(base, userID string) string {
+	if userID != "" {
+		return keyPrefix + base + "#meta:auth"
+	}
+	return keyPrefix + base + "#meta"
+}
+
+// entryKey — ключ варианта ответа: значения заголовков из Vary, наличие
+// токена, для личных ответов — ID пользователя, на маршрутах с canary — вариант сборки.
+func entryKey(base string, r *http.Request, m meta, userID string) string {
+	h := sha256.New()
+	for _, name := range m.Vary {
+		fmt.Fprintf(h, "%s:%s\n", name, strings.Join(r.Header.Values(name), ","))
+	}
+	switch {
+	case m.Private:
+		fmt.Fprintf(h, "user:%s\n", userID)
+	case userID != "":
+		// Общий ответ пользователям с токеном не отдаётся анонимным
+		fmt.Fprint(h, "auth\n")
+	}
+	if v := canary.FromContext(r.Context()); v != "" {
+		fmt.Fprintf(h, "variant:%s\n", v)
+	}
+	return keyPrefix + base + "#" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// escapeGlob экранирует спецсимволы шаблона SCAN MATCH.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
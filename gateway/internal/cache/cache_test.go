@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/canary"
+	"vira-gateway/internal/clientip"
+	"vira-gateway/internal/routes"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+func TestKeys(t *testing.T) {
+	const base = "/api/dev/courses?"
+
+	// request — GET base с заголовком Accept-Language lang.
+	request := func(lang string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, base, nil)
+		if lang != "" {
+			r.Header.Set("Accept-Language", lang)
+		}
+		return r
+	}
+	shared := meta{}
+	private := meta{Private: true}
+	byLang := meta{Vary: []string{"Accept-Language"}}
+
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{"правила анонимных и пользователей раздельно", metaKey(base, ""), metaKey(base, "u1"), false},
+		{"правила общие для всех пользователей", metaKey(base, "u1"), metaKey(base, "u2"), true},
+		{"общий ответ анонимным и пользователям раздельно", entryKey(base, request(""), shared, ""), entryKey(base, request(""), shared, "u1"), false},
+		{"общий ответ один для всех пользователей", entryKey(base, request(""), shared, "u1"), entryKey(base, request(""), shared, "u2"), true},
+		{"личный ответ у каждого пользователя свой", entryKey(base, request(""), private, "u1"), entryKey(base, request(""), private, "u2"), false},
+		{"личный ответ не совпадает с общим", entryKey(base, request(""), private, "u1"), entryKey(base, request(""), shared, "u1"), false},
+		{"разные значения заголовка из Vary", entryKey(base, request("ru"), byLang, ""), entryKey(base, request("en"), byLang, ""), false},
+		{"заголовок не из Vary не учитывается", entryKey(base, request("ru"), shared, ""), entryKey(base, request("en"), shared, ""), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.a == tt.b) != tt.equal {
+				t.Fatalf("ключи %q и %q: равны = %t, want %t", tt.a, tt.b, tt.a == tt.b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	// request — запрос клиента: пользователь из токена (пусто — анонимный),
+	// язык и явно выбранный вариант canary.
+	type request struct {
+		userID  string
+		lang    string
+		variant string
+	}
+
+	tests := []struct {
+		name string
+		// Заголовки ответа upstream
+		header map[string]string
+		first  request
+		second request
+		hit    bool // Второй ответ из кэша
+	}{
+		{
+			name:   "анонимный повтор",
+			first:  request{},
+			second: request{},
+			hit:    true,
+		},
+		{
+			name:   "анонимный ответ не отдаётся пользователю",
+			first:  request{},
+			second: request{userID: "u1"},
+		},
+		{
+			name:   "ответ пользователю по умолчанию личный",
+			first:  request{userID: "u1"},
+			second: request{userID: "u1"},
+			hit:    true,
+		},
+		{
+			name:   "личный ответ не отдаётся другому пользователю",
+			first:  request{userID: "u1"},
+			second: request{userID: "u2"},
+		},
+		{
+			name:   "личный ответ не отдаётся анонимному",
+			first:  request{userID: "u1"},
+			second: request{},
+		},
+		{
+			name:   "public ответ общий для пользователей",
+			header: map[string]string{"Cache-Control": "public, max-age=60"},
+			first:  request{userID: "u1"},
+			second: request{userID: "u2"},
+			hit:    true,
+		},
+		{
+			name:   "public ответ пользователю не отдаётся анонимному",
+			header: map[string]string{"Cache-Control": "public, max-age=60"},
+			first:  request{userID: "u1"},
+			second: request{},
+		},
+		{
+			name:   "private ответ не сохраняется для анонимного",
+			header: map[string]string{"Cache-Control": "private, max-age=60"},
+			first:  request{},
+			second: request{},
+		},
+		{
+			name:   "тот же язык из Vary",
+			header: map[string]string{"Vary": "Accept-Language"},
+			first:  request{lang: "ru"},
+			second: request{lang: "ru"},
+			hit:    true,
+		},
+		{
+			name:   "другой язык из Vary",
+			header: map[string]string{"Vary": "Accept-Language"},
+			first:  request{lang: "ru"},
+			second: request{lang: "en"},
+		},
+		{
+			name:   "другой вариант canary",
+			first:  request{variant: routes.StableVariant},
+			second: request{variant: "canary"},
+		},
+		{
+			name:   "тот же вариант canary",
+			first:  request{variant: "canary"},
+			second: request{variant: "canary"},
+			hit:    true,
+		},
+		{
+			name:   "no-store в ответе",
+			header: map[string]string{"Cache-Control": "no-store"},
+			first:  request{},
+			second: request{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer rdb.Close()
+			logger := log.New(log.Config{})
+
+			upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.Write([]byte("ok"))
+			})
+			route := routes.Route{
+				Name:   "dev",
+				Cache:  routes.Cache{Enabled: true, TTL: routes.Duration(time.Minute)},
+				Canary: routes.Canary{Header: canary.HeaderVariant, Variants: []routes.Variant{{Name: "canary"}}},
+			}
+			ips, err := clientip.New(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Как в роутере: вариант выбирается снаружи кэша
+			h := New(rdb, logger).Middleware(route.Name, route.Cache)(upstream)
+			h = canary.NewWeights(nil, logger).Middleware(route, ips)(h)
+
+			serve := func(req request) string {
+				r := httptest.NewRequest(http.MethodGet, "/api/dev/courses", nil)
+				if req.lang != "" {
+					r.Header.Set("Accept-Language", req.lang)
+				}
+				if req.variant != "" {
+					r.Header.Set(canary.HeaderVariant, req.variant)
+				}
+				if req.userID != "" {
+					r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{UserID: req.userID}))
+				}
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, r)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("статус %d, want 200", rec.Code)
+				}
+				return rec.Header().Get(HeaderStatus)
+			}
+
+			if got := serve(tt.first); got != "MISS" {
+				t.Fatalf("первый запрос: %s = %q, want MISS", HeaderStatus, got)
+			}
+			want := "MISS"
+			if tt.hit {
+				want = "HIT"
+			}
+			if got := serve(tt.second); got != want {
+				t.Fatalf("второй запрос: %s = %q, want %s", HeaderStatus, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// control — разобранные директивы Cache-Control (имя в нижнем регистре → значение).
+type control map[string]string
+
+func parseControl(h http.Header) control {
+	cc := control{}
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return cc
+}
+
+func (cc control) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds возвращает значение директивы в секундах (max-age, s-maxage).
+func (cc control) seconds(name string) (time.Duration, bool) {
+	n, err := strconv.Atoi(cc[name])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
@@ -0,0 +1,134 @@
+package canary
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"slices"
+
+	"vira-gateway/internal/accesslog"
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/clientip"
+	"vira-gateway/internal/routes"
+)
+
+// HeaderVariant — заголовок ответа с вариантом, обработавшим запрос.
+// По нему тестировщик видит, что попал в новую сборку.
+const HeaderVariant = "X-Vira-Variant"
+
+type ctxKey struct{}
+
+// FromContext возвращает вариант, выбранный для запроса, или пустую строку,
+// если на маршруте нет canary.
+func FromContext(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKey{}).(string)
+	return v
+}
+
+// Middleware выбирает вариант для запроса маршрута и кладёт его в контекст.
+// Стоит снаружи кэша (ключ кэша учитывает вариант) и внутри auth
+// (назначение по весу закреплено за пользователем).
+func (w *Weights) Middleware(route routes.Route, ips *clientip.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(route.Canary.Variants) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			variant := w.pick(route, r, ips)
+
+			accesslog.SetVariant(r.Context(), variant)
+			rw.Header().Set(HeaderVariant, variant)
+			next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), ctxKey{}, variant)))
+		})
+	}
+}
+
+// Dispatch передаёт запрос обработчику выбранного варианта,
+// а без варианта в контексте — основному обработчику.
+func Dispatch(stable http.Handler, variants map[string]http.Handler) http.Handler {
+	if len(variants) == 0 {
+		return stable
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := variants[FromContext(r.Context())]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		stable.ServeHTTP(w, r)
+	})
+}
+
+// pick выбирает вариант: явный выбор тестировщика, список пользователей,
+// затем вес по корзине пользователя (анонимного — по IP).
+func (w *Weights) pick(route routes.Route, r *http.Request, ips *clientip.Resolver) string {
+	cfg := route.Canary
+
+	if forced, ok := requested(cfg, r); ok {
+		return forced
+	}
+
+	var userID string
+	if id, ok := auth.FromContext(r.Context()); ok {
+		userID = id.UserID
+	}
+	if userID != "" {
+		for _, v := range cfg.Variants {
+			if slices.Contains(v.Users, userID) {
+				return v.Name
+			}
+		}
+	}
+
+	key := userID
+	if key == "" {
+		key = "ip:" + ips.IP(r)
+	}
+	b := bucket(route.Name, key)
+
+	weights := w.Effective(route.Name)
+	acc := 0
+	for _, v := range cfg.Variants {
+		acc += weights[v.Name]
+		if b < acc {
+			return v.Name
+		}
+	}
+	return routes.StableVariant
+}
+
+// requested возвращает вариант из заголовка или cookie, если такой вариант
+// есть на маршруте (stable тоже можно выбрать явно).
+func requested(cfg routes.Canary, r *http.Request) (string, bool) {
+	var names []string
+	if cfg.Header != "" {
+		names = append(names, r.Header.Get(cfg.Header))
+	}
+	if cfg.Cookie != "" {
+		if c, err := r.Cookie(cfg.Cookie); err == nil {
+			names = append(names, c.Value)
+		}
+	}
+
+	for _, name := range names {
+		if name == routes.StableVariant {
+			return name, true
+		}
+		for _, v := range cfg.Variants {
+			if v.Name == name {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// bucket — постоянная корзина 0–99 пользователя на маршруте. Маршрут входит
+// в хэш, чтобы на разных маршрутах в canary попадали разные пользователи.
+func bucket(route, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(route))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,263 @@
+package canary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+// redisKey — hash в Redis: поле — маршрут, значение — JSON {"вариант": вес}.
+const redisKey = "gwcanary:weights"
+
+var (
+	// ErrUnknownRoute возвращается для маршрута без canary.
+	ErrUnknownRoute = errors.New("на маршруте нет canary")
+	// ErrInvalidWeights возвращается для неверных весов из admin API.
+	ErrInvalidWeights = errors.New("некорректные веса")
+)
+
+// Weights — веса вариантов canary. Веса из таблицы маршрутов можно
+// переопределить через admin API: изменения хранятся в Redis, поэтому
+// действуют на всех репликах gateway, и переживают перезагрузку таблицы.
+type Weights struct {
+	rdb    *redis.Client
+	logger *log.Logger
+
+	mu        sync.RWMutex
+	routes    map[string]routes.Canary
+	overrides map[string]map[string]int
+}
+
+// VariantStatus — вес варианта для admin API.
+type VariantStatus struct {
+	Name      string   `json:"name"`
+	Weight    int      `json:"weight"`              // Действующий вес
+	Default   int      `json:"default"`             // Вес из таблицы маршрутов
+	Override  bool     `json:"override"`            // Вес изменён через admin API
+	Users     []string `json:"users,omitempty"`     // Пользователи, всегда попадающие в вариант
+	Upstreams []string `json:"upstreams,omitempty"` // Экземпляры варианта
+}
+
+// RouteStatus — canary маршрута для admin API.
+type RouteStatus struct {
+	Route    string          `json:"route"`
+	Header   string          `json:"header,omitempty"`
+	Cookie   string          `json:"cookie,omitempty"`
+	Stable   int             `json:"stable"` // Доля основной группы
+	Variants []VariantStatus `json:"variants"`
+}
+
+// NewWeights создаёт хранилище весов.
+func NewWeights(rdb *redis.Client, logger *log.Logger) *Weights {
+	return &Weights{
+		rdb:       rdb,
+		logger:    logger,
+		routes:    map[string]routes.Canary{},
+		overrides: map[string]map[string]int{},
+	}
+}
+
+// Sync запоминает canary маршрутов новой таблицы.
+func (w *Weights) Sync(table *routes.Table) {
+	next := map[string]routes.Canary{}
+	for _, r := range table.Routes {
+		if len(r.Canary.Variants) > 0 {
+			next[r.Name] = r.Canary
+		}
+	}
+
+	w.mu.Lock()
+	w.routes = next
+	w.mu.Unlock()
+	w.publish()
+}
+
+// Effective возвращает действующие веса вариантов маршрута.
+func (w *Weights) Effective(route string) map[string]int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.effective(route)
+}
+
+func (w *Weights) effective(route string) map[string]int {
+	out := map[string]int{}
+	for _, v := range w.routes[route].Variants {
+		out[v.Name] = v.Weight
+		if o, ok := w.overrides[route][v.Name]; ok {
+			out[v.Name] = o
+		}
+	}
+	return out
+}
+
+// Set переопределяет веса вариантов маршрута. Варианты, которых нет
+// в weights, сохраняют прежний вес.
+func (w *Weights) Set(ctx context.Context, route string, weights map[string]int) error {
+	w.mu.RLock()
+	cfg, ok := w.routes[route]
+	merged := w.effective(route)
+	override := maps.Clone(w.overrides[route])
+	w.mu.RUnlock()
+
+	if !ok {
+		return ErrUnknownRoute
+	}
+	if override == nil {
+		override = map[string]int{}
+	}
+	for name, weight := range weights {
+		if _, ok := merged[name]; !ok {
+			return fmt.Errorf("%w: неизвестный вариант %q", ErrInvalidWeights, name)
+		}
+		if weight < 0 || weight > 100 {
+			return fmt.Errorf("%w: вес варианта %s должен быть от 0 до 100", ErrInvalidWeights, name)
+		}
+		merged[name] = weight
+		override[name] = weight
+	}
+
+	total := 0
+	for _, v := range cfg.Variants {
+		total += merged[v.Name]
+	}
+	if total > 100 {
+		return fmt.Errorf("%w: сумма весов вариантов %d больше 100", ErrInvalidWeights, total)
+	}
+
+	raw, err := json.Marshal(override)
+	if err != nil {
+		return err
+	}
+	if err := w.rdb.HSet(ctx, redisKey, route, raw).Err(); err != nil {
+		return fmt.Errorf("ошибка записи весов в Redis: %w", err)
+	}
+
+	w.mu.Lock()
+	w.overrides[route] = override
+	w.mu.Unlock()
+	w.publish()
+	return nil
+}
+
+// Reset возвращает маршруту веса из таблицы.
+func (w *Weights) Reset(ctx context.Context, route string) error {
+	w.mu.RLock()
+	_, ok := w.routes[route]
+	w.mu.RUnlock()
+	if !ok {
+		return ErrUnknownRoute
+	}
+
+	if err := w.rdb.HDel(ctx, redisKey, route).Err(); err != nil {
+		return fmt.Errorf("ошибка удаления весов из Redis: %w", err)
+	}
+
+	w.mu.Lock()
+	delete(w.overrides, route)
+	w.mu.Unlock()
+	w.publish()
+	return nil
+}
+
+// Status возвращает canary всех маршрутов, отсортированные по имени.
+func (w *Weights) Status() []RouteStatus {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	out := make([]RouteStatus, 0, len(w.routes))
+	for name, cfg := range w.routes {
+		eff := w.effective(name)
+		st := RouteStatus{Route: name, Header: cfg.Header, Cookie: cfg.Cookie, Stable: 100}
+		for _, v := range cfg.Variants {
+			_, override := w.overrides[name][v.Name]
+			st.Variants = append(st.Variants, VariantStatus{
+				Name:      v.Name,
+				Weight:    eff[v.Name],
+				Default:   v.Weight,
+				Override:  override,
+				Users:     v.Users,
+				Upstreams: v.Upstreams,
+			})
+			st.Stable -= eff[v.Name]
+		}
+		st.Stable = max(st.Stable, 0)
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+// Run перечитывает веса из Redis раз в interval, чтобы изменения,
+// сделанные через другую реплику, дошли до этой. Блокируется до отмены ctx.
+func (w *Weights) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.refresh(ctx); err != nil && ctx.Err() == nil {
+			w.logger.Warn("Не удалось прочитать веса canary из Redis: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Weights) refresh(ctx context.Context) error {
+	raw, err := w.rdb.HGetAll(ctx, redisKey).Result()
+	if err != nil {
+		return err
+	}
+
+	overrides := make(map[string]map[string]int, len(raw))
+	for route, value := range raw {
+		var weights map[string]int
+		if err := json.Unmarshal([]byte(value), &weights); err != nil {
+			w.logger.Warn("Некорректные веса canary маршрута %s в Redis: %v", route, err)
+			continue
+		}
+		overrides[route] = weights
+	}
+
+	w.mu.Lock()
+	changed := !equalOverrides(w.overrides, overrides)
+	w.overrides = overrides
+	w.mu.Unlock()
+
+	if changed {
+		w.logger.Info("🔀 Веса canary обновлены из Redis")
+		w.publish()
+	}
+	return nil
+}
+
+// publish выставляет метрику весов по текущему состоянию.
+func (w *Weights) publish() {
+	metrics.VariantWeight.Reset()
+	for _, st := range w.Status() {
+		metrics.VariantWeight.WithLabelValues(st.Route, routes.StableVariant).Set(float64(st.Stable))
+		for _, v := range st.Variants {
+			metrics.VariantWeight.WithLabelValues(st.Route, v.Name).Set(float64(v.Weight))
+		}
+	}
+}
+
+func equalOverrides(a, b map[string]map[string]int) bool {
+	return maps.EqualFunc(a, b, func(x, y map[string]int) bool {
+		return maps.Equal(x, y)
+	})
+}
@@ -0,0 +1,77 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Resolver определяет реальный IP клиента.
+// X-Forwarded-For учитывается только если запрос пришёл от доверенного прокси
+// (например, nginx перед gateway), иначе клиент мог бы подставить любой адрес.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// New создаёт Resolver по списку доверенных сетей в CIDR-нотации.
+func New(cidrs []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, c := range cidrs {
+		_, ipnet, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная сеть доверенного прокси %q: %w", c, err)
+		}
+		r.trusted = append(r.trusted, ipnet)
+	}
+	return r, nil
+}
+
+// IP возвращает IP клиента. Цепочка X-Forwarded-For разбирается справа налево
+// до первого адреса, не принадлежащего доверенным прокси.
+func (res *Resolver) IP(r *http.Request) string {
+	remote := remoteIP(r.RemoteAddr)
+	if !res.isTrusted(remote) {
+		return remote
+	}
+
+	xff := r.Header.Values("X-Forwarded-For")
+	var hops []string
+	for _, v := range xff {
+		for _, part := range strings.Split(v, ",") {
+			if p := strings.TrimSpace(part); p != "" {
+				hops = append(hops, p)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !res.isTrusted(hops[i]) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return remote
+}
+
+func (res *Resolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range res.trusted {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
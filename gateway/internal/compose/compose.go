@@ -0,0 +1,196 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+
+	log "github.com/skrolikov/vira-logger"
+)
+
+// maxSectionSize — предел тела ответа секции.
+const maxSectionSize = 1 << 20
+
+// errTooLarge возвращается из Write, когда ответ секции превысил maxSectionSize.
+var errTooLarge = errors.New("ответ секции слишком большой")
+
+// SectionError — отметка об ошибке секции в составном документе.
+type SectionError struct {
+	Status int    `json:"status"` // Статус ответа маршрута (504 при таймауте)
+	Error  string `json:"error"`  // Текст ошибки
+}
+
+// result — исход запроса одной секции.
+type result struct {
+	data   json.RawMessage
+	absent bool
+	err    *SectionError
+}
+
+// Handler обслуживает составной эндпоинт. Секции запрашиваются параллельно
+// через обработчики маршрутов из handlers (по имени маршрута) — так на каждую
+// секцию действуют auth, rate limit и кэш её маршрута, а в модуль уходит
+// токен клиента. Документ: {"<секция>": <ответ или null>, "errors": {...}}.
+func Handler(c routes.Composition, handlers map[string]http.Handler, logger *log.Logger) http.HandlerFunc {
+	timeout := time.Duration(c.Timeout)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			apierror.Write(w, http.StatusMethodNotAllowed, "метод не поддерживается")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		results := make([]result, len(c.Sections))
+		var wg sync.WaitGroup
+		for i, s := range c.Sections {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = fetch(ctx, r, s, handlers[s.Route])
+			}()
+		}
+		wg.Wait()
+
+		doc := make(map[string]any, len(c.Sections)+1)
+		errs := map[string]*SectionError{}
+		l := logger.WithContext(r.Context())
+
+		for i, s := range c.Sections {
+			res := results[i]
+			switch {
+			case res.err != nil:
+				metrics.CompositionSections.WithLabelValues(c.Name, s.Name, "error").Inc()
+				l.Warn("Секция %s: статус %d: %s", s.Name, res.err.Status, res.err.Error)
+				if s.Required {
+					apierror.Write(w, requiredStatus(res.err.Status), res.err.Error)
+					return
+				}
+				doc[s.Name] = nil
+				errs[s.Name] = res.err
+			case res.absent:
+				metrics.CompositionSections.WithLabelValues(c.Name, s.Name, "absent").Inc()
+				if s.Required {
+					apierror.Write(w, http.StatusNotFound, "не найдено")
+					return
+				}
+				doc[s.Name] = nil
+			default:
+				metrics.CompositionSections.WithLabelValues(c.Name, s.Name, "ok").Inc()
+				doc[s.Name] = res.data
+			}
+		}
+		if len(errs) > 0 {
+			doc["errors"] = errs
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		// Документ собран для конкретного пользователя
+		w.Header().Set("Cache-Control", "private, no-store")
+		json.NewEncoder(w).Encode(doc)
+	}
+}
+
+// fetch выполняет GET секции через обработчик её маршрута.
+func fetch(ctx context.Context, in *http.Request, s routes.Section, h http.Handler) result {
+	req := in.Clone(ctx)
+	req.Method = http.MethodGet
+	req.URL.Path, req.URL.RawPath, req.URL.RawQuery = s.Path, "", ""
+	req.RequestURI = s.Path
+	req.Body, req.ContentLength = http.NoBody, 0
+
+	// Тело нужно gateway, а не клиенту: сжатие и условные заголовки клиента
+	// к ответам секций не относятся
+	for _, name := range []string{"Accept-Encoding", "Content-Type", "Content-Length", "Range",
+		"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range"} {
+		req.Header.Del(name)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	rec := newRecorder()
+	h.ServeHTTP(rec, req)
+
+	switch {
+	case rec.overflow:
+		return result{err: &SectionError{Status: http.StatusBadGateway, Error: "слишком большой ответ"}}
+	case rec.status == http.StatusNotFound:
+		return result{absent: true}
+	case rec.status >= 200 && rec.status < 300:
+		if !json.Valid(rec.body.Bytes()) {
+			return result{err: &SectionError{Status: http.StatusBadGateway, Error: "ответ не JSON"}}
+		}
+		return result{data: rec.body.Bytes()}
+	default:
+		return result{err: &SectionError{Status: rec.status, Error: errorText(rec)}}
+	}
+}
+
+// requiredStatus — статус ответа клиенту при ошибке обязательной секции:
+// ошибки клиента (401, 403, 429) передаются как есть, ошибки сервиса — как 502/503/504.
+func requiredStatus(status int) int {
+	switch {
+	case status < 500:
+		return status
+	case status == http.StatusServiceUnavailable, status == http.StatusGatewayTimeout:
+		return status
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// errorText достаёт текст ошибки из ответа: поле error JSON-ошибки gateway
+// или текст ответа модуля.
+func errorText(rec *recorder) string {
+	var e apierror.Response
+	if json.Unmarshal(rec.body.Bytes(), &e) == nil && e.Error != "" {
+		return e.Error
+	}
+	if text := bytes.TrimSpace(rec.body.Bytes()); len(text) > 0 && len(text) <= 200 {
+		return string(text)
+	}
+	return http.StatusText(rec.status)
+}
+
+// recorder — ResponseWriter секции: копит ответ в памяти до maxSectionSize.
+type recorder struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 && status >= 200 {
+		r.status = status
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if r.body.Len()+len(b) > maxSectionSize {
+		r.overflow = true
+		return 0, errTooLarge
+	}
+	return r.body.Write(b)
+}
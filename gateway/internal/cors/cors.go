@@ -0,0 +1,166 @@
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/respwriter"
+	"vira-gateway/internal/routes"
+)
+
+// Заголовки ответа, которыми управляет только gateway: значения от upstream удаляются.
+var responseHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Max-Age",
+}
+
+// Policy — подготовленная к применению CORS политика маршрута.
+type Policy struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	methods     []string
+	headers     map[string]bool
+	anyHeader   bool
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// New готовит политику из конфигурации. Политика должна быть провалидирована.
+func New(cfg routes.CORSPolicy) *Policy {
+	p := &Policy{
+		origins:     make(map[string]bool, len(cfg.AllowedOrigins)),
+		headers:     make(map[string]bool, len(cfg.AllowedHeaders)),
+		methods:     cfg.AllowedMethods,
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		p.origins[strings.ToLower(strings.TrimSuffix(o, "/"))] = true
+	}
+	for _, h := range cfg.AllowedHeaders {
+		if h == "*" {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	if len(p.methods) == 0 {
+		p.methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(time.Duration(cfg.MaxAge).Seconds()))
+	}
+	return p
+}
+
+// Middleware отвечает на preflight запросы сам и добавляет CORS заголовки
+// к ответам upstream. Запросы с неразрешённого origin проксируются
+// без CORS заголовков — браузер не отдаст ответ странице.
+func (p *Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && p.allowOrigin(origin)
+
+		if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+			p.preflight(w, r, origin, allowed)
+			return
+		}
+
+		w = respwriter.OnHeader(w, func(h http.Header) {
+			for _, name := range responseHeaders {
+				h.Del(name)
+			}
+			if origin == "" {
+				return
+			}
+			h.Add("Vary", "Origin")
+			if !allowed {
+				return
+			}
+			p.setOrigin(h, origin)
+			if p.exposed != "" {
+				h.Set("Access-Control-Expose-Headers", p.exposed)
+			}
+		})
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *Policy) preflight(w http.ResponseWriter, r *http.Request, origin string, allowed bool) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	if !allowed {
+		apierror.Write(w, http.StatusForbidden, "origin не разрешён")
+		return
+	}
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !slices.Contains(p.methods, method) {
+		apierror.Write(w, http.StatusForbidden, "метод не разрешён")
+		return
+	}
+
+	requested := requestedHeaders(r)
+	if !p.anyHeader {
+		for _, name := range requested {
+			if !p.headers[name] {
+				apierror.Write(w, http.StatusForbidden, "заголовок "+name+" не разрешён")
+				return
+			}
+		}
+	}
+
+	p.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", strings.Join(p.methods, ", "))
+	if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if p.maxAge != "" {
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *Policy) allowOrigin(origin string) bool {
+	return p.anyOrigin || p.origins[strings.ToLower(origin)]
+}
+
+func (p *Policy) setOrigin(h http.Header, origin string) {
+	if p.anyOrigin && !p.credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// requestedHeaders разбирает Access-Control-Request-Headers в канонический вид.
+func requestedHeaders(r *http.Request) []string {
+	var out []string
+	for _, v := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				out = append(out, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,338 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+const (
+	// HeaderKey — заголовок запроса с ключом идемпотентности.
+	HeaderKey = "Idempotency-Key"
+
+	// HeaderReplayed — заголовок ответа, повторённого из сохранённого.
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+// keyPrefix — префикс ключей в Redis.
+const keyPrefix = "gwidem:"
+
+// maxKeyLength — предел длины Idempotency-Key.
+const maxKeyLength = 255
+
+// lockMargin — запас времени блокировки сверх таймаута маршрута:
+// если реплика упала посреди запроса, ключ освободится сам.
+const lockMargin = 10 * time.Second
+
+// Заголовки, которые не сохраняются: относятся к соединению или ставятся
+// gateway на каждый ответ заново.
+var skipHeaders = []string{
+	"Connection", "Keep-Alive", "Transfer-Encoding", "Trailer", "Upgrade", "X-Request-Id",
+}
+
+// Состояния записи в Redis.
+const (
+	statePending = "pending"
+	stateDone    = "done"
+)
+
+// record — запись ключа в Redis: блокировка выполняющегося запроса
+// или сохранённый ответ.
+type record struct {
+	State       string      `json:"state"`
+	Token       string      `json:"token,omitempty"` // Владелец блокировки
+	Fingerprint string      `json:"fingerprint"`     // Хэш метода, адреса и тела запроса
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// finish заменяет блокировку ответом (ARGV[2]) или удаляет её (ARGV[2] пуст),
+// только если блокировка всё ещё наша.
+var finish = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == "" then
+	return redis.call("DEL", KEYS[1])
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+return 1
+`)
+
+// Store хранит ответы на запросы с Idempotency-Key в Redis, общем для всех реплик.
+type Store struct {
+	rdb    *redis.Client
+	logger *log.Logger
+}
+
+// New создаёт Store.
+func New(rdb *redis.Client, logger *log.Logger) *Store {
+	return &Store{rdb: rdb, logger: logger}
+}
+
+// Middleware повторяет сохранённый ответ на изменяющие запросы маршрута
+// с тем же Idempotency-Key. Стоит внутри auth: ключи разных пользователей
+// не пересекаются. Запросы без токена идут мимо: по IP клиенты за одним
+// NAT получали бы ответы друг друга. Если Redis недоступен, запрос
+// выполняется как обычно.
+func (s *Store) Middleware(route routes.Route) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !route.Idempotency.Enabled {
+			return next
+		}
+		cfg := route.Idempotency.WithDefaults()
+		lockTTL := time.Duration(route.Timeout) + lockMargin
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKey)
+			id, ok := auth.FromContext(r.Context())
+			if key == "" || !ok || id.UserID == "" || !mutating(r.Method) || excluded(cfg.ExcludePaths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !validKey(key) {
+				apierror.Write(w, http.StatusBadRequest, "некорректный Idempotency-Key: 1–255 печатных ASCII символов")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			var tooLarge *http.MaxBytesError
+			switch {
+			case errors.As(err, &tooLarge):
+				apierror.Write(w, http.StatusRequestEntityTooLarge, "тело запроса слишком большое")
+				return
+			case err != nil:
+				apierror.Write(w, http.StatusBadRequest, "не удалось прочитать тело запроса")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			l := s.logger.WithContext(r.Context())
+			rkey := redisKey(route.Name, id.UserID, key)
+			pending := record{State: statePending, Token: token(), Fingerprint: fingerprint(r, body)}
+			rawPending, _ := json.Marshal(pending)
+
+			acquired, err := s.rdb.SetNX(r.Context(), rkey, rawPending, lockTTL).Result()
+			if err != nil {
+				metrics.IdempotencyRequests.WithLabelValues(route.Name, "error").Inc()
+				l.Warn("Idempotency: ошибка Redis: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !acquired {
+				s.duplicate(w, r, route.Name, rkey, pending.Fingerprint)
+				return
+			}
+
+			cw := &captureWriter{w: w, header: make(http.Header), limit: cfg.MaxBodySize}
+			next.ServeHTTP(cw, r)
+			if !cw.wrote {
+				cw.WriteHeader(http.StatusOK)
+			}
+
+			// Ответ уже отдан клиенту: сохраняем, даже если он ушёл
+			ctx := context.WithoutCancel(r.Context())
+			done := ""
+			if cw.status < http.StatusInternalServerError && !cw.overflow {
+				rec := record{State: stateDone, Fingerprint: pending.Fingerprint, Status: cw.status, Header: storedHeader(cw.header), Body: cw.buf.Bytes()}
+				raw, _ := json.Marshal(rec)
+				done = string(raw)
+			}
+
+			owned, err := finish.Run(ctx, s.rdb, []string{rkey}, rawPending, done, time.Duration(cfg.TTL).Milliseconds()).Int()
+			if err != nil {
+				metrics.IdempotencyRequests.WithLabelValues(route.Name, "error").Inc()
+				l.Warn("Idempotency: ошибка сохранения ответа: %v", err)
+				return
+			}
+			if owned == 0 {
+				l.Warn("Idempotency: блокировка ключа истекла раньше ответа, ответ не сохранён")
+			}
+			if done == "" || owned == 0 {
+				metrics.IdempotencyRequests.WithLabelValues(route.Name, "released").Inc()
+				return
+			}
+			metrics.IdempotencyRequests.WithLabelValues(route.Name, "stored").Inc()
+		})
+	}
+}
+
+// duplicate отвечает на запрос с уже использованным ключом.
+func (s *Store) duplicate(w http.ResponseWriter, r *http.Request, route, rkey, fp string) {
+	raw, err := s.rdb.Get(r.Context(), rkey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Блокировку только что сняли после 5xx — клиент может повторить
+		metrics.IdempotencyRequests.WithLabelValues(route, "conflict").Inc()
+		conflict(w)
+		return
+	}
+
+	var rec record
+	if err == nil {
+		err = json.Unmarshal(raw, &rec)
+	}
+	if err != nil {
+		metrics.IdempotencyRequests.WithLabelValues(route, "error").Inc()
+		s.logger.WithContext(r.Context()).Warn("Idempotency: ошибка чтения записи: %v", err)
+		apierror.Write(w, http.StatusServiceUnavailable, "не удалось проверить Idempotency-Key, попробуйте позже")
+		return
+	}
+
+	switch {
+	case rec.Fingerprint != fp:
+		metrics.IdempotencyRequests.WithLabelValues(route, "mismatch").Inc()
+		apierror.Write(w, http.StatusUnprocessableEntity, "Idempotency-Key уже использован с другим запросом")
+	case rec.State == statePending:
+		metrics.IdempotencyRequests.WithLabelValues(route, "conflict").Inc()
+		conflict(w)
+	default:
+		metrics.IdempotencyRequests.WithLabelValues(route, "replayed").Inc()
+		h := w.Header()
+		for name, values := range rec.Header {
+			h[name] = values
+		}
+		h.Set(HeaderReplayed, "true")
+		w.WriteHeader(rec.Status)
+		w.Write(rec.Body)
+	}
+}
+
+func conflict(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	apierror.Write(w, http.StatusConflict, "запрос с этим Idempotency-Key ещё выполняется")
+}
+
+// captureWriter отдаёт ответ клиенту и одновременно копит его для сохранения.
+// Заголовки upstream держит отдельно, чтобы не сохранить заголовки,
+// которые gateway ставит сам (CORS, rate limit).
+type captureWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+	limit  int64
+
+	wrote    bool
+	status   int
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (cw *captureWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *captureWriter) WriteHeader(status int) {
+	if cw.wrote {
+		return
+	}
+	if status < http.StatusOK {
+		cw.w.WriteHeader(status)
+		return
+	}
+	cw.wrote = true
+	cw.status = status
+
+	h := cw.w.Header()
+	for name, values := range cw.header {
+		h[name] = values
+	}
+	cw.w.WriteHeader(status)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if !cw.wrote {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.overflow {
+		if int64(cw.buf.Len()+len(b)) > cw.limit {
+			cw.overflow = true
+			cw.buf = bytes.Buffer{}
+		} else {
+			cw.buf.Write(b)
+		}
+	}
+	return cw.w.Write(b)
+}
+
+// Unwrap даёт http.ResponseController доступ к Flush.
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.w
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// excluded сообщает, попадает ли путь запроса под exclude_paths: сам путь
+// или вложенный в него. Путь нормализуется, чтобы /api/dev//login/
+// не обходил исключение для /api/dev/login.
+func excluded(paths []string, p string) bool {
+	p = path.Clean("/" + p)
+	for _, e := range paths {
+		if p == e || strings.HasPrefix(p, e+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func redisKey(route, userID, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%s%s:user:%s:%s", keyPrefix, route, userID, hex.EncodeToString(sum[:]))
+}
+
+// fingerprint — хэш запроса: тот же ключ с другим телом или адресом — ошибка клиента.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func token() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func storedHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range skipHeaders {
+		out.Del(name)
+	}
+	return out
+}
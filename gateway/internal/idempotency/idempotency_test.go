@@ -0,0 +1,252 @@
+package idempotency
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/routes"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+// route — маршрут dev с Idempotency-Key и исключённым входом.
+var route = routes.Route{
+	Name:    "dev",
+	Prefix:  "/api/dev",
+	Timeout: routes.Duration(time.Second),
+	Idempotency: routes.Idempotency{
+		Enabled:      true,
+		ExcludePaths: []string{"/api/dev/login"},
+	},
+}
+
+// request — POST path с телом body и ключом key от пользователя userID
+// (пусто — анонимный запрос).
+type request struct {
+	path   string
+	body   string
+	key    string
+	userID string
+}
+
+func (req request) build() *http.Request {
+	r := httptest.NewRequest(http.MethodPost, req.path, strings.NewReader(req.body))
+	if req.key != "" {
+		r.Header.Set(HeaderKey, req.key)
+	}
+	if req.userID != "" {
+		r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{UserID: req.userID}))
+	}
+	return r
+}
+
+// newTestStore создаёт Store поверх miniredis.
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return New(rdb, log.New(log.Config{})), mr
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		first, second request
+		status        int // Статус второго ответа
+		replayed      bool
+		calls         int // Сколько запросов дошло до upstream
+	}{
+		{
+			name:     "повтор ответа",
+			first:    request{"/api/dev/orders", `{"n":1}`, "k1", "u1"},
+			second:   request{"/api/dev/orders", `{"n":1}`, "k1", "u1"},
+			status:   http.StatusCreated,
+			replayed: true,
+			calls:    1,
+		},
+		{
+			name:   "тот же ключ с другим телом",
+			first:  request{"/api/dev/orders", `{"n":1}`, "k1", "u1"},
+			second: request{"/api/dev/orders", `{"n":2}`, "k1", "u1"},
+			status: http.StatusUnprocessableEntity,
+			calls:  1,
+		},
+		{
+			name:   "тот же ключ на другом адресе",
+			first:  request{"/api/dev/orders", `{"n":1}`, "k1", "u1"},
+			second: request{"/api/dev/orders/2", `{"n":1}`, "k1", "u1"},
+			status: http.StatusUnprocessableEntity,
+			calls:  1,
+		},
+		{
+			name:   "ключи разных пользователей не пересекаются",
+			first:  request{"/api/dev/orders", `{"n":1}`, "k1", "u1"},
+			second: request{"/api/dev/orders", `{"n":1}`, "k1", "u2"},
+			status: http.StatusCreated,
+			calls:  2,
+		},
+		{
+			name:   "анонимный запрос не сохраняется",
+			first:  request{"/api/dev/orders", `{"n":1}`, "k1", ""},
+			second: request{"/api/dev/orders", `{"n":1}`, "k1", ""},
+			status: http.StatusCreated,
+			calls:  2,
+		},
+		{
+			name:   "исключённый путь",
+			first:  request{"/api/dev/login", `{}`, "k1", "u1"},
+			second: request{"/api/dev/login", `{}`, "k1", "u1"},
+			status: http.StatusCreated,
+			calls:  2,
+		},
+		{
+			name:   "исключённый путь со слэшем в конце",
+			first:  request{"/api/dev/login/", `{}`, "k1", "u1"},
+			second: request{"/api/dev/login/", `{}`, "k1", "u1"},
+			status: http.StatusCreated,
+			calls:  2,
+		},
+		{
+			name:   "исключённый путь с лишними сегментами",
+			first:  request{"/api/dev//./login", `{}`, "k1", "u1"},
+			second: request{"/api/dev//./login", `{}`, "k1", "u1"},
+			status: http.StatusCreated,
+			calls:  2,
+		},
+		{
+			name:   "путь, вложенный в исключённый",
+			first:  request{"/api/dev/login/otp", `{}`, "k1", "u1"},
+			second: request{"/api/dev/login/otp", `{}`, "k1", "u1"},
+			status: http.StatusCreated,
+			calls:  2,
+		},
+		{
+			name:     "путь с тем же началом не исключается",
+			first:    request{"/api/dev/logins", `{}`, "k1", "u1"},
+			second:   request{"/api/dev/logins", `{}`, "k1", "u1"},
+			status:   http.StatusCreated,
+			replayed: true,
+			calls:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestStore(t)
+			calls := 0
+			h := s.Middleware(route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, "created")
+			}))
+
+			h.ServeHTTP(httptest.NewRecorder(), tt.first.build())
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, tt.second.build())
+
+			if rec.Code != tt.status {
+				t.Fatalf("второй ответ: %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get(HeaderReplayed) == "true"; got != tt.replayed {
+				t.Fatalf("%s = %t, want %t", HeaderReplayed, got, tt.replayed)
+			}
+			if calls != tt.calls {
+				t.Fatalf("запросов в upstream: %d, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestMiddlewareInFlight(t *testing.T) {
+	s, mr := newTestStore(t)
+	req := request{"/api/dev/orders", `{"n":1}`, "k1", "u1"}
+	rkey := redisKey(route.Name, "u1", "k1")
+
+	tests := []struct {
+		name string
+		// during выполняется, пока исходный запрос в upstream
+		during func(t *testing.T, h http.Handler)
+		// Что лежит по ключу после исходного запроса (пусто — ключа нет)
+		want string
+	}{
+		{
+			name: "дубликат во время выполнения",
+			during: func(t *testing.T, h http.Handler) {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req.build())
+				if rec.Code != http.StatusConflict {
+					t.Fatalf("дубликат: %d, want 409", rec.Code)
+				}
+			},
+			want: stateDone,
+		},
+		{
+			name: "блокировку перехватил другой запрос",
+			during: func(t *testing.T, _ http.Handler) {
+				// Блокировка истекла, и ключ занял запрос с другой реплики
+				mr.Set(rkey, `{"state":"pending","token":"чужой"}`)
+			},
+			want: "чужой",
+		},
+		{
+			name: "блокировка истекла",
+			during: func(t *testing.T, _ http.Handler) {
+				mr.Del(rkey)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr.FlushAll()
+			var h http.Handler
+			h = s.Middleware(route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.during(t, h)
+				w.WriteHeader(http.StatusCreated)
+			}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req.build())
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("исходный запрос: %d, want 201", rec.Code)
+			}
+
+			got, err := mr.Get(rkey)
+			switch {
+			case tt.want == "":
+				if err == nil {
+					t.Fatalf("по ключу %q, want ключа нет", got)
+				}
+			case err != nil:
+				t.Fatalf("ключа нет, want запись с %q", tt.want)
+			case !strings.Contains(got, tt.want):
+				t.Fatalf("по ключу %q, want запись с %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareServerError(t *testing.T) {
+	s, _ := newTestStore(t)
+	req := request{"/api/dev/orders", `{"n":1}`, "k1", "u1"}
+
+	status := http.StatusBadGateway
+	h := s.Middleware(route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	// 5xx не сохраняется: повтор с тем же ключом выполняется заново
+	h.ServeHTTP(httptest.NewRecorder(), req.build())
+	status = http.StatusCreated
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req.build())
+	if rec.Code != http.StatusCreated || rec.Header().Get(HeaderReplayed) != "" {
+		t.Fatalf("повтор после 5xx: %d %s=%q, want 201 без повтора", rec.Code, HeaderReplayed, rec.Header().Get(HeaderReplayed))
+	}
+}
@@ -0,0 +1,215 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/metrics"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+// redisKey — hash в Redis: поле — маршрут, значение — JSON Mode.
+const redisKey = "gwmaintenance:routes"
+
+// defaultMessage — текст ошибки, если тело ответа не задано.
+const defaultMessage = "сервис на обслуживании, попробуйте позже"
+
+// Mode — режим обслуживания маршрута: gateway отвечает 503,
+// не передавая запросы в upstream.
+type Mode struct {
+	Body        string    `json:"body,omitempty"`         // Тело ответа (по умолчанию JSON-ошибка gateway)
+	ContentType string    `json:"content_type,omitempty"` // Content-Type тела (по умолчанию text/plain)
+	RetryAfter  int       `json:"retry_after,omitempty"`  // Заголовок Retry-After, секунды
+	Since       time.Time `json:"since"`                  // Когда режим включён
+}
+
+func (m Mode) equal(o Mode) bool {
+	return m.Body == o.Body && m.ContentType == o.ContentType &&
+		m.RetryAfter == o.RetryAfter && m.Since.Equal(o.Since)
+}
+
+// Entry — режим обслуживания маршрута для admin API.
+type Entry struct {
+	Route string `json:"route"`
+	Mode
+}
+
+// Switch — переключатель режима обслуживания маршрутов. Состояние хранится
+// в Redis, поэтому действует на всех репликах gateway и переживает
+// перезагрузку таблицы маршрутов и перезапуск.
+type Switch struct {
+	rdb    *redis.Client
+	logger *log.Logger
+
+	mu     sync.RWMutex
+	routes map[string]Mode
+}
+
+// New создаёт переключатель.
+func New(rdb *redis.Client, logger *log.Logger) *Switch {
+	return &Switch{rdb: rdb, logger: logger, routes: map[string]Mode{}}
+}
+
+// Enable включает режим обслуживания маршрута.
+func (s *Switch) Enable(ctx context.Context, route string, m Mode) error {
+	if m.RetryAfter < 0 {
+		return errors.New("retry_after не может быть отрицательным")
+	}
+	if m.Since.IsZero() {
+		m.Since = time.Now().UTC()
+	}
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := s.rdb.HSet(ctx, redisKey, route, raw).Err(); err != nil {
+		return fmt.Errorf("ошибка записи режима обслуживания в Redis: %w", err)
+	}
+
+	s.mu.Lock()
+	s.routes[route] = m
+	s.mu.Unlock()
+	s.publish()
+
+	s.logger.Warn("🚧 Маршрут %s переведён в режим обслуживания", route)
+	return nil
+}
+
+// Disable выключает режим обслуживания маршрута.
+func (s *Switch) Disable(ctx context.Context, route string) error {
+	if err := s.rdb.HDel(ctx, redisKey, route).Err(); err != nil {
+		return fmt.Errorf("ошибка удаления режима обслуживания из Redis: %w", err)
+	}
+
+	s.mu.Lock()
+	_, ok := s.routes[route]
+	delete(s.routes, route)
+	s.mu.Unlock()
+	s.publish()
+
+	if ok {
+		s.logger.Info("✅ Маршрут %s выведен из режима обслуживания", route)
+	}
+	return nil
+}
+
+// Get возвращает режим обслуживания маршрута.
+func (s *Switch) Get(route string) (Mode, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.routes[route]
+	return m, ok
+}
+
+// Status возвращает маршруты в режиме обслуживания, отсортированные по имени.
+func (s *Switch) Status() []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Entry, 0, len(s.routes))
+	for route, m := range s.routes {
+		out = append(out, Entry{Route: route, Mode: m})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+// Middleware отвечает 503 на запросы маршрута, пока включён режим обслуживания.
+// Стоит внутри CORS, чтобы браузер мог прочитать ответ, и снаружи auth,
+// чтобы клиент без токена увидел 503, а не 401.
+func (s *Switch) Middleware(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m, ok := s.Get(route)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if m.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(m.RetryAfter))
+			}
+			w.Header().Set("Cache-Control", "no-store")
+			if m.Body == "" {
+				apierror.Write(w, http.StatusServiceUnavailable, defaultMessage)
+				return
+			}
+
+			contentType := m.ContentType
+			if contentType == "" {
+				contentType = "text/plain; charset=utf-8"
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(m.Body))
+		})
+	}
+}
+
+// Run перечитывает режимы из Redis раз в interval, чтобы изменения,
+// сделанные через другую реплику, дошли до этой. Блокируется до отмены ctx.
+func (s *Switch) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.refresh(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("Не удалось прочитать режимы обслуживания из Redis: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Switch) refresh(ctx context.Context) error {
+	raw, err := s.rdb.HGetAll(ctx, redisKey).Result()
+	if err != nil {
+		return err
+	}
+
+	routes := make(map[string]Mode, len(raw))
+	for route, value := range raw {
+		var m Mode
+		if err := json.Unmarshal([]byte(value), &m); err != nil {
+			s.logger.Warn("Некорректный режим обслуживания маршрута %s в Redis: %v", route, err)
+			continue
+		}
+		routes[route] = m
+	}
+
+	s.mu.Lock()
+	changed := !maps.EqualFunc(s.routes, routes, Mode.equal)
+	s.routes = routes
+	s.mu.Unlock()
+
+	if changed {
+		s.logger.Info("🚧 Режимы обслуживания обновлены из Redis")
+		s.publish()
+	}
+	return nil
+}
+
+// publish выставляет метрику по текущему состоянию.
+func (s *Switch) publish() {
+	metrics.Maintenance.Reset()
+	for _, e := range s.Status() {
+		metrics.Maintenance.WithLabelValues(e.Route).Set(1)
+	}
+}
@@ -0,0 +1,345 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Requests — счётчик запросов к маршрутам gateway.
+//
+// Метрика: gateway_requests_total
+// Labels:
+// - route: имя маршрута из таблицы
+// - upstream: адрес экземпляра (host:port) или none, если запрос не дошёл до upstream
+// - status: класс кода ответа (2xx, 3xx, 4xx, 5xx)
+var Requests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_requests_total",
+		Help: "Количество запросов, обработанных gateway",
+	},
+	[]string{"route", "upstream", "status"},
+)
+
+// RequestDuration — гистограмма полного времени обработки запроса gateway
+// (вместе с auth, rate limit и повторами).
+//
+// Метрика: gateway_request_duration_seconds
+// Labels: как у gateway_requests_total
+var RequestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "gateway_request_duration_seconds",
+		Help:    "Время обработки запроса gateway в секундах",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	},
+	[]string{"route", "upstream", "status"},
+)
+
+// RequestsInFlight — число запросов маршрута в обработке.
+//
+// Метрика: gateway_requests_in_flight
+// Labels:
+// - route: имя маршрута из таблицы
+var RequestsInFlight = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "gateway_requests_in_flight",
+		Help: "Количество запросов в обработке gateway",
+	},
+	[]string{"route"},
+)
+
+// UpstreamInFlight — число запросов, отправленных на экземпляр upstream
+// и ещё не завершённых.
+//
+// Метрика: gateway_upstream_in_flight
+// Labels:
+// - route: имя маршрута из таблицы
+// - upstream: адрес экземпляра (host:port)
+var UpstreamInFlight = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "gateway_upstream_in_flight",
+		Help: "Количество запросов в обработке на экземплярах upstream",
+	},
+	[]string{"route", "upstream"},
+)
+
+// RateLimitDecisions — счётчик решений rate limiter-а.
+//
+// Метрика: gateway_ratelimit_requests_total
+// Labels:
+// - route: имя маршрута из таблицы
+// - key: признак лимита (ip, user, api_key)
+// - result: allowed или limited
+var RateLimitDecisions = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_ratelimit_requests_total",
+		Help: "Количество запросов, проверенных rate limiter-ом",
+	},
+	[]string{"route", "key", "result"},
+)
+
+// RateLimitErrors — счётчик ошибок обращения к Redis при проверке лимита.
+// При ошибке запрос пропускается (fail open).
+//
+// Метрика: gateway_ratelimit_errors_total
+// Labels:
+// - route: имя маршрута из таблицы
+var RateLimitErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_ratelimit_errors_total",
+		Help: "Количество ошибок Redis при проверке rate limit",
+	},
+	[]string{"route"},
+)
+
+// CircuitState — текущее состояние circuit breaker-а upstream-а.
+//
+// Метрика: gateway_circuit_state
+// Labels:
+// - route: имя маршрута из таблицы
+// Значения: 0 — closed, 1 — half_open, 2 — open
+var CircuitState = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "gateway_circuit_state",
+		Help: "Состояние circuit breaker-а upstream-а (0 — closed, 1 — half_open, 2 — open)",
+	},
+	[]string{"route"},
+)
+
+// CircuitTransitions — счётчик переходов circuit breaker-а.
+//
+// Метрика: gateway_circuit_transitions_total
+// Labels:
+// - route: имя маршрута из таблицы
+// - to: новое состояние (closed, half_open, open)
+var CircuitTransitions = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_circuit_transitions_total",
+		Help: "Количество переходов circuit breaker-а между состояниями",
+	},
+	[]string{"route", "to"},
+)
+
+// CircuitRejected — счётчик запросов, отклонённых разомкнутой цепью.
+//
+// Метрика: gateway_circuit_rejected_total
+// Labels:
+// - route: имя маршрута из таблицы
+var CircuitRejected = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_circuit_rejected_total",
+		Help: "Количество запросов, отклонённых circuit breaker-ом без обращения к upstream",
+	},
+	[]string{"route"},
+)
+
+// UpstreamRetries — счётчик повторных попыток запроса к upstream.
+//
+// Метрика: gateway_upstream_retries_total
+// Labels:
+// - route: имя маршрута из таблицы
+var UpstreamRetries = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_upstream_retries_total",
+		Help: "Количество повторных попыток запроса к upstream",
+	},
+	[]string{"route"},
+)
+
+// StreamsActive — число открытых долгоживущих соединений.
+//
+// Метрика: gateway_streams_active
+// Labels:
+// - route: имя маршрута из таблицы
+// - kind: websocket или sse
+var StreamsActive = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "gateway_streams_active",
+		Help: "Количество открытых WebSocket и SSE соединений",
+	},
+	[]string{"route", "kind"},
+)
+
+// StreamsRejected — счётчик потоков, отклонённых из-за предела соединений на пользователя.
+//
+// Метрика: gateway_streams_rejected_total
+// Labels:
+// - route: имя маршрута из таблицы
+// - kind: websocket или sse
+var StreamsRejected = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_streams_rejected_total",
+		Help: "Количество WebSocket и SSE соединений, отклонённых пределом на пользователя",
+	},
+	[]string{"route", "kind"},
+)
+
+// CacheRequests — счётчик обращений к кэшу ответов.
+//
+// Метрика: gateway_cache_requests_total
+// Labels:
+// - route: имя маршрута из таблицы
+// - result: hit, miss или bypass (Cache-Control: no-store в запросе)
+var CacheRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_cache_requests_total",
+		Help: "Количество запросов, проверенных кэшем ответов",
+	},
+	[]string{"route", "result"},
+)
+
+// CacheErrors — счётчик ошибок Redis при работе с кэшем ответов.
+// При ошибке запрос проксируется без кэша.
+//
+// Метрика: gateway_cache_errors_total
+// Labels:
+// - route: имя маршрута из таблицы
+var CacheErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_cache_errors_total",
+		Help: "Количество ошибок Redis при работе с кэшем ответов",
+	},
+	[]string{"route"},
+)
+
+// VersionRequests — счётчик запросов по версиям API. По нему видно,
+// когда устаревшей версией перестали пользоваться и её можно удалить.
+//
+// Метрика: gateway_api_version_requests_total
+// Labels:
+// - route: имя маршрута из таблицы
+// - version: версия API маршрута (unversioned, если не указана)
+// - deprecated: true, если версия объявлена устаревшей
+var VersionRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_api_version_requests_total",
+		Help: "Количество запросов по версиям API",
+	},
+	[]string{"route", "version", "deprecated"},
+)
+
+// OpenAPIFetchErrors — счётчик ошибок загрузки OpenAPI документов сервисов
+// для общего каталога. Каталог при этом отдаётся без документа маршрута.
+//
+// Метрика: gateway_openapi_fetch_errors_total
+// Labels:
+// - route: имя маршрута из таблицы
+var OpenAPIFetchErrors = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_openapi_fetch_errors_total",
+		Help: "Количество ошибок загрузки OpenAPI документов сервисов",
+	},
+	[]string{"route"},
+)
+
+// CompositionSections — счётчик секций составных эндпоинтов по исходу.
+//
+// Метрика: gateway_composition_sections_total
+// Labels:
+// - composition: имя составного эндпоинта
+// - section: имя секции
+// - result: ok, absent (пользователь не состоит в модуле) или error
+var CompositionSections = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_composition_sections_total",
+		Help: "Количество секций составных эндпоинтов по исходу",
+	},
+	[]string{"composition", "section", "result"},
+)
+
+// VariantRequests — счётчик запросов маршрутов с canary по вариантам.
+// Сравнение долей 5xx по вариантам показывает, можно ли переключать трафик.
+//
+// Метрика: gateway_variant_requests_total
+// Labels:
+// - route: имя маршрута из таблицы
+// - variant: stable или имя варианта
+// - status: класс кода ответа (2xx, 3xx, 4xx, 5xx)
+var VariantRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_variant_requests_total",
+		Help: "Количество запросов по вариантам canary",
+	},
+	[]string{"route", "variant", "status"},
+)
+
+// VariantWeight — текущий вес варианта в процентах пользователей
+// (с учётом изменений через admin API).
+//
+// Метрика: gateway_variant_weight
+// Labels:
+// - route: имя маршрута из таблицы
+// - variant: stable или имя варианта
+var VariantWeight = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "gateway_variant_weight",
+		Help: "Вес варианта canary в процентах пользователей",
+	},
+	[]string{"route", "variant"},
+)
+
+// Maintenance — маршруты в режиме обслуживания.
+//
+// Метрика: gateway_maintenance
+// Labels:
+// - route: имя маршрута из таблицы
+// Значения: 1 — маршрут отвечает 503
+var Maintenance = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "gateway_maintenance",
+		Help: "Маршрут в режиме обслуживания (1 — включён)",
+	},
+	[]string{"route"},
+)
+
+// IdempotencyRequests — запросы с заголовком Idempotency-Key.
+//
+// Метрика: gateway_idempotency_requests_total
+// Labels:
+// - route: имя маршрута из таблицы
+// - result: stored, replayed, conflict (запрос ещё выполняется), mismatch (ключ от другого запроса), released (ответ не сохранён) или error
+var IdempotencyRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_idempotency_requests_total",
+		Help: "Количество запросов с Idempotency-Key по результату",
+	},
+	[]string{"route", "result"},
+)
+
+// ShadowRequests — запросы, скопированные в теневой upstream, по итогу сравнения.
+//
+// Метрика: gateway_shadow_requests_total
+// Labels:
+// - route: имя маршрута из таблицы
+// - result: match, status_mismatch, body_mismatch, error (теневой запрос не удался), too_large (тело больше предела) или dropped (слишком много теневых запросов)
+var ShadowRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gateway_shadow_requests_total",
+		Help: "Количество теневых запросов по итогу сравнения с основным ответом",
+	},
+	[]string{"route", "result"},
+)
+
+func init() {
+	// Регистрируем метрики в Prometheus, чтобы они были доступны для сбора
+	prometheus.MustRegister(Requests)
+	prometheus.MustRegister(RequestDuration)
+	prometheus.MustRegister(RequestsInFlight)
+	prometheus.MustRegister(UpstreamInFlight)
+	prometheus.MustRegister(RateLimitDecisions)
+	prometheus.MustRegister(RateLimitErrors)
+	prometheus.MustRegister(CircuitState)
+	prometheus.MustRegister(CircuitTransitions)
+	prometheus.MustRegister(CircuitRejected)
+	prometheus.MustRegister(UpstreamRetries)
+	prometheus.MustRegister(StreamsActive)
+	prometheus.MustRegister(StreamsRejected)
+	prometheus.MustRegister(CacheRequests)
+	prometheus.MustRegister(CacheErrors)
+	prometheus.MustRegister(VersionRequests)
+	prometheus.MustRegister(OpenAPIFetchErrors)
+	prometheus.MustRegister(CompositionSections)
+	prometheus.MustRegister(VariantRequests)
+	prometheus.MustRegister(VariantWeight)
+	prometheus.MustRegister(Maintenance)
+	prometheus.MustRegister(IdempotencyRequests)
+	prometheus.MustRegister(ShadowRequests)
+}
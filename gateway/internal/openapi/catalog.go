@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/upstream"
+
+	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/tracing"
+)
+
+const (
+	// TTL — сколько каталог отдаётся из памяти, прежде чем документы
+	// сервисов будут загружены заново.
+	TTL = time.Minute
+
+	// partialTTL — время жизни каталога, в котором не хватает документов:
+	// недоступный сервис попадёт в каталог вскоре после восстановления.
+	partialTTL = 10 * time.Second
+
+	fetchTimeout = 5 * time.Second
+	maxSpecSize  = 4 << 20
+)
+
+// Catalog собирает общий OpenAPI документ из документов сервисов маршрутов
+// с полем openapi. Документ собирается при первом запросе и кэшируется на TTL.
+// Если документ сервиса не загрузился, каталог отдаётся без него.
+type Catalog struct {
+	routes []routes.Route
+	pools  map[string]*upstream.Pool
+	client *http.Client
+	logger *log.Logger
+
+	mu      sync.Mutex
+	doc     []byte
+	expires time.Time
+}
+
+// New создаёт каталог для таблицы маршрутов. Документы загружаются
+// с экземпляров, которые пул считает здоровыми; без пула (pools == nil) —
+// с первого upstream маршрута.
+func New(table *routes.Table, pools map[string]*upstream.Pool, logger *log.Logger) *Catalog {
+	c := &Catalog{
+		pools:  pools,
+		logger: logger,
+		client: &http.Client{
+			Timeout:   fetchTimeout,
+			Transport: tracing.Transport(http.DefaultTransport),
+		},
+	}
+	for _, route := range table.Routes {
+		if route.OpenAPI != "" {
+			c.routes = append(c.routes, route)
+		}
+	}
+	return c
+}
+
+// Handler отдаёт общий OpenAPI документ.
+func (c *Catalog) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		doc, err := c.Document(r.Context())
+		if err != nil {
+			apierror.Write(w, http.StatusInternalServerError, "ошибка сборки каталога API")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(doc)
+	}
+}
+
+// Document возвращает общий документ в JSON, собирая его заново,
+// если кэш устарел.
+func (c *Catalog) Document(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.doc != nil && time.Now().Before(c.expires) {
+		return c.doc, nil
+	}
+
+	// Сборку не прерывает отмена запроса, который её начал: результат нужен всем
+	doc, problems := c.Build(context.WithoutCancel(ctx))
+	for _, p := range problems {
+		c.logger.Warn("⚠️ Каталог API: %s", p)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := TTL
+	if len(doc.Unavailable) > 0 {
+		ttl = partialTTL
+	}
+	c.doc, c.expires = data, time.Now().Add(ttl)
+	return data, nil
+}
+
+// Build загружает документы всех сервисов параллельно и объединяет их
+// в порядке таблицы маршрутов. Возвращает каталог и список проблем:
+// недоступные документы и операции, описанные в нескольких маршрутах.
+func (c *Catalog) Build(ctx context.Context) (*Document, []string) {
+	specs := make([]*spec, len(c.routes))
+	errs := make([]error, len(c.routes))
+
+	var wg sync.WaitGroup
+	for i, route := range c.routes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			specs[i], errs[i] = c.fetch(ctx, route)
+		}()
+	}
+	wg.Wait()
+
+	doc := newDocument()
+	owners := map[string]string{}
+	var problems []string
+
+	for i, route := range c.routes {
+		if errs[i] != nil {
+			metrics.OpenAPIFetchErrors.WithLabelValues(route.Name).Inc()
+			doc.Unavailable = append(doc.Unavailable, route.Name)
+			problems = append(problems, fmt.Sprintf("документ маршрута %s недоступен: %v", route.Name, errs[i]))
+			continue
+		}
+		problems = append(problems, doc.add(route, specs[i], owners)...)
+	}
+	return doc, problems
+}
+
+// fetch загружает и разбирает документ сервиса маршрута.
+func (c *Catalog) fetch(ctx context.Context, route routes.Route) (*spec, error) {
+	target, err := c.target(route)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s ответил %d", target, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSpecSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxSpecSize {
+		return nil, fmt.Errorf("%s: документ больше %d байт", target, maxSpecSize)
+	}
+
+	var s spec
+	if err := json.Unmarshal(body, &s); err != nil {
+		return nil, fmt.Errorf("%s: некорректный JSON: %w", target, err)
+	}
+	if s.Swagger != "2.0" {
+		return nil, fmt.Errorf("%s: поддерживается только Swagger 2.0", target)
+	}
+	return &s, nil
+}
+
+// target возвращает адрес документа на экземпляре маршрута.
+func (c *Catalog) target(route routes.Route) (string, error) {
+	var base *url.URL
+	if pool := c.pools[route.Name]; pool != nil {
+		inst, err := pool.Pick()
+		if err != nil {
+			return "", err
+		}
+		base = inst.URL
+	} else {
+		base = route.UpstreamURLs()[0]
+	}
+	return strings.TrimSuffix(base.String(), "/") + route.OpenAPI, nil
+}
@@ -0,0 +1,221 @@
+package openapi
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"vira-gateway/internal/routes"
+)
+
+// spec — OpenAPI (Swagger 2.0) документ сервиса в том виде, в каком его
+// генерирует swag. Операции и схемы не разбираются, а переносятся как есть.
+type spec struct {
+	Swagger             string                    `json:"swagger"`
+	Info                map[string]any            `json:"info"`
+	BasePath            string                    `json:"basePath"`
+	Paths               map[string]map[string]any `json:"paths"`
+	Definitions         map[string]any            `json:"definitions"`
+	SecurityDefinitions map[string]any            `json:"securityDefinitions"`
+}
+
+// Document — общий каталог API, собранный из документов сервисов.
+// Пути в нём публичные (с префиксами маршрутов gateway).
+type Document struct {
+	Swagger             string                    `json:"swagger"`
+	Info                map[string]any            `json:"info"`
+	BasePath            string                    `json:"basePath"`
+	Tags                []Tag                     `json:"tags"`
+	Paths               map[string]map[string]any `json:"paths"`
+	Definitions         map[string]any            `json:"definitions"`
+	SecurityDefinitions map[string]any            `json:"securityDefinitions,omitempty"`
+	Unavailable         []string                  `json:"x-unavailable,omitempty"` // Маршруты, документы которых не загрузились
+}
+
+// Tag — группа операций в каталоге.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Методы операций Swagger 2.0. Остальные ключи элемента paths
+// (parameters, $ref) переносятся без изменений.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+func newDocument() *Document {
+	return &Document{
+		Swagger: "2.0",
+		Info: map[string]any{
+			"title":       "Vira API",
+			"version":     "1.0",
+			"description": "Общий каталог API сервисов Vira. Пути указаны с префиксами gateway.",
+		},
+		BasePath:    "/",
+		Paths:       map[string]map[string]any{},
+		Definitions: map[string]any{},
+	}
+}
+
+// add переносит документ сервиса в каталог под маршрутом route.
+// Возвращает описания конфликтов: операций, уже описанных другим маршрутом.
+func (d *Document) add(route routes.Route, s *spec, owners map[string]string) []string {
+	var conflicts []string
+
+	for name, def := range s.Definitions {
+		d.Definitions[route.Name+"."+name] = renameRefs(def, route.Name)
+	}
+	for name, def := range s.SecurityDefinitions {
+		if _, ok := d.SecurityDefinitions[name]; ok {
+			continue
+		}
+		if d.SecurityDefinitions == nil {
+			d.SecurityDefinitions = map[string]any{}
+		}
+		d.SecurityDefinitions[name] = def
+	}
+
+	tags := map[string]bool{}
+	for path, item := range s.Paths {
+		public, ok := publicPath(route, joinPath(s.BasePath, path))
+		if !ok {
+			continue
+		}
+
+		for key, value := range item {
+			if !slices.Contains(methods, key) {
+				if d.Paths[public] == nil {
+					d.Paths[public] = map[string]any{}
+				}
+				d.Paths[public][key] = renameRefs(value, route.Name)
+				continue
+			}
+
+			op := key + " " + public
+			if owner, ok := owners[op]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("%s %s описан в маршрутах %s и %s", strings.ToUpper(key), public, owner, route.Name))
+				continue
+			}
+			owners[op] = route.Name
+
+			operation, _ := renameRefs(value, route.Name).(map[string]any)
+			if operation == nil {
+				continue
+			}
+			for _, tag := range operationTags(route, operation) {
+				tags[tag] = true
+			}
+			if id, ok := operation["operationId"].(string); ok && id != "" {
+				operation["operationId"] = route.Name + "." + id
+			}
+			if route.Deprecation.Deprecated() {
+				operation["deprecated"] = true
+			}
+
+			if d.Paths[public] == nil {
+				d.Paths[public] = map[string]any{}
+			}
+			d.Paths[public][key] = operation
+		}
+	}
+
+	title, _ := s.Info["title"].(string)
+	for _, name := range slices.Sorted(maps.Keys(tags)) {
+		d.Tags = append(d.Tags, Tag{Name: name, Description: title})
+	}
+	return conflicts
+}
+
+// operationTags заменяет теги операции на теги с именем маршрута
+// (auth → id: auth), чтобы одинаковые теги разных сервисов и версий
+// не смешивались. Операция без тегов попадает в тег маршрута.
+func operationTags(route routes.Route, operation map[string]any) []string {
+	raw, _ := operation["tags"].([]any)
+	tags := make([]string, 0, len(raw))
+	for _, t := range raw {
+		if s, ok := t.(string); ok && s != "" {
+			tags = append(tags, route.Name+": "+s)
+		}
+	}
+	if len(tags) == 0 {
+		tags = append(tags, route.Name)
+	}
+
+	list := make([]any, len(tags))
+	for i, t := range tags {
+		list[i] = t
+	}
+	operation["tags"] = list
+	return tags
+}
+
+// publicPath переводит путь upstream в публичный путь gateway по правилам
+// маршрута. Возвращает false, если путь через маршрут недоступен.
+func publicPath(route routes.Route, upstream string) (string, bool) {
+	switch {
+	case route.Rewrite != "":
+		rest, ok := cutPathPrefix(upstream, route.Rewrite)
+		if !ok {
+			return "", false
+		}
+		return route.Prefix + rest, true
+	case route.StripPrefix:
+		if upstream == "/" {
+			return route.Prefix, true
+		}
+		return route.Prefix + upstream, true
+	default:
+		if _, ok := cutPathPrefix(upstream, route.Prefix); !ok {
+			return "", false
+		}
+		return upstream, true
+	}
+}
+
+// cutPathPrefix отрезает prefix, только если он совпадает с целыми сегментами пути:
+// /v1/x под /v1 — да, /v10/x — нет.
+func cutPathPrefix(path, prefix string) (string, bool) {
+	if prefix == "/" {
+		return path, true
+	}
+	rest, ok := strings.CutPrefix(path, prefix)
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+		return "", false
+	}
+	return rest, true
+}
+
+func joinPath(base, path string) string {
+	base = strings.TrimSuffix(base, "/")
+	if base == "" {
+		return path
+	}
+	return base + path
+}
+
+// renameRefs копирует значение, заменяя ссылки на схемы сервиса
+// (#/definitions/types.User) ссылками на схемы каталога (#/definitions/id.types.User).
+func renameRefs(v any, route string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			if ref, ok := item.(string); ok && k == "$ref" {
+				if name, ok := strings.CutPrefix(ref, "#/definitions/"); ok {
+					out[k] = "#/definitions/" + route + "." + name
+					continue
+				}
+			}
+			out[k] = renameRefs(item, route)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = renameRefs(item, route)
+		}
+		return out
+	default:
+		return v
+	}
+}
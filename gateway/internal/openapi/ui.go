@@ -0,0 +1,38 @@
+package openapi
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+const (
+	// UIPath — страница каталога API в gateway.
+	UIPath = "/api/docs"
+
+	// DocPath — общий OpenAPI документ.
+	DocPath = UIPath + "/openapi.json"
+
+	// UIPolicy — Content-Security-Policy страницы каталога: скрипты и стили
+	// только свои, запросы «Выполнить» — только к gateway.
+	UIPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; img-src 'self' data:; frame-ancestors 'none'"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// UIHandler отдаёт встроенную страницу каталога (index.html, app.js, style.css).
+// Монтируется на UIPath и UIPath/*.
+func UIHandler() http.Handler {
+	files, _ := fs.Sub(uiFiles, "ui")
+	static := http.StripPrefix(UIPath, http.FileServerFS(files))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == UIPath {
+			http.ServeFileFS(w, r, files, "index.html")
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		static.ServeHTTP(w, r)
+	})
+}
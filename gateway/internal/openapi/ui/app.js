@@ -0,0 +1,242 @@
+// Страница каталога API Vira: читает общий OpenAPI (Swagger 2.0) документ
+// gateway и позволяет выполнить запрос прямо со страницы.
+// Данные документа вставляются только как текст, без innerHTML.
+"use strict";
+
+const DOC_URL = "/api/docs/openapi.json";
+const TOKEN_KEY = "vira-docs-token";
+
+let spec = null;
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key === "class") node.className = value;
+    else if (key.startsWith("on")) node.addEventListener(key.slice(2), value);
+    else node.setAttribute(key, value);
+  }
+  for (const child of children) {
+    if (child === null || child === undefined) continue;
+    node.append(typeof child === "string" ? document.createTextNode(child) : child);
+  }
+  return node;
+}
+
+function resolve(schema) {
+  if (schema && schema.$ref) {
+    const name = schema.$ref.replace("#/definitions/", "");
+    return spec.definitions[name] || {};
+  }
+  return schema || {};
+}
+
+// example строит пример значения по схеме для тела запроса.
+function example(schema, depth) {
+  schema = resolve(schema);
+  if ((depth || 0) > 5) return null;
+  if (schema.example !== undefined) return schema.example;
+  if (schema.allOf) {
+    return Object.assign({}, ...schema.allOf.map((s) => example(s, (depth || 0) + 1)));
+  }
+  switch (schema.type) {
+    case "object": {
+      const out = {};
+      for (const [name, prop] of Object.entries(schema.properties || {})) {
+        out[name] = example(prop, (depth || 0) + 1);
+      }
+      return out;
+    }
+    case "array":
+      return [example(schema.items, (depth || 0) + 1)];
+    case "integer":
+    case "number":
+      return 0;
+    case "boolean":
+      return false;
+    case "string":
+      return "";
+    default:
+      return schema.properties ? example(Object.assign({ type: "object" }, schema), depth) : null;
+  }
+}
+
+function schemaText(schema) {
+  if (!schema) return "";
+  if (schema.$ref) return schema.$ref.replace("#/definitions/", "");
+  if (schema.type === "array") return schemaText(schema.items) + "[]";
+  return schema.type || "";
+}
+
+function renderSchema(schema) {
+  const resolved = resolve(schema);
+  const box = el("div", { class: "schema" }, el("code", {}, schemaText(schema)));
+  const props = resolved.properties || {};
+  if (Object.keys(props).length > 0) {
+    const list = el("ul");
+    for (const [name, prop] of Object.entries(props)) {
+      const required = (resolved.required || []).includes(name);
+      list.append(el("li", {}, el("code", {}, name), " ", el("span", { class: "muted" }, schemaText(prop)), required ? " *" : ""));
+    }
+    box.append(list);
+  }
+  return box;
+}
+
+function renderTry(path, method, op) {
+  const params = op.parameters || [];
+  const inputs = {};
+  const form = el("form", { class: "try" });
+
+  for (const p of params) {
+    if (p.in === "body") {
+      const body = el("textarea", { rows: "8", spellcheck: "false" });
+      body.value = JSON.stringify(example(p.schema), null, 2);
+      inputs[p.name] = { param: p, input: body };
+      form.append(el("label", {}, "Тело запроса", body));
+    } else {
+      const input = el("input", { type: "text", placeholder: p.type || "" });
+      inputs[p.name] = { param: p, input };
+      form.append(el("label", {}, p.name + " (" + p.in + ")" + (p.required ? " *" : ""), input));
+    }
+  }
+
+  const output = el("pre", { class: "response", hidden: "" });
+  form.append(el("button", { type: "submit" }, "Выполнить"), output);
+
+  form.addEventListener("submit", async (event) => {
+    event.preventDefault();
+    let url = path;
+    const query = new URLSearchParams();
+    const headers = {};
+    let body;
+
+    for (const { param, input } of Object.values(inputs)) {
+      const value = input.value;
+      if (value === "" && param.in !== "body") continue;
+      switch (param.in) {
+        case "path":
+          url = url.replace("{" + param.name + "}", encodeURIComponent(value));
+          break;
+        case "query":
+          query.append(param.name, value);
+          break;
+        case "header":
+          headers[param.name] = value;
+          break;
+        case "body":
+          body = value;
+          headers["Content-Type"] = "application/json";
+          break;
+      }
+    }
+
+    const token = document.getElementById("token").value.trim();
+    if (token && !headers.Authorization) {
+      headers.Authorization = token.startsWith("Bearer ") ? token : "Bearer " + token;
+    }
+    if (query.toString()) url += "?" + query.toString();
+
+    output.hidden = false;
+    output.textContent = method.toUpperCase() + " " + url + "\n…";
+    try {
+      const resp = await fetch(url, { method: method.toUpperCase(), headers, body });
+      let text = await resp.text();
+      try {
+        text = JSON.stringify(JSON.parse(text), null, 2);
+      } catch (_) {
+        // ответ не JSON — показываем как есть
+      }
+      output.textContent = method.toUpperCase() + " " + url + "\n" + resp.status + " " + resp.statusText + "\n\n" + text;
+    } catch (err) {
+      output.textContent = method.toUpperCase() + " " + url + "\nОшибка: " + err.message;
+    }
+  });
+
+  return form;
+}
+
+function renderOperation(path, method, op) {
+  const details = el("details", { class: "operation " + method + (op.deprecated ? " deprecated" : "") });
+  details.append(el("summary", {},
+    el("span", { class: "method" }, method.toUpperCase()),
+    el("code", { class: "path" }, path),
+    el("span", { class: "summary" }, op.summary || ""),
+    op.deprecated ? el("span", { class: "badge" }, "устарело") : null,
+    (op.security || []).length > 0 ? el("span", { class: "badge" }, "токен") : null,
+  ));
+
+  if (op.description) details.append(el("p", {}, op.description));
+
+  const params = (op.parameters || []).filter((p) => p.in !== "body");
+  if (params.length > 0) {
+    const table = el("table", {}, el("tr", {}, el("th", {}, "Параметр"), el("th", {}, "Где"), el("th", {}, "Тип"), el("th", {}, "Описание")));
+    for (const p of params) {
+      table.append(el("tr", {}, el("td", {}, el("code", {}, p.name + (p.required ? " *" : ""))), el("td", {}, p.in), el("td", {}, p.type || ""), el("td", {}, p.description || "")));
+    }
+    details.append(el("h4", {}, "Параметры"), table);
+  }
+
+  const bodyParam = (op.parameters || []).find((p) => p.in === "body");
+  if (bodyParam) details.append(el("h4", {}, "Тело запроса"), renderSchema(bodyParam.schema));
+
+  const responses = el("ul", { class: "responses" });
+  for (const [code, resp] of Object.entries(op.responses || {})) {
+    responses.append(el("li", {}, el("code", {}, code), " ", resp.description || "", resp.schema ? renderSchema(resp.schema) : null));
+  }
+  details.append(el("h4", {}, "Ответы"), responses, el("h4", {}, "Попробовать"), renderTry(path, method, op));
+  return details;
+}
+
+function render() {
+  document.getElementById("title").textContent = spec.info.title;
+  document.getElementById("description").textContent = spec.info.description || "";
+
+  if ((spec["x-unavailable"] || []).length > 0) {
+    const warning = document.getElementById("unavailable");
+    warning.hidden = false;
+    warning.textContent = "Нет документов маршрутов: " + spec["x-unavailable"].join(", ");
+  }
+
+  const byTag = new Map((spec.tags || []).map((t) => [t.name, []]));
+  for (const [path, item] of Object.entries(spec.paths).sort(([a], [b]) => a.localeCompare(b))) {
+    for (const [method, op] of Object.entries(item)) {
+      if (method === "parameters") continue;
+      for (const tag of op.tags || ["—"]) {
+        if (!byTag.has(tag)) byTag.set(tag, []);
+        byTag.get(tag).push([path, method, op]);
+      }
+    }
+  }
+
+  const nav = document.getElementById("tags");
+  const main = document.getElementById("operations");
+  main.replaceChildren();
+  let i = 0;
+  for (const [tag, ops] of byTag) {
+    if (ops.length === 0) continue;
+    const id = "tag-" + i++;
+    const description = (spec.tags || []).find((t) => t.name === tag);
+    nav.append(el("a", { href: "#" + id }, tag));
+    const section = el("section", { id }, el("h2", {}, tag), description && description.description ? el("p", { class: "muted" }, description.description) : null);
+    for (const [path, method, op] of ops) section.append(renderOperation(path, method, op));
+    main.append(section);
+  }
+  if (i === 0) main.append(el("p", { class: "muted" }, "В каталоге нет операций."));
+}
+
+async function load() {
+  const token = document.getElementById("token");
+  token.value = sessionStorage.getItem(TOKEN_KEY) || "";
+  token.addEventListener("change", () => sessionStorage.setItem(TOKEN_KEY, token.value.trim()));
+
+  try {
+    const resp = await fetch(DOC_URL);
+    if (!resp.ok) throw new Error(resp.status + " " + resp.statusText);
+    spec = await resp.json();
+    render();
+  } catch (err) {
+    document.getElementById("operations").replaceChildren(el("p", { class: "warning" }, "Не удалось загрузить каталог: " + err.message));
+  }
+}
+
+load();
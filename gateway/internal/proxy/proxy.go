@@ -1,46 +1,138 @@
 package proxy
 
 import (
-	"log"
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
+	"time"
 
-	middleware "github.com/skrolikov/vira-middleware"
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/breaker"
+	"vira-gateway/internal/clientip"
+	"vira-gateway/internal/identity"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/stream"
+	"vira-gateway/internal/upstream"
+
+	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/tracing"
 )
 
-func Proxy(target string) http.HandlerFunc {
-	url, err := url.Parse(target)
-	if err != nil {
-		log.Printf("[Proxy] Ошибка парсинга target URL '%s': %v", target, err)
-		return func(w http.ResponseWriter, r *http.Request) {
-			http.Error(w, "Неверный адрес прокси", http.StatusInternalServerError)
-		}
-	}
+// statusClientClosed — код для access-лога, когда клиент ушёл, не дождавшись
+// ответа (как 499 в nginx). Клиенту он уже не доставляется.
+const statusClientClosed = 499
 
-	proxy := httputil.NewSingleHostReverseProxy(url)
+// Proxy проксирует запросы маршрута на экземпляры пула.
+// Выбор экземпляра, circuit breaker и повторы выполняет poolTransport;
+// весь запрос вместе с повторами ограничен таймаутом маршрута.
+//
+// WebSocket и SSE проксируются, если на маршруте включены streams: таймаут
+// маршрута на них не действует, поток закрывается при простое, а число
+// открытых соединений ограничено на пользователя (анонимных — на IP).
+func Proxy(pool *upstream.Pool, route routes.Route, streams *stream.Counter, ips *clientip.Resolver, signer *identity.Signer, logger *log.Logger) http.HandlerFunc {
+	timeout := time.Duration(route.Timeout)
+	streamCfg := route.Streams.WithDefaults()
+
+	proxy := &httputil.ReverseProxy{
+		// Адрес экземпляра подставляет poolTransport
+		Director: func(*http.Request) {},
+		Transport: &poolTransport{
+			pool:  pool,
+			retry: route.Retry.WithDefaults(),
+			base:  tracing.Transport(baseTransport),
+		},
+	}
 
 	proxy.ModifyResponse = func(resp *http.Response) error {
 		req := resp.Request
-		if req != nil {
-			log.Printf("[Proxy] Ответ от %s %s: статус %d", req.Method, req.URL.Path, resp.StatusCode)
-		} else {
-			log.Printf("[Proxy] Ответ с неизвестным запросом: статус %d", resp.StatusCode)
-		}
+		logger.WithContext(req.Context()).Debug("Ответ от %s %s %s: статус %d", req.URL.Host, req.Method, req.URL.Path, resp.StatusCode)
 		return nil
 	}
 
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		l := logger.WithContext(r.Context())
+
+		switch {
+		case errors.Is(err, context.Canceled):
+			l.Debug("Клиент отменил запрос к %s: %v", pool.Name, err)
+			w.WriteHeader(statusClientClosed)
+		case errors.Is(err, breaker.ErrOpen):
+			// Цепь разомкнута — отвечаем сразу, не дожидаясь таймаута
+			apierror.Write(w, http.StatusServiceUnavailable, "сервис временно недоступен")
+		case errors.Is(err, upstream.ErrNoHealthy):
+			l.Warn("Пул %s: %v", pool.Name, err)
+			apierror.Write(w, http.StatusServiceUnavailable, "сервис временно недоступен")
+		case errors.Is(err, context.DeadlineExceeded):
+			l.Warn("Таймаут запроса к %s: %v", pool.Name, err)
+			apierror.Write(w, http.StatusGatewayTimeout, "сервис не ответил вовремя")
+		default:
+			l.Error("Ошибка запроса к %s: %v", pool.Name, err)
+			apierror.Write(w, http.StatusBadGateway, "сервис недоступен")
+		}
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("[Proxy] Входящий запрос: %s %s", r.Method, r.URL.String())
+		// Личность передаётся только если её проверил auth.Middleware
+		auth.StripHeaders(r.Header)
+		if id, ok := auth.FromContext(r.Context()); ok {
+			auth.SetHeaders(r.Header, id)
+		}
+		signer.Sign(r.Context(), r.Header, ips.IP(r))
 
-		userID := middleware.GetUserID(r)
-		if userID != "" {
-			r.Header.Set("X-User-ID", userID)
-			log.Printf("[Proxy] Добавлен заголовок X-User-ID: %s", userID)
+		if kind := stream.Detect(r); kind != stream.None {
+			if streamCfg.Enabled {
+				serveStream(w, r, proxy, kind, route.Name, streamCfg, streams, ips, logger)
+				return
+			}
+			if kind == stream.WebSocket {
+				apierror.Write(w, http.StatusBadRequest, "WebSocket не поддерживается на этом маршруте")
+				return
+			}
 		}
 
-		log.Printf("[Proxy] Проксирование на: %s%s", url.Host, r.URL.Path)
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
 
 		proxy.ServeHTTP(w, r)
 	}
 }
+
+// serveStream проксирует долгоживущее соединение.
+func serveStream(w http.ResponseWriter, r *http.Request, proxy *httputil.ReverseProxy, kind stream.Kind,
+	routeName string, cfg routes.Streams, streams *stream.Counter, ips *clientip.Resolver, logger *log.Logger) {
+	key := routeName + ":ip:" + ips.IP(r)
+	if id, ok := auth.FromContext(r.Context()); ok {
+		key = routeName + ":" + id.UserID
+	}
+
+	release, ok := streams.Acquire(key, cfg.MaxPerUser)
+	if !ok {
+		metrics.StreamsRejected.WithLabelValues(routeName, string(kind)).Inc()
+		logger.WithContext(r.Context()).Warn("Превышен предел соединений %s (%d)", kind, cfg.MaxPerUser)
+		apierror.Write(w, http.StatusTooManyRequests, "слишком много открытых соединений")
+		return
+	}
+	defer release()
+
+	active := metrics.StreamsActive.WithLabelValues(routeName, string(kind))
+	active.Inc()
+	defer active.Dec()
+
+	// Дедлайн записи сервера, если он задан, рассчитан на обычные запросы, а не на потоки
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	ctx := stream.WithIdleTimeout(r.Context(), time.Duration(cfg.IdleTimeout))
+	if cfg.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.MaxDuration))
+		defer cancel()
+	}
+
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"vira-gateway/internal/accesslog"
+	"vira-gateway/internal/breaker"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/stream"
+	"vira-gateway/internal/upstream"
+)
+
+// baseTransport — общий транспорт gateway. В отличие от http.DefaultTransport
+// ограничивает установку соединения и ожидание заголовков ответа,
+// чтобы зависший upstream не держал запросы бесконечно.
+var baseTransport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout:   3 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          200,
+	MaxIdleConnsPerHost:   50,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ExpectContinueTimeout: time.Second,
+	ResponseHeaderTimeout: routes.DefaultTimeout,
+}
+
+// poolTransport отправляет запрос на экземпляр пула с учётом circuit breaker-а
+// и повторяет идемпотентные запросы на другом экземпляре.
+// Повтор возможен, пока ответ ещё не передан клиенту, поэтому он сделан
+// на уровне RoundTripper, а не обработчика.
+type poolTransport struct {
+	pool  *upstream.Pool
+	retry routes.Retry
+	base  http.RoundTripper
+}
+
+func (t *poolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if retryable(req) {
+		attempts += t.retry.Attempts
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.try(req)
+
+		last := attempt == attempts-1
+		if last || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		metrics.UpstreamRetries.WithLabelValues(t.pool.Name).Inc()
+		if err := sleep(req.Context(), t.backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// try делает одну попытку: выбирает экземпляр, спрашивает автомат
+// и учитывает исход в пуле и автомате.
+func (t *poolTransport) try(req *http.Request) (*http.Response, error) {
+	inst, err := t.pool.Pick()
+	if err != nil {
+		return nil, err
+	}
+	permit, ok := t.pool.Breaker.Allow()
+	if !ok {
+		metrics.CircuitRejected.WithLabelValues(t.pool.Name).Inc()
+		return nil, breaker.ErrOpen
+	}
+
+	out := req.WithContext(req.Context())
+	out.URL = target(inst.URL, req.URL)
+
+	accesslog.SetUpstream(req.Context(), inst.URL.Host)
+	inFlight := metrics.UpstreamInFlight.WithLabelValues(t.pool.Name, inst.URL.Host)
+	inFlight.Inc()
+	inst.Acquire()
+	release := func() {
+		inst.Release()
+		inFlight.Dec()
+	}
+
+	resp, err := t.base.RoundTrip(out)
+
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+		// Клиент ушёл сам — upstream тут ни при чём
+		t.pool.Breaker.Done(permit, breaker.Ignored)
+	case err != nil:
+		t.pool.Report(inst, err)
+		t.pool.Breaker.Done(permit, breaker.Failure)
+	case resp.StatusCode >= 500:
+		t.pool.Report(inst, fmt.Errorf("статус %d", resp.StatusCode))
+		t.pool.Breaker.Done(permit, breaker.Failure)
+	default:
+		t.pool.Report(inst, nil)
+		t.pool.Breaker.Done(permit, breaker.Success)
+	}
+
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	// Поток закрывается при простое; для WebSocket тело — соединение с upstream
+	if d := stream.IdleTimeout(req.Context()); d > 0 {
+		resp.Body = stream.IdleBody(resp.Body, d)
+	}
+
+	// Экземпляр занят, пока тело ответа не дочитано (или соединение не закрыто)
+	resp.Body = withRelease(resp.Body, release)
+	return resp, nil
+}
+
+// backoff возвращает паузу перед повтором номер attempt+1:
+// случайную величину до Backoff*2^attempt, но не больше MaxBackoff.
+func (t *poolTransport) backoff(attempt int) time.Duration {
+	ceil := time.Duration(t.retry.Backoff) << attempt
+	if limit := time.Duration(t.retry.MaxBackoff); ceil <= 0 || ceil > limit {
+		ceil = limit
+	}
+	if ceil <= 0 {
+		return 0
+	}
+	return rand.N(ceil)
+}
+
+// retryable сообщает, можно ли безопасно повторить запрос:
+// метод идемпотентен и тело не нужно перечитывать.
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody
+}
+
+// shouldRetry сообщает, стоит ли повторять попытку с таким исходом.
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		// Повтор не поможет: цепь разомкнута или экземпляров нет
+		return !errors.Is(err, breaker.ErrOpen) && !errors.Is(err, upstream.ErrNoHealthy)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// target строит адрес запроса к экземпляру так же, как
+// httputil.NewSingleHostReverseProxy: путь экземпляра + путь запроса.
+func target(base, in *url.URL) *url.URL {
+	u := *in
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	if base.Path != "" {
+		u.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(in.Path, "/")
+		u.RawPath = ""
+	}
+	switch {
+	case base.RawQuery == "":
+	case in.RawQuery == "":
+		u.RawQuery = base.RawQuery
+	default:
+		u.RawQuery = base.RawQuery + "&" + in.RawQuery
+	}
+	return &u
+}
+
+// withRelease вызывает release при закрытии тела ответа.
+// Тело ответа 101 Switching Protocols остаётся доступным для записи,
+// иначе ReverseProxy не сможет проксировать WebSocket.
+func withRelease(body io.ReadCloser, release func()) io.ReadCloser {
+	rb := &releaseBody{ReadCloser: body, release: release}
+	if w, ok := body.(io.Writer); ok {
+		return &releaseRWBody{releaseBody: rb, w: w}
+	}
+	return rb
+}
+
+// releaseBody освобождает экземпляр при закрытии тела ответа.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+type releaseRWBody struct {
+	*releaseBody
+	w io.Writer
+}
+
+func (b *releaseRWBody) Write(p []byte) (int, error) {
+	return b.w.Write(p)
+}
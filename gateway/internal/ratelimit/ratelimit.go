@@ -0,0 +1,192 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vira-gateway/internal/apierror"
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/clientip"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+// HeaderAPIKey — заголовок с API ключом клиента для лимитов по api_key.
+// Gateway ключ не проверяет: лимит по api_key делит квоту между клиентами,
+// но не защищает маршрут — клиент может прислать любое значение.
+const HeaderAPIKey = "X-API-Key"
+
+// slidingWindow — атомарная проверка скользящего окна на sorted set.
+// Время берётся из Redis, чтобы реплики gateway с разными часами
+// считали одинаково.
+//
+// KEYS[1] — ключ окна; ARGV: окно (мс), лимит, уникальный ID запроса.
+// Возвращает {allowed, count, retry_after_ms}.
+var slidingWindow = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return {1, count + 1, 0}
+end
+
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local retry = window
+if oldest[2] then
+	retry = window - (now - tonumber(oldest[2]))
+end
+return {0, count, retry}
+`)
+
+// Result — итог проверки одного правила.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // Через сколько освободится место в окне
+}
+
+// Limiter — rate limiter со счётчиками в Redis, общими для всех реплик gateway.
+type Limiter struct {
+	rdb    *redis.Client
+	logger *log.Logger
+}
+
+// New создаёт Limiter.
+func New(rdb *redis.Client, logger *log.Logger) *Limiter {
+	return &Limiter{rdb: rdb, logger: logger}
+}
+
+// Allow учитывает запрос в окне key и сообщает, укладывается ли он в лимит.
+func (l *Limiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	res, err := slidingWindow.Run(ctx, l.rdb, []string{key},
+		window.Milliseconds(), limit, uuid.NewString(),
+	).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("ошибка проверки лимита: %w", err)
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  max(limit-int(res[1]), 0),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}
+
+// Middleware применяет правила маршрута к каждому запросу.
+// Правило пропускается, если у запроса нет значения признака
+// (анонимный запрос для user, нет заголовка для api_key).
+// Если Redis недоступен, запрос пропускается, чтобы gateway не падал вместе с ним.
+func (l *Limiter) Middleware(route string, rules []routes.RateLimit, ips *clientip.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(rules) == 0 {
+			return next
+		}
+
+		ids := make([]string, len(rules))
+		for i, rule := range rules {
+			ids[i] = ruleID(rule)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var tightest *Result
+
+			for i, rule := range rules {
+				if !rule.Matches(r.URL.Path) {
+					continue
+				}
+				subject := identify(r, rule.Key, ips)
+				if subject == "" {
+					continue
+				}
+
+				window := time.Duration(rule.Window)
+				key := fmt.Sprintf("ratelimit:%s:%s:%s:%s", route, ids[i], rule.Key, subject)
+
+				res, err := l.Allow(r.Context(), key, rule.Limit, window)
+				if err != nil {
+					metrics.RateLimitErrors.WithLabelValues(route).Inc()
+					l.logger.WithContext(r.Context()).Warn("Rate limit: %v", err)
+					continue
+				}
+
+				if !res.Allowed {
+					metrics.RateLimitDecisions.WithLabelValues(route, string(rule.Key), "limited").Inc()
+					setHeaders(w.Header(), res)
+					w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+					apierror.Write(w, http.StatusTooManyRequests, "слишком много запросов, попробуйте позже")
+					return
+				}
+
+				metrics.RateLimitDecisions.WithLabelValues(route, string(rule.Key), "allowed").Inc()
+				if tightest == nil || res.Remaining < tightest.Remaining {
+					tightest = &res
+				}
+			}
+
+			if tightest != nil {
+				setHeaders(w.Header(), *tightest)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ruleID — отпечаток правила для ключа окна в Redis. Зависит только
+// от самого правила, поэтому счётчики переживают перезагрузку таблицы,
+// в которой правила переставили или добавили новые, а изменённое правило
+// начинает счёт заново.
+func ruleID(rule routes.RateLimit) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%s",
+		rule.Key, rule.Limit, time.Duration(rule.Window).Milliseconds(), strings.Join(rule.Paths, ","))))
+	return hex.EncodeToString(sum[:8])
+}
+
+// identify возвращает значение признака лимита для запроса.
+func identify(r *http.Request, key routes.RateLimitKey, ips *clientip.Resolver) string {
+	switch key {
+	case routes.LimitByIP:
+		return ips.IP(r)
+	case routes.LimitByUser:
+		if id, ok := auth.FromContext(r.Context()); ok {
+			return id.UserID
+		}
+	case routes.LimitByAPIKey:
+		if k := r.Header.Get(HeaderAPIKey); k != "" {
+			// Сам ключ в Redis не храним
+			sum := sha256.Sum256([]byte(k))
+			return hex.EncodeToString(sum[:16])
+		}
+	}
+	return ""
+}
+
+func setHeaders(h http.Header, res Result) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	if !res.Allowed {
+		h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Max(1, math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/clientip"
+	"vira-gateway/internal/routes"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+// newTestLimiter создаёт Limiter поверх miniredis с остановленными часами.
+func newTestLimiter(t *testing.T) (*Limiter, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Unix(1_700_000_000, 0))
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return New(rdb, log.New(log.Config{})), mr
+}
+
+func TestAllow(t *testing.T) {
+	l, mr := newTestLimiter(t)
+	start := time.Unix(1_700_000_000, 0)
+
+	// Лимит 2 запроса за 10s; шаги идут по порядку с отметкой времени от start
+	tests := []struct {
+		name       string
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{"первый запрос", 0, true, 1, 0},
+		{"второй запрос", time.Second, true, 0, 0},
+		{"сверх лимита", 2 * time.Second, false, 0, 8 * time.Second},
+		{"отказ не занимает место", 9 * time.Second, false, 0, time.Second},
+		{"первый вышел из окна", 10 * time.Second, true, 0, 0},
+		{"второй ещё в окне", 10*time.Second + time.Millisecond, false, 0, 999 * time.Millisecond},
+		{"окно сдвинулось", 11 * time.Second, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr.SetTime(start.Add(tt.at))
+			res, err := l.Allow(context.Background(), "ratelimit:test", 2, 10*time.Second)
+			if err != nil {
+				t.Fatalf("Allow() error = %v", err)
+			}
+			want := Result{Allowed: tt.allowed, Limit: 2, Remaining: tt.remaining, RetryAfter: tt.retryAfter}
+			if res != want {
+				t.Fatalf("Allow() = %+v, want %+v", res, want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	ips, err := clientip.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byIP := routes.RateLimit{Key: routes.LimitByIP, Limit: 1, Window: routes.Duration(time.Minute)}
+	byUser := routes.RateLimit{Key: routes.LimitByUser, Limit: 1, Window: routes.Duration(time.Minute)}
+	login := routes.RateLimit{Key: routes.LimitByIP, Limit: 1, Window: routes.Duration(time.Minute), Paths: []string{"/api/id/login"}}
+
+	// request — запрос с IP ip; userID — пользователь из access токена (пусто — анонимный).
+	type request struct {
+		path   string
+		ip     string
+		userID string
+	}
+
+	tests := []struct {
+		name string
+		// Первый запрос проходит через rules, второй — через next
+		rules, next []routes.RateLimit
+		first       request
+		second      request
+		limited     bool
+	}{
+		{
+			name:  "повтор с того же IP",
+			rules: []routes.RateLimit{byIP}, next: []routes.RateLimit{byIP},
+			first:   request{"/api/id/me", "192.0.2.1", ""},
+			second:  request{"/api/id/me", "192.0.2.1", ""},
+			limited: true,
+		},
+		{
+			name:  "другой IP",
+			rules: []routes.RateLimit{byIP}, next: []routes.RateLimit{byIP},
+			first:  request{"/api/id/me", "192.0.2.1", ""},
+			second: request{"/api/id/me", "192.0.2.2", ""},
+		},
+		{
+			name:  "правило только для своего пути",
+			rules: []routes.RateLimit{login}, next: []routes.RateLimit{login},
+			first:  request{"/api/id/login", "192.0.2.1", ""},
+			second: request{"/api/id/me", "192.0.2.1", ""},
+		},
+		{
+			name:  "анонимный запрос не считается по user",
+			rules: []routes.RateLimit{byUser}, next: []routes.RateLimit{byUser},
+			first:  request{"/api/id/me", "192.0.2.1", ""},
+			second: request{"/api/id/me", "192.0.2.1", ""},
+		},
+		{
+			name:  "user считается по пользователю, а не по IP",
+			rules: []routes.RateLimit{byUser}, next: []routes.RateLimit{byUser},
+			first:   request{"/api/id/me", "192.0.2.1", "u1"},
+			second:  request{"/api/id/me", "192.0.2.2", "u1"},
+			limited: true,
+		},
+		{
+			name:  "счётчик переживает перестановку правил",
+			rules: []routes.RateLimit{byIP, login}, next: []routes.RateLimit{login, byIP},
+			first:   request{"/api/id/me", "192.0.2.1", ""},
+			second:  request{"/api/id/me", "192.0.2.1", ""},
+			limited: true,
+		},
+		{
+			name:  "изменённое правило считает заново",
+			rules: []routes.RateLimit{byIP}, next: []routes.RateLimit{{Key: routes.LimitByIP, Limit: 2, Window: routes.Duration(time.Minute)}},
+			first:  request{"/api/id/me", "192.0.2.1", ""},
+			second: request{"/api/id/me", "192.0.2.1", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := newTestLimiter(t)
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+			serve := func(rules []routes.RateLimit, req request) int {
+				r := httptest.NewRequest(http.MethodGet, req.path, nil)
+				r.RemoteAddr = req.ip + ":40000"
+				if req.userID != "" {
+					r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{UserID: req.userID}))
+				}
+				rec := httptest.NewRecorder()
+				l.Middleware("id", rules, ips)(ok).ServeHTTP(rec, r)
+				return rec.Code
+			}
+
+			if code := serve(tt.rules, tt.first); code != http.StatusOK {
+				t.Fatalf("первый запрос: %d, want 200", code)
+			}
+			want := http.StatusOK
+			if tt.limited {
+				want = http.StatusTooManyRequests
+			}
+			if code := serve(tt.next, tt.second); code != want {
+				t.Fatalf("второй запрос: %d, want %d", code, want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRedisDown(t *testing.T) {
+	l, mr := newTestLimiter(t)
+	mr.Close()
+
+	ips, err := clientip.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []routes.RateLimit{{Key: routes.LimitByIP, Limit: 1, Window: routes.Duration(time.Minute)}}
+	h := l.Middleware("id", rules, ips)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Без Redis запросы пропускаются
+	for range 2 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/id/me", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("запрос без Redis: %d, want 200", rec.Code)
+		}
+	}
+}
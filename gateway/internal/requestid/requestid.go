@@ -0,0 +1,57 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Header — заголовок с ID запроса. Передаётся в upstream и возвращается клиенту.
+const Header = "X-Request-ID"
+
+// ctxKey — ключ ID запроса в контексте. Именно строка "request_id":
+// по ней vira-logger (Logger.WithContext) добавляет ID в поля записи.
+const ctxKey = "request_id"
+
+// maxLen — предел длины ID, пришедшего от клиента.
+const maxLen = 128
+
+// Middleware присваивает запросу ID. ID из заголовка клиента (или nginx)
+// сохраняется, если он разумной длины и из безопасных символов,
+// иначе генерируется новый UUID.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = uuid.NewString()
+		}
+
+		r.Header.Set(Header, id)
+		w.Header().Set(Header, id)
+
+		ctx := context.WithValue(r.Context(), ctxKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromContext возвращает ID текущего запроса или пустую строку.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey).(string)
+	return id
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package respwriter
+
+import "net/http"
+
+// OnHeader возвращает ResponseWriter, который вызывает fn с заголовками
+// ответа непосредственно перед их отправкой клиенту (первый итоговый
+// WriteHeader или Write). Так gateway может поправить заголовки,
+// пришедшие от upstream, а не только добавить свои рядом с ними.
+func OnHeader(w http.ResponseWriter, fn func(http.Header)) http.ResponseWriter {
+	return &hookWriter{ResponseWriter: w, fn: fn}
+}
+
+type hookWriter struct {
+	http.ResponseWriter
+	fn   func(http.Header)
+	done bool
+}
+
+func (w *hookWriter) fire() {
+	if !w.done {
+		w.done = true
+		w.fn(w.ResponseWriter.Header())
+	}
+}
+
+func (w *hookWriter) WriteHeader(status int) {
+	// 1xx кроме 101 — промежуточные ответы, итоговые заголовки будут позже
+	if status >= 200 || status == http.StatusSwitchingProtocols {
+		w.fire()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *hookWriter) Write(b []byte) (int, error) {
+	w.fire()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap даёт http.ResponseController доступ к Flush и Hijack.
+func (w *hookWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,34 +1,274 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"vira-gateway/internal/accesslog"
+	"vira-gateway/internal/apiversion"
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/cache"
+	"vira-gateway/internal/canary"
+	"vira-gateway/internal/clientip"
+	"vira-gateway/internal/compose"
+	"vira-gateway/internal/cors"
+	"vira-gateway/internal/idempotency"
+	"vira-gateway/internal/identity"
+	"vira-gateway/internal/maintenance"
+	"vira-gateway/internal/openapi"
 	"vira-gateway/internal/proxy"
+	"vira-gateway/internal/ratelimit"
+	"vira-gateway/internal/requestid"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/secheaders"
+	"vira-gateway/internal/shadow"
+	"vira-gateway/internal/stream"
+	"vira-gateway/internal/upstream"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 	config "github.com/skrolikov/vira-config"
 	logger "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/tracing"
 )
 
-func Setup(cfg *config.Config, logger *logger.Logger) http.Handler {
+// Router — http.Handler gateway, маршрутизирующий запросы по текущей таблице.
+// Таблицу можно заменить на лету через Reload: запросы, уже попавшие
+// в старый обработчик, дорабатывают на нём.
+type Router struct {
+	cfg     *config.Config
+	logger  *logger.Logger
+	pools   *upstream.Registry
+	limiter *ratelimit.Limiter
+	cache   *cache.Cache
+	ready   func() bool
+	streams *stream.Counter
+	canary  *canary.Weights
+	maint   *maintenance.Switch
+	idem    *idempotency.Store
+	signer  *identity.Signer
+	shadow  *shadow.Mirror
+	handler atomic.Pointer[http.Handler]
+	table   atomic.Pointer[routes.Table]
+}
+
+// Deps — зависимости роутера, которые живут дольше одной таблицы маршрутов.
+type Deps struct {
+	Redis       *redis.Client       // Общие между репликами счётчики rate limit
+	Pools       *upstream.Registry  // Пулы upstream-ов с состоянием здоровья
+	Cache       *cache.Cache        // Кэш ответов (общий с admin API для сброса)
+	Ready       func() bool         // Готовность принимать запросы (false во время остановки)
+	Canary      *canary.Weights     // Веса вариантов canary (общие с admin API)
+	Maintenance *maintenance.Switch // Режим обслуживания маршрутов (общий с admin API)
+	Idempotency *idempotency.Store  // Сохранённые ответы на запросы с Idempotency-Key
+	Signer      *identity.Signer    // Подпись личности для upstream (nil — без подписи)
+	Shadow      *shadow.Mirror      // Копии запросов в теневые upstream-ы
+}
+
+// Setup собирает роутер по таблице маршрутов.
+func Setup(cfg *config.Config, logger *logger.Logger, deps Deps, table *routes.Table) (*Router, error) {
+	rt := &Router{
+		cfg:     cfg,
+		logger:  logger,
+		pools:   deps.Pools,
+		limiter: ratelimit.New(deps.Redis, logger.WithFields(map[string]any{"component": "ratelimit"})),
+		cache:   deps.Cache,
+		ready:   deps.Ready,
+		streams: stream.NewCounter(),
+		canary:  deps.Canary,
+		maint:   deps.Maintenance,
+		idem:    deps.Idempotency,
+		signer:  deps.Signer,
+		shadow:  deps.Shadow,
+	}
+	if err := rt.Reload(table); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Reload атомарно заменяет таблицу маршрутов.
+func (rt *Router) Reload(table *routes.Table) error {
+	if err := table.Validate(); err != nil {
+		return err
+	}
+
+	h, err := rt.build(table)
+	if err != nil {
+		return err
+	}
+	rt.handler.Store(&h)
+	rt.table.Store(table)
+	return nil
+}
+
+// Table возвращает действующую таблицу маршрутов.
+func (rt *Router) Table() *routes.Table {
+	return rt.table.Load()
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*rt.handler.Load()).ServeHTTP(w, r)
+}
+
+func (rt *Router) build(table *routes.Table) (http.Handler, error) {
+	ips, err := clientip.New(table.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, route := range table.Routes {
+		if reserved(route.Prefix) {
+			return nil, fmt.Errorf("маршрут %s: префикс %s занят каталогом API", route.Name, route.Prefix)
+		}
+	}
+	for _, c := range table.Compositions {
+		if reserved(c.Path) {
+			return nil, fmt.Errorf("составной эндпоинт %s: путь %s занят каталогом API", c.Name, c.Path)
+		}
+	}
+
+	pools := rt.pools.Sync(table)
+
 	r := chi.NewRouter()
+	r.Use(tracing.Middleware("vira-gateway"))
+	r.Use(requestid.Middleware)
 
-	r.Route("/api", func(r chi.Router) {
-		r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
-			w.Write([]byte("pong"))
-		})
+	headers := secheaders.Middleware(table.SecurityHeaders)
+	r.NotFound(headers(http.NotFoundHandler()).ServeHTTP)
+	r.MethodNotAllowed(headers(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})).ServeHTTP)
 
-		r.Route("/id", func(r chi.Router) {
-			r.Handle("/*", http.StripPrefix("/api/id", proxy.Proxy("http://vira-id:8080")))
-		})
+	// Каталог API: странице нужны свои скрипты и стили, поэтому
+	// у неё отдельная Content-Security-Policy
+	docsHeaders := table.SecurityHeaders
+	docsHeaders.ContentSecurityPolicy = openapi.UIPolicy
+	catalog := openapi.New(table, pools, rt.logger.WithFields(map[string]any{"component": "openapi"}))
 
-		r.Route("/dev", func(r chi.Router) {
-			r.Handle("/*", http.StripPrefix("/api/dev", proxy.Proxy("http://vira-api-dev:8080")))
-		})
+	ui := openapi.UIHandler()
 
-		r.Route("/wish", func(r chi.Router) {
-			r.Handle("/*", http.StripPrefix("/api/wish", proxy.Proxy("http://vira-api-wish:8080")))
-		})
+	r.Group(func(r chi.Router) {
+		r.Use(secheaders.Middleware(docsHeaders))
+		r.Get(openapi.DocPath, catalog.Handler())
+		r.Get(openapi.UIPath, ui.ServeHTTP)
+		r.Get(openapi.UIPath+"/*", ui.ServeHTTP)
 	})
 
-	return r
+	r.Group(func(r chi.Router) {
+		r.Use(headers)
+		rt.mount(r, table, pools, ips)
+	})
+
+	rt.canary.Sync(table)
+	return r, nil
+}
+
+// mount регистрирует служебные маршруты gateway и маршруты таблицы.
+func (rt *Router) mount(r chi.Router, table *routes.Table, pools map[string]*upstream.Pool, ips *clientip.Resolver) {
+	r.Get("/api/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	})
+
+	// Готовность для балансировщика перед gateway: 503 во время остановки
+	r.Get("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if rt.ready != nil && !rt.ready() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	// Метрики Prometheus
+	r.Get("/metrics", promhttp.Handler().ServeHTTP)
+
+	accessLogger := rt.logger.WithFields(map[string]any{"component": "access"})
+	handlers := make(map[string]http.Handler, len(table.Routes))
+
+	for _, route := range table.Routes {
+		routeLogger := rt.logger.WithFields(map[string]any{"component": "proxy", "route": route.Name})
+
+		var h http.Handler = proxy.Proxy(pools[route.Name], route, rt.streams, ips, rt.signer, routeLogger)
+		variants := make(map[string]http.Handler, len(route.Canary.Variants))
+		for _, v := range route.Canary.Variants {
+			pool := pools[routes.VariantPool(route.Name, v.Name)]
+			variants[v.Name] = proxy.Proxy(pool, route, rt.streams, ips, rt.signer, routeLogger.WithFields(map[string]any{"variant": v.Name}))
+		}
+		h = canary.Dispatch(h, variants)
+		// Копия уходит по пути upstream-а; ответы из кэша и повторы
+		// по Idempotency-Key до неё не доходят
+		h = rt.shadow.Middleware(route, ips)(h)
+
+		switch {
+		case route.Rewrite != "":
+			h = rewritePrefix(route.Prefix, route.Rewrite, h)
+		case route.StripPrefix:
+			h = http.StripPrefix(route.Prefix, h)
+		}
+		// Кэш снаружи StripPrefix: ключи строятся по публичному пути
+		h = rt.cache.Middleware(route.Name, route.Cache)(h)
+		// Вариант выбирается снаружи кэша: у каждого варианта свои записи
+		h = rt.canary.Middleware(route, ips)(h)
+		// Повтор по Idempotency-Key не зависит от варианта и не доходит до upstream
+		h = rt.idem.Middleware(route)(h)
+		h = rt.limiter.Middleware(route.Name, route.RateLimits, ips)(h)
+		h = auth.Middleware(rt.cfg.JwtSecret, route.Auth, rt.logger)(h)
+		h = rt.maint.Middleware(route.Name)(h)
+		// CORS снаружи auth: preflight приходит без токена
+		if route.CORS != "" {
+			h = cors.New(table.CORSPolicies[route.CORS]).Middleware(h)
+		}
+		h = apiversion.Middleware(route)(h)
+		h = accesslog.Middleware(route.Name, accessLogger)(h)
+
+		handlers[route.Name] = h
+		r.Handle(route.Prefix, h)
+		r.Handle(route.Prefix+"/*", h)
+	}
+
+	// Составные эндпоинты вызывают обработчики маршрутов напрямую,
+	// поэтому их секции проходят всю цепочку своего маршрута
+	for _, c := range table.Compositions {
+		composeLogger := rt.logger.WithFields(map[string]any{"component": "compose", "composition": c.Name})
+
+		var h http.Handler = compose.Handler(c, handlers, composeLogger)
+		h = auth.Middleware(rt.cfg.JwtSecret, routes.AuthProtected, rt.logger)(h)
+		if c.CORS != "" {
+			h = cors.New(table.CORSPolicies[c.CORS]).Middleware(h)
+		}
+		h = accesslog.Middleware(c.Name, accessLogger)(h)
+
+		r.Handle(c.Path, h)
+	}
+}
+
+// reserved сообщает, занят ли путь каталогом API gateway.
+func reserved(path string) bool {
+	return path == openapi.UIPath || strings.HasPrefix(path, openapi.UIPath+"/")
+}
+
+// rewritePrefix заменяет публичный префикс маршрута на путь версии upstream:
+// /api/v1/dev/courses с rewrite /v1 уходит в upstream как /v1/courses.
+func rewritePrefix(prefix, to string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := strings.CutPrefix(r.URL.Path, prefix)
+		rp, rok := strings.CutPrefix(r.URL.RawPath, prefix)
+		if !ok || (r.URL.RawPath != "" && !rok) {
+			http.NotFound(w, r)
+			return
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = strings.TrimSuffix(to, "/") + p
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = strings.TrimSuffix(to, "/") + rp
+		}
+		if r2.URL.Path == "" {
+			r2.URL.Path = "/"
+		}
+		h.ServeHTTP(w, r2)
+	})
 }
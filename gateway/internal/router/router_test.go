@@ -0,0 +1,145 @@
+package router
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vira-gateway/internal/cache"
+	"vira-gateway/internal/canary"
+	"vira-gateway/internal/idempotency"
+	"vira-gateway/internal/maintenance"
+	"vira-gateway/internal/openapi"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/shadow"
+	"vira-gateway/internal/upstream"
+
+	config "github.com/skrolikov/vira-config"
+	log "github.com/skrolikov/vira-logger"
+)
+
+// upstreamServer отвечает своим именем на любой запрос.
+func upstreamServer(t *testing.T, name string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, name)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// route — публичный маршрут без префикса в upstream.
+func route(name, prefix, upstreamURL string) routes.Route {
+	return routes.Route{Name: name, Prefix: prefix, Upstreams: []string{upstreamURL}, StripPrefix: true, Auth: routes.AuthPublic}
+}
+
+// newTestRouter собирает роутер без Redis по таблице table.
+func newTestRouter(t *testing.T, table *routes.Table) *Router {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	logger := log.New(log.Config{})
+	rt, err := Setup(&config.Config{JwtSecret: "test-secret"}, logger, Deps{
+		Pools:       upstream.NewRegistry(ctx, nil, logger),
+		Cache:       cache.New(nil, logger),
+		Canary:      canary.NewWeights(nil, logger),
+		Maintenance: maintenance.New(nil, logger),
+		Idempotency: idempotency.New(nil, logger),
+		Shadow:      shadow.New(nil, logger),
+	}, table)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	return rt
+}
+
+// get возвращает статус и тело ответа роутера на GET path.
+func get(rt *Router, path string) (int, string) {
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestReload(t *testing.T) {
+	a := upstreamServer(t, "a")
+	b := upstreamServer(t, "b")
+
+	tests := []struct {
+		name    string
+		table   *routes.Table
+		wantErr bool
+		// Ответы после перезагрузки: путь → тело (пусто — 404)
+		want map[string]string
+	}{
+		{
+			name:  "новая таблица",
+			table: &routes.Table{Routes: []routes.Route{route("b", "/api/b", b)}},
+			want:  map[string]string{"/api/a/x": "", "/api/b/x": "b"},
+		},
+		{
+			name:    "пустая таблица",
+			table:   &routes.Table{},
+			wantErr: true,
+			want:    map[string]string{"/api/a/x": "a"},
+		},
+		{
+			name: "повтор префикса",
+			table: &routes.Table{Routes: []routes.Route{
+				route("a", "/api/a", a),
+				route("b", "/api/a", b),
+			}},
+			wantErr: true,
+			want:    map[string]string{"/api/a/x": "a"},
+		},
+		{
+			name: "некорректная сеть прокси",
+			table: &routes.Table{
+				TrustedProxies: []string{"не-сеть"},
+				Routes:         []routes.Route{route("b", "/api/b", b)},
+			},
+			wantErr: true,
+			want:    map[string]string{"/api/a/x": "a", "/api/b/x": ""},
+		},
+		{
+			name:    "префикс каталога API",
+			table:   &routes.Table{Routes: []routes.Route{route("b", openapi.UIPath, b)}},
+			wantErr: true,
+			want:    map[string]string{"/api/a/x": "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initial := &routes.Table{Routes: []routes.Route{route("a", "/api/a", a)}}
+			rt := newTestRouter(t, initial)
+
+			err := rt.Reload(tt.table)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload() error = %v, wantErr %t", err, tt.wantErr)
+			}
+
+			// При ошибке остаётся прежняя таблица и прежний обработчик
+			wantTable := tt.table
+			if tt.wantErr {
+				wantTable = initial
+			}
+			if rt.Table() != wantTable {
+				t.Fatal("Table() вернула не ту таблицу")
+			}
+			for path, body := range tt.want {
+				code, got := get(rt, path)
+				if body == "" {
+					if code != http.StatusNotFound {
+						t.Errorf("GET %s = %d, want 404", path, code)
+					}
+					continue
+				}
+				if code != http.StatusOK || got != body {
+					t.Errorf("GET %s = %d %q, want 200 %q", path, code, got, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,867 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AuthMode — требование к аутентификации на маршруте.
+type AuthMode string
+
+const (
+	// AuthPublic — маршрут доступен без токена.
+	AuthPublic AuthMode = "public"
+
+	// AuthProtected — маршрут требует валидный access токен.
+	AuthProtected AuthMode = "protected"
+)
+
+// RateLimitKey — признак, по которому считаются запросы в лимите.
+type RateLimitKey string
+
+const (
+	// LimitByIP — по IP клиента.
+	LimitByIP RateLimitKey = "ip"
+
+	// LimitByUser — по ID пользователя из access токена.
+	LimitByUser RateLimitKey = "user"
+
+	// LimitByAPIKey — по значению заголовка X-API-Key. Ключ не проверяется,
+	// поэтому это деление квоты между клиентами, а не защита маршрута.
+	LimitByAPIKey RateLimitKey = "api_key"
+)
+
+// Table — таблица маршрутов gateway, загружаемая из файла.
+type Table struct {
+	TrustedProxies  []string              `yaml:"trusted_proxies" json:"trusted_proxies"`   // Сети прокси, которым доверяем X-Forwarded-For
+	SecurityHeaders SecurityHeaders       `yaml:"security_headers" json:"security_headers"` // Заголовки безопасности для всех ответов
+	CORSPolicies    map[string]CORSPolicy `yaml:"cors_policies" json:"cors_policies"`       // Именованные CORS политики для маршрутов
+	Routes          []Route               `yaml:"routes" json:"routes"`
+	Compositions    []Composition         `yaml:"compositions" json:"compositions"` // Составные эндпоинты (BFF)
+}
+
+// SecurityHeaders — заголовки безопасности, которые gateway ставит на каждый ответ,
+// заменяя значения от upstream. Пустое поле — заголовок не ставится,
+// кроме X-Content-Type-Options и Referrer-Policy, у которых есть значения по умолчанию.
+type SecurityHeaders struct {
+	HSTS                  string `yaml:"hsts" json:"hsts"`                                       // Strict-Transport-Security (только для HTTPS)
+	ContentSecurityPolicy string `yaml:"content_security_policy" json:"content_security_policy"` // Content-Security-Policy
+	ContentTypeOptions    string `yaml:"content_type_options" json:"content_type_options"`       // X-Content-Type-Options (по умолчанию nosniff)
+	ReferrerPolicy        string `yaml:"referrer_policy" json:"referrer_policy"`                 // Referrer-Policy (по умолчанию strict-origin-when-cross-origin)
+	FrameOptions          string `yaml:"frame_options" json:"frame_options"`                     // X-Frame-Options
+}
+
+// WithDefaults возвращает заголовки с подставленными значениями по умолчанию.
+func (h SecurityHeaders) WithDefaults() SecurityHeaders {
+	if h.ContentTypeOptions == "" {
+		h.ContentTypeOptions = "nosniff"
+	}
+	if h.ReferrerPolicy == "" {
+		h.ReferrerPolicy = "strict-origin-when-cross-origin"
+	}
+	return h
+}
+
+// CORSPolicy — правила CORS для браузерных клиентов маршрута.
+// Preflight запросы обрабатываются gateway и не доходят до upstream.
+type CORSPolicy struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" json:"allowed_origins"`     // Точные origin (https://vira-dev.loc) или *
+	AllowedMethods   []string `yaml:"allowed_methods" json:"allowed_methods"`     // По умолчанию GET, HEAD, POST
+	AllowedHeaders   []string `yaml:"allowed_headers" json:"allowed_headers"`     // Заголовки запроса; * — любые
+	ExposedHeaders   []string `yaml:"exposed_headers" json:"exposed_headers"`     // Заголовки ответа, доступные JS
+	AllowCredentials bool     `yaml:"allow_credentials" json:"allow_credentials"` // Разрешить cookies и Authorization
+	MaxAge           Duration `yaml:"max_age" json:"max_age"`                     // Кэширование preflight в браузере
+}
+
+// Route описывает один проксируемый маршрут.
+type Route struct {
+	Name        string   `yaml:"name" json:"name"`                 // Имя маршрута (для логов и метрик)
+	Prefix      string   `yaml:"prefix" json:"prefix"`             // Публичный префикс, например /api/id
+	Upstreams   []string `yaml:"upstreams" json:"upstreams"`       // Адреса экземпляров сервиса
+	StripPrefix bool     `yaml:"strip_prefix" json:"strip_prefix"` // Отрезать префикс перед проксированием
+	Auth        AuthMode `yaml:"auth" json:"auth"`                 // public или protected
+	Timeout     Duration `yaml:"timeout" json:"timeout"`           // Таймаут запроса к upstream вместе с повторами (по умолчанию 30s)
+
+	RateLimits []RateLimit `yaml:"rate_limits" json:"rate_limits"` // Ограничения частоты запросов
+	Pool       Pool        `yaml:"pool" json:"pool"`               // Балансировка и проверки здоровья upstream-ов
+	Retry      Retry       `yaml:"retry" json:"retry"`             // Повторы идемпотентных запросов
+	CORS       string      `yaml:"cors" json:"cors"`               // Имя политики из cors_policies (пусто — без CORS)
+	Streams    Streams     `yaml:"streams" json:"streams"`         // WebSocket и SSE
+	Cache      Cache       `yaml:"cache" json:"cache"`             // Кэш GET ответов в Redis
+
+	Idempotency Idempotency `yaml:"idempotency" json:"idempotency"` // Повтор ответа на запросы с Idempotency-Key
+
+	Version     string      `yaml:"version" json:"version"`         // Версия API маршрута (v1, v2) для метрик
+	Rewrite     string      `yaml:"rewrite" json:"rewrite"`         // Заменить префикс на этот путь перед проксированием (например, /v2)
+	Deprecation Deprecation `yaml:"deprecation" json:"deprecation"` // Вывод версии из эксплуатации
+
+	OpenAPI string `yaml:"openapi" json:"openapi"` // Путь OpenAPI документа в upstream для общего каталога (пусто — не включать)
+	Canary  Canary `yaml:"canary" json:"canary"`   // Разделение трафика между сборками сервиса
+	Shadow  Shadow `yaml:"shadow" json:"shadow"`   // Копия трафика в теневой upstream для сравнения ответов
+}
+
+// WithDefaults возвращает маршрут с подставленными значениями по умолчанию —
+// в том виде, в каком его обслуживает gateway.
+func (r Route) WithDefaults() Route {
+	r.Pool = r.Pool.WithDefaults()
+	r.Retry = r.Retry.WithDefaults()
+	if r.Streams.Enabled {
+		r.Streams = r.Streams.WithDefaults()
+	}
+	if r.Cache.Enabled {
+		r.Cache = r.Cache.WithDefaults()
+	}
+	if r.Idempotency.Enabled {
+		r.Idempotency = r.Idempotency.WithDefaults()
+	}
+	if r.Shadow.Upstream != "" {
+		r.Shadow = r.Shadow.WithDefaults()
+	}
+	return r
+}
+
+// StableVariant — имя основной группы upstream-ов маршрута (поле upstreams).
+const StableVariant = "stable"
+
+// Canary — разделение трафика маршрута между основной группой upstream-ов
+// и вариантами (новыми сборками сервиса). Вариант выбирается по порядку:
+// заголовок или cookie тестировщика с именем варианта, список пользователей
+// варианта, затем вес — процент пользователей (анонимных — IP). Назначение
+// по весу постоянно для пользователя: при увеличении веса попавшие в вариант
+// пользователи в нём остаются. Веса можно менять на лету через admin API.
+type Canary struct {
+	Header   string    `yaml:"header" json:"header"`     // Заголовок с именем варианта (например, X-Vira-Variant)
+	Cookie   string    `yaml:"cookie" json:"cookie"`     // Cookie с именем варианта
+	Variants []Variant `yaml:"variants" json:"variants"` // Пусто — весь трафик в upstreams маршрута
+}
+
+// Variant — группа upstream-ов новой сборки сервиса.
+// Пул (балансировка, проверки здоровья) настраивается как у маршрута.
+type Variant struct {
+	Name      string   `yaml:"name" json:"name"`           // Имя (label метрик), например canary
+	Upstreams []string `yaml:"upstreams" json:"upstreams"` // Адреса экземпляров сборки
+	Weight    int      `yaml:"weight" json:"weight"`       // Процент пользователей, 0–100
+	Users     []string `yaml:"users" json:"users"`         // ID пользователей, которые всегда попадают в вариант
+}
+
+// VariantPool — имя пула варианта маршрута.
+func VariantPool(route, variant string) string {
+	return route + "/" + variant
+}
+
+// VariantRoutes возвращает маршрут для пула каждого варианта:
+// upstreams варианта и настройки пула маршрута.
+func (r Route) VariantRoutes() []Route {
+	out := make([]Route, 0, len(r.Canary.Variants))
+	for _, v := range r.Canary.Variants {
+		vr := r
+		vr.Name = VariantPool(r.Name, v.Name)
+		vr.Upstreams = v.Upstreams
+		out = append(out, vr)
+	}
+	return out
+}
+
+// Shadow — зеркалирование трафика маршрута: доля запросов копируется
+// в теневой upstream (новую реализацию сервиса), и его ответ сравнивается
+// с ответом основного upstream-а по статусу и хэшу тела. Клиент всегда
+// получает основной ответ; теневой запрос идёт параллельно и не влияет на него.
+// Запросы с телом больше MaxBodySize, потоки и ответы из кэша не копируются.
+type Shadow struct {
+	Upstream    string   `yaml:"upstream" json:"upstream"`           // Адрес теневого upstream-а (пусто — выключено)
+	Percent     int      `yaml:"percent" json:"percent"`             // Доля копируемых запросов, 1–100
+	Methods     []string `yaml:"methods" json:"methods"`             // Копируемые методы (по умолчанию GET и HEAD)
+	MaxBodySize int64    `yaml:"max_body_size" json:"max_body_size"` // Предел тела запроса для копии (по умолчанию 1 MiB)
+	Timeout     Duration `yaml:"timeout" json:"timeout"`             // Таймаут теневого запроса (по умолчанию — таймаут маршрута)
+}
+
+// WithDefaults возвращает настройки с подставленными значениями по умолчанию.
+// Timeout без значения остаётся нулём: его подставляет gateway из маршрута.
+func (s Shadow) WithDefaults() Shadow {
+	if len(s.Methods) == 0 {
+		s.Methods = []string{"GET", "HEAD"}
+	}
+	if s.MaxBodySize == 0 {
+		s.MaxBodySize = 1 << 20
+	}
+	return s
+}
+
+// Composition — составной эндпоинт gateway (BFF): GET запрос клиента
+// параллельно расходится по маршрутам модулей, и ответы собираются в один
+// JSON документ. Каждая секция проходит цепочку своего маршрута (auth,
+// rate limit, кэш) с токеном клиента, поэтому каждый модуль сам решает,
+// что отдать пользователю. Эндпоинт всегда требует access токен.
+type Composition struct {
+	Name     string    `yaml:"name" json:"name"`         // Имя (для логов и метрик)
+	Path     string    `yaml:"path" json:"path"`         // Публичный путь, например /api/me
+	Timeout  Duration  `yaml:"timeout" json:"timeout"`   // Таймаут всех секций (по умолчанию 5s)
+	CORS     string    `yaml:"cors" json:"cors"`         // Имя политики из cors_policies
+	Sections []Section `yaml:"sections" json:"sections"` // Части документа
+}
+
+// Section — часть составного документа: ответ маршрута Route на GET Path.
+// Ответ 404 значит, что пользователь не состоит в модуле: секция будет null
+// без ошибки. Остальные ошибки помечаются в errors документа, а при ошибке
+// обязательной секции весь запрос завершается её статусом.
+type Section struct {
+	Name     string `yaml:"name" json:"name"`         // Ключ в документе
+	Route    string `yaml:"route" json:"route"`       // Имя маршрута таблицы
+	Path     string `yaml:"path" json:"path"`         // Публичный путь внутри префикса маршрута, например /api/id/me
+	Required bool   `yaml:"required" json:"required"` // Без секции документ не имеет смысла
+}
+
+// DefaultCompositionTimeout — таймаут составного эндпоинта, если он не задан.
+const DefaultCompositionTimeout = 5 * time.Second
+
+// Deprecation — заголовки Deprecation (RFC 9745), Sunset (RFC 8594) и Link
+// на ответах маршрута, по которым клиенты узнают о выводе версии API.
+type Deprecation struct {
+	Since         Date   `yaml:"since" json:"since"`                   // С какой даты версия устарела
+	Sunset        Date   `yaml:"sunset" json:"sunset"`                 // Дата отключения
+	Link          string `yaml:"link" json:"link"`                     // Документация по переходу (Link rel="deprecation")
+	EnforceSunset bool   `yaml:"enforce_sunset" json:"enforce_sunset"` // После Sunset отвечать 410 Gone
+}
+
+// Deprecated сообщает, объявлена ли версия устаревшей.
+func (d Deprecation) Deprecated() bool {
+	return !d.Since.IsZero() || !d.Sunset.IsZero()
+}
+
+// Cache — кэширование GET ответов маршрута в Redis, общем для всех реплик.
+// Время жизни берётся из Cache-Control ответа (s-maxage, max-age), а без него — TTL.
+// Ответы на запросы с токеном кэшируются отдельно для каждого пользователя,
+// если upstream не пометил их как public.
+type Cache struct {
+	Enabled     bool     `yaml:"enabled" json:"enabled"`
+	TTL         Duration `yaml:"ttl" json:"ttl"`                     // Время жизни без max-age в ответе (по умолчанию 1m)
+	MaxTTL      Duration `yaml:"max_ttl" json:"max_ttl"`             // Предел времени жизни (0 — без предела)
+	MaxBodySize int64    `yaml:"max_body_size" json:"max_body_size"` // Ответы больше не кэшируются (по умолчанию 1 MiB)
+}
+
+// WithDefaults возвращает настройки кэша с подставленными значениями по умолчанию.
+func (c Cache) WithDefaults() Cache {
+	if c.TTL == 0 {
+		c.TTL = Duration(time.Minute)
+	}
+	if c.MaxBodySize == 0 {
+		c.MaxBodySize = 1 << 20
+	}
+	return c
+}
+
+// Idempotency — поддержка заголовка Idempotency-Key на изменяющих запросах
+// (POST, PUT, PATCH, DELETE) с access токеном. Первый ответ сохраняется в Redis
+// по пользователю, маршруту и ключу и повторяется на дубликаты; дубликат,
+// пришедший, пока исходный запрос выполняется, получает 409. Ответы 5xx
+// не сохраняются: после них запрос с тем же ключом выполняется заново.
+// На анонимных запросах заголовок не действует: различить клиентов за одним
+// IP нельзя. На путях из ExcludePaths (полные пути, например /api/dev/login,
+// вместе с вложенными) заголовок тоже не действует: ответы с токенами
+// не должны храниться в Redis.
+type Idempotency struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
+	TTL          Duration `yaml:"ttl" json:"ttl"`                     // Сколько хранится ответ (по умолчанию 24h)
+	MaxBodySize  int64    `yaml:"max_body_size" json:"max_body_size"` // Ответы больше не сохраняются (по умолчанию 1 MiB)
+	ExcludePaths []string `yaml:"exclude_paths" json:"exclude_paths"` // Пути, где Idempotency-Key не поддерживается
+}
+
+// WithDefaults возвращает настройки с подставленными значениями по умолчанию.
+func (i Idempotency) WithDefaults() Idempotency {
+	if i.TTL == 0 {
+		i.TTL = Duration(24 * time.Hour)
+	}
+	if i.MaxBodySize == 0 {
+		i.MaxBodySize = 1 << 20
+	}
+	return i
+}
+
+// Streams — долгоживущие соединения маршрута: WebSocket и Server-Sent Events.
+// На потоки не действует Timeout маршрута; вместо него — таймаут простоя.
+// Без Enabled WebSocket на маршруте отклоняется, а SSE проксируется как обычный запрос.
+type Streams struct {
+	Enabled     bool     `yaml:"enabled" json:"enabled"`
+	MaxPerUser  int      `yaml:"max_per_user" json:"max_per_user"` // Соединений на пользователя (анонимных — на IP); 0 — без ограничения
+	IdleTimeout Duration `yaml:"idle_timeout" json:"idle_timeout"` // Закрыть поток без данных дольше этого (по умолчанию 5m)
+	MaxDuration Duration `yaml:"max_duration" json:"max_duration"` // Предельная длительность потока (0 — без ограничения)
+}
+
+// WithDefaults возвращает настройки потоков с подставленными значениями по умолчанию.
+func (s Streams) WithDefaults() Streams {
+	if s.IdleTimeout == 0 {
+		s.IdleTimeout = Duration(5 * time.Minute)
+	}
+	return s
+}
+
+// DefaultTimeout — таймаут маршрута, если он не задан в таблице.
+const DefaultTimeout = 30 * time.Second
+
+// Retry — повторы запросов при ошибке соединения или ответах 502/503/504.
+// Повторяются только идемпотентные методы без тела запроса.
+// Пауза перед повтором — случайная в пределах Backoff*2^n, но не больше MaxBackoff.
+type Retry struct {
+	Attempts   int      `yaml:"attempts" json:"attempts"`       // Повторов сверх первой попытки (0 — без повторов)
+	Backoff    Duration `yaml:"backoff" json:"backoff"`         // Базовая пауза (по умолчанию 50ms)
+	MaxBackoff Duration `yaml:"max_backoff" json:"max_backoff"` // Предел паузы (по умолчанию 1s)
+}
+
+// WithDefaults возвращает настройки повторов с подставленными значениями по умолчанию.
+func (r Retry) WithDefaults() Retry {
+	if r.Backoff == 0 {
+		r.Backoff = Duration(50 * time.Millisecond)
+	}
+	if r.MaxBackoff == 0 {
+		r.MaxBackoff = Duration(time.Second)
+	}
+	return r
+}
+
+// Balancer — стратегия выбора экземпляра upstream.
+type Balancer string
+
+const (
+	// BalancerRoundRobin — по кругу.
+	BalancerRoundRobin Balancer = "round_robin"
+
+	// BalancerLeastConn — экземпляр с наименьшим числом запросов в обработке.
+	BalancerLeastConn Balancer = "least_conn"
+)
+
+// Pool — настройки пула экземпляров маршрута.
+type Pool struct {
+	Balancer    Balancer    `yaml:"balancer" json:"balancer"`         // round_robin (по умолчанию) или least_conn
+	HealthCheck HealthCheck `yaml:"health_check" json:"health_check"` // Активные проверки здоровья
+	MaxFails    int         `yaml:"max_fails" json:"max_fails"`       // Ошибок подряд до выброса экземпляра (по умолчанию 3)
+	EjectFor    Duration    `yaml:"eject_for" json:"eject_for"`       // Срок выброса без активных проверок (по умолчанию 30s)
+
+	CircuitBreaker CircuitBreaker `yaml:"circuit_breaker" json:"circuit_breaker"` // Автомат на весь upstream
+}
+
+// CircuitBreaker — настройки автомата upstream-а. Пока цепь разомкнута,
+// gateway сразу отвечает 503, не дожидаясь таймаутов.
+type CircuitBreaker struct {
+	FailureThreshold int      `yaml:"failure_threshold" json:"failure_threshold"`   // Ошибок подряд до размыкания (по умолчанию 5)
+	OpenFor          Duration `yaml:"open_for" json:"open_for"`                     // Время в open до пробных запросов (по умолчанию 15s)
+	HalfOpenRequests int      `yaml:"half_open_requests" json:"half_open_requests"` // Пробных запросов в half-open (по умолчанию 1)
+}
+
+// HealthCheck — активная проверка здоровья экземпляров.
+// Пустой Path отключает активные проверки.
+type HealthCheck struct {
+	Path     string   `yaml:"path" json:"path"`         // Например, /healthz
+	Interval Duration `yaml:"interval" json:"interval"` // По умолчанию 10s
+	Timeout  Duration `yaml:"timeout" json:"timeout"`   // По умолчанию 2s
+}
+
+// WithDefaults возвращает настройки пула с подставленными значениями по умолчанию.
+func (p Pool) WithDefaults() Pool {
+	if p.Balancer == "" {
+		p.Balancer = BalancerRoundRobin
+	}
+	if p.MaxFails == 0 {
+		p.MaxFails = 3
+	}
+	if p.EjectFor == 0 {
+		p.EjectFor = Duration(30 * time.Second)
+	}
+	if p.HealthCheck.Interval == 0 {
+		p.HealthCheck.Interval = Duration(10 * time.Second)
+	}
+	if p.HealthCheck.Timeout == 0 {
+		p.HealthCheck.Timeout = Duration(2 * time.Second)
+	}
+	if p.CircuitBreaker.FailureThreshold == 0 {
+		p.CircuitBreaker.FailureThreshold = 5
+	}
+	if p.CircuitBreaker.OpenFor == 0 {
+		p.CircuitBreaker.OpenFor = Duration(15 * time.Second)
+	}
+	if p.CircuitBreaker.HalfOpenRequests == 0 {
+		p.CircuitBreaker.HalfOpenRequests = 1
+	}
+	return p
+}
+
+// RateLimit — правило скользящего окна: не более Limit запросов за Window
+// на каждое значение признака Key. Если задан Paths, правило действует
+// только на перечисленные полные пути (например, /api/id/login).
+type RateLimit struct {
+	Key    RateLimitKey `yaml:"key" json:"key"`
+	Limit  int          `yaml:"limit" json:"limit"`
+	Window Duration     `yaml:"window" json:"window"`
+	Paths  []string     `yaml:"paths" json:"paths"`
+}
+
+// Matches сообщает, относится ли правило к пути запроса.
+func (rl RateLimit) Matches(path string) bool {
+	if len(rl.Paths) == 0 {
+		return true
+	}
+	for _, p := range rl.Paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// Duration — time.Duration, читаемый из строки вида "10s" в YAML и JSON.
+type Duration time.Duration
+
+// UnmarshalYAML разбирает длительность из строки YAML.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+// UnmarshalJSON разбирает длительность из строки JSON.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+// MarshalJSON сериализует длительность в строку вида "10s".
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) parse(s string) error {
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("некорректная длительность %q: %w", s, err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Date — дата из строки вида "2026-01-31" или RFC 3339 в YAML и JSON.
+type Date struct {
+	time.Time
+}
+
+// UnmarshalYAML разбирает дату из строки YAML.
+func (d *Date) UnmarshalYAML(node *yaml.Node) error {
+	return d.parse(node.Value)
+}
+
+// UnmarshalJSON разбирает дату из строки JSON.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+// MarshalJSON сериализует дату в RFC 3339 (пустая дата — пустая строка).
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return json.Marshal("")
+	}
+	return json.Marshal(d.Format(time.RFC3339))
+}
+
+func (d *Date) parse(s string) error {
+	if s == "" {
+		d.Time = time.Time{}
+		return nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			d.Time = t
+			return nil
+		}
+	}
+	return fmt.Errorf("некорректная дата %q (ожидается 2006-01-02 или RFC 3339)", s)
+}
+
+// Load читает таблицу маршрутов из YAML или JSON файла и проверяет её.
+// Формат определяется по расширению: .json — JSON, всё остальное — YAML.
+func Load(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла маршрутов: %w", err)
+	}
+
+	var table Table
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &table)
+	} else {
+		err = yaml.Unmarshal(data, &table)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора файла маршрутов: %w", err)
+	}
+
+	if err := table.Validate(); err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// WithDefaults возвращает копию таблицы со значениями по умолчанию
+// в заголовках безопасности и маршрутах.
+func (t *Table) WithDefaults() *Table {
+	out := *t
+	out.SecurityHeaders = t.SecurityHeaders.WithDefaults()
+	out.Routes = make([]Route, len(t.Routes))
+	for i, r := range t.Routes {
+		out.Routes[i] = r.WithDefaults()
+	}
+	return &out
+}
+
+// Validate проверяет таблицу маршрутов целиком.
+// Возвращает первую найденную ошибку с указанием маршрута.
+func (t *Table) Validate() error {
+	if len(t.Routes) == 0 {
+		return errors.New("таблица маршрутов пуста")
+	}
+	for _, c := range t.TrustedProxies {
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			return fmt.Errorf("trusted_proxies: некорректная сеть %q", c)
+		}
+	}
+
+	for name, p := range t.CORSPolicies {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("cors_policies.%s: %w", name, err)
+		}
+	}
+
+	names := make(map[string]bool, len(t.Routes))
+	prefixes := make(map[string]bool, len(t.Routes))
+
+	for i := range t.Routes {
+		r := &t.Routes[i]
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("маршрут #%d (%s): %w", i+1, r.Name, err)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("маршрут %s: имя уже используется", r.Name)
+		}
+		if _, ok := t.CORSPolicies[r.CORS]; r.CORS != "" && !ok {
+			return fmt.Errorf("маршрут %s: неизвестная CORS политика %q", r.Name, r.CORS)
+		}
+		if prefixes[r.Prefix] {
+			return fmt.Errorf("маршрут %s: префикс %s уже используется", r.Name, r.Prefix)
+		}
+		names[r.Name] = true
+		prefixes[r.Prefix] = true
+	}
+
+	byName := make(map[string]*Route, len(t.Routes))
+	for i := range t.Routes {
+		byName[t.Routes[i].Name] = &t.Routes[i]
+	}
+	paths := make(map[string]bool, len(t.Compositions))
+	for i := range t.Compositions {
+		c := &t.Compositions[i]
+		if err := c.validate(byName); err != nil {
+			return fmt.Errorf("составной эндпоинт #%d (%s): %w", i+1, c.Name, err)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("составной эндпоинт %s: имя уже используется", c.Name)
+		}
+		if _, ok := t.CORSPolicies[c.CORS]; c.CORS != "" && !ok {
+			return fmt.Errorf("составной эндпоинт %s: неизвестная CORS политика %q", c.Name, c.CORS)
+		}
+		if prefixes[c.Path] || paths[c.Path] {
+			return fmt.Errorf("составной эндпоинт %s: путь %s уже используется", c.Name, c.Path)
+		}
+		names[c.Name] = true
+		paths[c.Path] = true
+	}
+	return nil
+}
+
+func (c *Composition) validate(routes map[string]*Route) error {
+	if strings.TrimSpace(c.Name) == "" {
+		return errors.New("не указано имя")
+	}
+	if !strings.HasPrefix(c.Path, "/") || (len(c.Path) > 1 && strings.HasSuffix(c.Path, "/")) ||
+		strings.ContainsAny(c.Path, "{}*") {
+		return fmt.Errorf("путь %q должен начинаться с /, не заканчиваться на / и не содержать шаблонов", c.Path)
+	}
+
+	switch {
+	case c.Timeout < 0:
+		return errors.New("таймаут не может быть отрицательным")
+	case c.Timeout == 0:
+		c.Timeout = Duration(DefaultCompositionTimeout)
+	}
+
+	if len(c.Sections) == 0 {
+		return errors.New("не указана ни одна секция")
+	}
+	sections := make(map[string]bool, len(c.Sections))
+	for i, s := range c.Sections {
+		if strings.TrimSpace(s.Name) == "" || s.Name == "errors" {
+			return fmt.Errorf("sections[%d]: некорректное имя %q", i, s.Name)
+		}
+		if sections[s.Name] {
+			return fmt.Errorf("sections[%d]: имя %s уже используется", i, s.Name)
+		}
+		sections[s.Name] = true
+
+		route, ok := routes[s.Route]
+		if !ok {
+			return fmt.Errorf("sections[%d]: неизвестный маршрут %q", i, s.Route)
+		}
+		if s.Path != route.Prefix && !strings.HasPrefix(s.Path, route.Prefix+"/") {
+			return fmt.Errorf("sections[%d]: путь %q вне префикса маршрута %s", i, s.Path, route.Name)
+		}
+	}
+	return nil
+}
+
+func (r *Route) validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("не указано имя")
+	}
+	if !strings.HasPrefix(r.Prefix, "/") || (len(r.Prefix) > 1 && strings.HasSuffix(r.Prefix, "/")) {
+		return fmt.Errorf("префикс %q должен начинаться с / и не заканчиваться на /", r.Prefix)
+	}
+	if strings.ContainsAny(r.Prefix, "{}*") {
+		return fmt.Errorf("префикс %q не должен содержать шаблонов", r.Prefix)
+	}
+
+	if err := validateUpstreams(r.Upstreams); err != nil {
+		return err
+	}
+
+	switch r.Auth {
+	case "":
+		r.Auth = AuthPublic
+	case AuthPublic, AuthProtected:
+	default:
+		return fmt.Errorf("неизвестный режим auth %q (ожидается public или protected)", r.Auth)
+	}
+
+	switch {
+	case r.Timeout < 0:
+		return errors.New("таймаут не может быть отрицательным")
+	case r.Timeout == 0:
+		r.Timeout = Duration(DefaultTimeout)
+	}
+
+	switch r.Pool.Balancer {
+	case "", BalancerRoundRobin, BalancerLeastConn:
+	default:
+		return fmt.Errorf("pool: неизвестная стратегия %q (ожидается round_robin или least_conn)", r.Pool.Balancer)
+	}
+	if r.Pool.MaxFails < 0 || r.Pool.EjectFor < 0 || r.Pool.HealthCheck.Interval < 0 || r.Pool.HealthCheck.Timeout < 0 {
+		return errors.New("pool: значения не могут быть отрицательными")
+	}
+	if hc := r.Pool.HealthCheck.Path; hc != "" && !strings.HasPrefix(hc, "/") {
+		return fmt.Errorf("pool: путь проверки здоровья %q должен начинаться с /", hc)
+	}
+	if cb := r.Pool.CircuitBreaker; cb.FailureThreshold < 0 || cb.OpenFor < 0 || cb.HalfOpenRequests < 0 {
+		return errors.New("pool.circuit_breaker: значения не могут быть отрицательными")
+	}
+
+	if r.Streams.MaxPerUser < 0 || r.Streams.IdleTimeout < 0 || r.Streams.MaxDuration < 0 {
+		return errors.New("streams: значения не могут быть отрицательными")
+	}
+
+	if r.Cache.TTL < 0 || r.Cache.MaxTTL < 0 || r.Cache.MaxBodySize < 0 {
+		return errors.New("cache: значения не могут быть отрицательными")
+	}
+
+	if r.Idempotency.TTL < 0 || r.Idempotency.MaxBodySize < 0 {
+		return errors.New("idempotency: значения не могут быть отрицательными")
+	}
+	for _, p := range r.Idempotency.ExcludePaths {
+		if !strings.HasPrefix(p, r.Prefix+"/") {
+			return fmt.Errorf("idempotency: путь %q вне префикса маршрута", p)
+		}
+		if path.Clean(p) != p {
+			return fmt.Errorf("idempotency: путь %q не нормализован (ожидается %s)", p, path.Clean(p))
+		}
+	}
+
+	if r.Rewrite != "" && (!strings.HasPrefix(r.Rewrite, "/") || (len(r.Rewrite) > 1 && strings.HasSuffix(r.Rewrite, "/"))) {
+		return fmt.Errorf("rewrite %q должен начинаться с / и не заканчиваться на /", r.Rewrite)
+	}
+	if d := r.Deprecation; !d.Since.IsZero() && !d.Sunset.IsZero() && d.Sunset.Before(d.Since.Time) {
+		return errors.New("deprecation: sunset раньше since")
+	}
+	if d := r.Deprecation; d.EnforceSunset && d.Sunset.IsZero() {
+		return errors.New("deprecation: enforce_sunset без даты sunset")
+	}
+	if link := r.Deprecation.Link; link != "" {
+		if u, err := url.Parse(link); err != nil || (!u.IsAbs() && !strings.HasPrefix(link, "/")) {
+			return fmt.Errorf("deprecation: некорректная ссылка %q", link)
+		}
+	}
+
+	if err := r.Canary.validate(); err != nil {
+		return fmt.Errorf("canary: %w", err)
+	}
+
+	if err := r.Shadow.validate(); err != nil {
+		return fmt.Errorf("shadow: %w", err)
+	}
+
+	if r.OpenAPI != "" && !strings.HasPrefix(r.OpenAPI, "/") {
+		return fmt.Errorf("openapi: путь %q должен начинаться с /", r.OpenAPI)
+	}
+
+	if r.Retry.Attempts < 0 || r.Retry.Attempts > 5 {
+		return errors.New("retry: attempts должно быть от 0 до 5")
+	}
+	if r.Retry.Backoff < 0 || r.Retry.MaxBackoff < 0 {
+		return errors.New("retry: паузы не могут быть отрицательными")
+	}
+
+	for i, rl := range r.RateLimits {
+		switch rl.Key {
+		case LimitByIP, LimitByUser, LimitByAPIKey:
+		default:
+			return fmt.Errorf("rate_limits[%d]: неизвестный ключ %q (ожидается ip, user или api_key)", i, rl.Key)
+		}
+		if rl.Limit <= 0 {
+			return fmt.Errorf("rate_limits[%d]: limit должен быть больше нуля", i)
+		}
+		if time.Duration(rl.Window) < time.Second {
+			return fmt.Errorf("rate_limits[%d]: window должно быть не меньше 1s", i)
+		}
+		for _, p := range rl.Paths {
+			if !strings.HasPrefix(p, r.Prefix+"/") {
+				return fmt.Errorf("rate_limits[%d]: путь %q вне префикса маршрута", i, p)
+			}
+		}
+	}
+	return nil
+}
+
+func validateUpstreams(upstreams []string) error {
+	if len(upstreams) == 0 {
+		return errors.New("не указан ни один upstream")
+	}
+	for _, raw := range upstreams {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("некорректный upstream %q: %w", raw, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("upstream %q должен быть абсолютным http(s) адресом", raw)
+		}
+	}
+	return nil
+}
+
+func (c Canary) validate() error {
+	if len(c.Variants) == 0 {
+		return nil
+	}
+	if c.Header != "" && strings.ContainsAny(c.Header, " :\t") {
+		return fmt.Errorf("некорректный заголовок %q", c.Header)
+	}
+
+	names := map[string]bool{StableVariant: true}
+	total := 0
+	for i, v := range c.Variants {
+		if strings.TrimSpace(v.Name) == "" || strings.ContainsAny(v.Name, "/ ") {
+			return fmt.Errorf("variants[%d]: некорректное имя %q", i, v.Name)
+		}
+		if names[v.Name] {
+			return fmt.Errorf("variants[%d]: имя %s уже используется", i, v.Name)
+		}
+		names[v.Name] = true
+
+		if err := validateUpstreams(v.Upstreams); err != nil {
+			return fmt.Errorf("variants[%d]: %w", i, err)
+		}
+		if v.Weight < 0 || v.Weight > 100 {
+			return fmt.Errorf("variants[%d]: weight должен быть от 0 до 100", i)
+		}
+		total += v.Weight
+	}
+	if total > 100 {
+		return fmt.Errorf("сумма весов вариантов %d больше 100", total)
+	}
+	return nil
+}
+
+func (p CORSPolicy) validate() error {
+	if len(p.AllowedOrigins) == 0 {
+		return errors.New("не указан ни один origin")
+	}
+	for _, o := range p.AllowedOrigins {
+		if o == "*" {
+			if p.AllowCredentials {
+				return errors.New("origin * несовместим с allow_credentials")
+			}
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return fmt.Errorf("origin %q должен иметь вид scheme://host[:port]", o)
+		}
+	}
+	for _, m := range p.AllowedMethods {
+		if m == "" || strings.ToUpper(m) != m {
+			return fmt.Errorf("метод %q должен быть в верхнем регистре", m)
+		}
+	}
+	if p.MaxAge < 0 {
+		return errors.New("max_age не может быть отрицательным")
+	}
+	return nil
+}
+
+// UpstreamURLs возвращает разобранные адреса upstream-ов маршрута.
+// Вызывается только для провалидированной таблицы.
+func (r Route) UpstreamURLs() []*url.URL {
+	urls := make([]*url.URL, 0, len(r.Upstreams))
+	for _, raw := range r.Upstreams {
+		u, _ := url.Parse(raw)
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+func (s Shadow) validate() error {
+	if s.Upstream == "" {
+		return nil
+	}
+	if err := validateUpstreams([]string{s.Upstream}); err != nil {
+		return err
+	}
+	if s.Percent < 1 || s.Percent > 100 {
+		return errors.New("percent должен быть от 1 до 100")
+	}
+	if s.MaxBodySize < 0 || s.Timeout < 0 {
+		return errors.New("значения не могут быть отрицательными")
+	}
+	for _, m := range s.Methods {
+		if m == "" || strings.ToUpper(m) != m {
+			return fmt.Errorf("некорректный метод %q (ожидается GET, POST и т. п.)", m)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/skrolikov/vira-logger"
+)
+
+// Watch следит за файлом маршрутов и перечитывает его по SIGHUP
+// или при изменении файла на диске (проверка раз в interval).
+// Новая таблица передаётся в apply; если файл не разобрался, не прошёл
+// валидацию или apply вернул ошибку — продолжает работать предыдущая таблица.
+// Блокируется до отмены ctx.
+func Watch(ctx context.Context, path string, interval time.Duration, logger *log.Logger, apply func(*Table) error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := stat(path)
+
+	reload := func(reason string) {
+		table, err := Load(path)
+		if err == nil {
+			err = apply(table)
+		}
+		if err != nil {
+			logger.Error("❌ Маршруты не перезагружены (%s), оставлена прежняя таблица: %v", reason, err)
+			return
+		}
+		logger.Info("🔄 Маршруты перезагружены (%s): %d шт.", reason, len(table.Routes))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			last, _ = stat(path)
+			reload("SIGHUP")
+		case <-ticker.C:
+			cur, err := stat(path)
+			if err != nil || cur == last {
+				continue
+			}
+			last = cur
+			reload("изменение файла")
+		}
+	}
+}
+
+// fileState — отпечаток файла для обнаружения изменений.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func stat(path string) (fileState, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
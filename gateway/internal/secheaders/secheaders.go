@@ -0,0 +1,39 @@
+package secheaders
+
+import (
+	"net/http"
+
+	"vira-gateway/internal/respwriter"
+	"vira-gateway/internal/routes"
+)
+
+// Middleware ставит заголовки безопасности на каждый ответ gateway,
+// заменяя значения от upstream. HSTS отправляется только по HTTPS
+// (напрямую или через прокси с X-Forwarded-Proto: https).
+func Middleware(cfg routes.SecurityHeaders) func(http.Handler) http.Handler {
+	cfg = cfg.WithDefaults()
+	headers := map[string]string{
+		"Content-Security-Policy": cfg.ContentSecurityPolicy,
+		"X-Content-Type-Options":  cfg.ContentTypeOptions,
+		"Referrer-Policy":         cfg.ReferrerPolicy,
+		"X-Frame-Options":         cfg.FrameOptions,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secure := r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+
+			w = respwriter.OnHeader(w, func(h http.Header) {
+				for name, value := range headers {
+					if value != "" {
+						h.Set(name, value)
+					}
+				}
+				if secure && cfg.HSTS != "" {
+					h.Set("Strict-Transport-Security", cfg.HSTS)
+				}
+			})
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"vira-gateway/internal/auth"
+	"vira-gateway/internal/clientip"
+	"vira-gateway/internal/identity"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/stream"
+
+	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/tracing"
+)
+
+// Header — заголовок, по которому теневой upstream отличает копию
+// от настоящего запроса (например, чтобы не слать письма и события).
+const Header = "X-Vira-Shadow"
+
+// maxInFlight — предел одновременных теневых запросов на реплику.
+// Сверх него копии не отправляются: медленный теневой upstream
+// не должен накапливать горутины и память gateway.
+const maxInFlight = 256
+
+// Заголовки соединения, которые не переносятся в копию запроса.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// transport — отдельный транспорт теневых запросов: их соединения
+// не занимают пул соединений основных upstream-ов.
+var transport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout:   3 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:   true,
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 20,
+	IdleConnTimeout:     90 * time.Second,
+	TLSHandshakeTimeout: 5 * time.Second,
+}
+
+// Mirror копирует запросы маршрутов в теневые upstream-ы и сравнивает ответы.
+type Mirror struct {
+	client *http.Client
+	signer *identity.Signer
+	logger *log.Logger
+	sem    chan struct{}
+}
+
+// New создаёт Mirror. Копии подписываются тем же signer-ом, что и основные запросы.
+func New(signer *identity.Signer, logger *log.Logger) *Mirror {
+	return &Mirror{
+		client: &http.Client{
+			Transport: tracing.Transport(transport),
+			// Редиректы сравниваются как есть, без перехода
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		signer: signer,
+		logger: logger,
+		sem:    make(chan struct{}, maxInFlight),
+	}
+}
+
+// result — итог теневого запроса.
+type result struct {
+	status int
+	sum    []byte
+	size   int64
+	err    error
+}
+
+// Middleware отправляет долю запросов маршрута в теневой upstream параллельно
+// с основным и после ответа клиенту сравнивает статус и хэш тела.
+// Клиент всегда получает основной ответ, ошибки копии на него не влияют.
+// Стоит внутри rewrite и StripPrefix: копия уходит по пути upstream-а.
+func (m *Mirror) Middleware(route routes.Route, ips *clientip.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if route.Shadow.Upstream == "" {
+			return next
+		}
+		cfg := route.Shadow.WithDefaults()
+		logger := m.logger.WithFields(map[string]any{"route": route.Name})
+		target, _ := url.Parse(strings.TrimSuffix(cfg.Upstream, "/"))
+
+		timeout := time.Duration(cfg.Timeout)
+		if timeout == 0 {
+			timeout = time.Duration(route.Timeout)
+		}
+		if timeout == 0 {
+			timeout = routes.DefaultTimeout
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(cfg.Methods, r.Method) || stream.Detect(r) != stream.None || rand.IntN(100) >= cfg.Percent {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, ok := bufferBody(r, cfg.MaxBodySize)
+			if !ok {
+				metrics.ShadowRequests.WithLabelValues(route.Name, "too_large").Inc()
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case m.sem <- struct{}{}:
+			default:
+				metrics.ShadowRequests.WithLabelValues(route.Name, "dropped").Inc()
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Копия собирается до основного запроса: proxy меняет заголовки r на месте
+			req := m.copyRequest(r, target, body, ips.IP(r))
+			done := make(chan result, 1)
+			go func() {
+				ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+				defer cancel()
+				done <- m.send(req.WithContext(ctx))
+			}()
+
+			rec := &recorder{ResponseWriter: w, hash: sha256.New()}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			clientGone := r.Context().Err() != nil
+
+			l := logger.WithContext(r.Context())
+			method, path := r.Method, r.URL.Path
+			go func() {
+				defer func() { <-m.sem }()
+				res := <-done
+
+				switch {
+				case res.err != nil:
+					metrics.ShadowRequests.WithLabelValues(route.Name, "error").Inc()
+					l.Warn("Shadow %s %s: ошибка теневого запроса: %v", method, path, res.err)
+				case clientGone:
+					// Основной ответ оборван клиентом — сравнивать не с чем
+					metrics.ShadowRequests.WithLabelValues(route.Name, "error").Inc()
+				case res.status != rec.status:
+					metrics.ShadowRequests.WithLabelValues(route.Name, "status_mismatch").Inc()
+					l.Warn("🔀 Shadow %s %s: статус %d, теневой %d", method, path, rec.status, res.status)
+				case !bytes.Equal(res.sum, rec.hash.Sum(nil)):
+					metrics.ShadowRequests.WithLabelValues(route.Name, "body_mismatch").Inc()
+					l.Warn("🔀 Shadow %s %s: тело отличается (%d байт, теневой %d байт)", method, path, rec.size, res.size)
+				default:
+					metrics.ShadowRequests.WithLabelValues(route.Name, "match").Inc()
+				}
+			}()
+		})
+	}
+}
+
+// copyRequest готовит копию запроса для теневого upstream-а.
+// Личность передаётся так же, как в основной запрос: только проверенная auth.
+func (m *Mirror) copyRequest(r *http.Request, target *url.URL, body []byte, clientIP string) *http.Request {
+	u := *target
+	u.Path = target.Path + r.URL.Path
+	u.RawPath = ""
+	if r.URL.RawPath != "" {
+		u.RawPath = target.Path + r.URL.RawPath
+	}
+	u.RawQuery = r.URL.RawQuery
+
+	req, _ := http.NewRequest(r.Method, u.String(), bytes.NewReader(body))
+	req.Header = r.Header.Clone()
+	for _, name := range hopHeaders {
+		req.Header.Del(name)
+	}
+	auth.StripHeaders(req.Header)
+	if id, ok := auth.FromContext(r.Context()); ok {
+		auth.SetHeaders(req.Header, id)
+	}
+	m.signer.Sign(r.Context(), req.Header, clientIP)
+	req.Header.Set(Header, "true")
+	return req
+}
+
+// send выполняет теневой запрос и хэширует тело ответа.
+func (m *Mirror) send(req *http.Request) result {
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return result{err: err}
+	}
+	defer resp.Body.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, resp.Body)
+	if err != nil {
+		return result{err: err}
+	}
+	return result{status: resp.StatusCode, sum: h.Sum(nil), size: n}
+}
+
+// bufferBody читает тело запроса, чтобы отправить его дважды.
+// Если тело больше limit или не читается, возвращает false и восстанавливает
+// r.Body так, что основной запрос получит его целиком (или ту же ошибку).
+func bufferBody(r *http.Request, limit int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil || int64(len(buf)) > limit {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(buf), errReader{err, r.Body}), r.Body}
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(buf))
+	return buf, true
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader отдаёт ошибку первого чтения, если она была, иначе остаток тела.
+type errReader struct {
+	err error
+	r   io.Reader
+}
+
+func (e errReader) Read(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	return e.r.Read(p)
+}
+
+// recorder передаёт ответ клиенту и считает статус, размер и хэш тела.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+	hash   hash.Hash
+}
+
+func (rw *recorder) WriteHeader(status int) {
+	if rw.status == 0 && status >= http.StatusOK {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.hash.Write(b)
+	rw.size += int64(len(b))
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap даёт http.ResponseController доступ к Flush.
+func (rw *recorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,142 @@
+package stream
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind — тип долгоживущего соединения.
+type Kind string
+
+const (
+	// None — обычный запрос.
+	None Kind = ""
+
+	// WebSocket — запрос на Upgrade: websocket.
+	WebSocket Kind = "websocket"
+
+	// SSE — подписка на Server-Sent Events (Accept: text/event-stream).
+	SSE Kind = "sse"
+)
+
+// Detect определяет, открывает ли запрос долгоживущее соединение.
+func Detect(r *http.Request) Kind {
+	if headerContains(r.Header, "Connection", "upgrade") && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return WebSocket
+	}
+	if r.Method == http.MethodGet && headerContains(r.Header, "Accept", "text/event-stream") {
+		return SSE
+	}
+	return None
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			part, _, _ = strings.Cut(part, ";")
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Counter ограничивает число одновременных соединений на ключ (пользователя или IP).
+// Счётчики живут в памяти реплики: предел действует на каждую реплику gateway.
+type Counter struct {
+	mu     sync.Mutex
+	active map[string]int
+}
+
+// NewCounter создаёт пустой счётчик.
+func NewCounter() *Counter {
+	return &Counter{active: make(map[string]int)}
+}
+
+// Acquire занимает место для key, если открыто меньше limit соединений.
+// limit <= 0 — без ограничения. Возвращённую функцию нужно вызвать при закрытии.
+func (c *Counter) Acquire(key string, limit int) (release func(), ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if limit > 0 && c.active[key] >= limit {
+		return nil, false
+	}
+	c.active[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.active[key]--; c.active[key] <= 0 {
+				delete(c.active, key)
+			}
+		})
+	}, true
+}
+
+type idleKey struct{}
+
+// WithIdleTimeout помечает запрос как поток: тело ответа upstream
+// закрывается, если по соединению нет данных дольше d.
+func WithIdleTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, idleKey{}, d)
+}
+
+// IdleTimeout возвращает таймаут простоя потока из контекста (0 — не задан).
+func IdleTimeout(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(idleKey{}).(time.Duration)
+	return d
+}
+
+// IdleBody оборачивает тело ответа так, что оно закрывается после d без
+// чтения и записи. Для 101 Switching Protocols тело — это соединение
+// с upstream (io.ReadWriteCloser), и обёртка сохраняет запись.
+func IdleBody(body io.ReadCloser, d time.Duration) io.ReadCloser {
+	ib := &idleBody{ReadCloser: body}
+	ib.timer = time.AfterFunc(d, func() { body.Close() })
+	ib.timeout = d
+
+	if w, ok := body.(io.Writer); ok {
+		return &idleRWBody{idleBody: ib, w: w}
+	}
+	return ib
+}
+
+type idleBody struct {
+	io.ReadCloser
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
+
+type idleRWBody struct {
+	*idleBody
+	w io.Writer
+}
+
+func (b *idleRWBody) Write(p []byte) (int, error) {
+	n, err := b.w.Write(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
@@ -0,0 +1,358 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vira-gateway/internal/breaker"
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+
+	log "github.com/skrolikov/vira-logger"
+)
+
+// ErrNoHealthy возвращается, если в пуле не осталось доступных экземпляров.
+var ErrNoHealthy = errors.New("нет доступных экземпляров upstream")
+
+// Mode — режим экземпляра, заданный оператором через admin API.
+type Mode string
+
+const (
+	// ModeActive — экземпляр принимает запросы (режим по умолчанию).
+	ModeActive Mode = "active"
+
+	// ModeDraining — новые запросы на экземпляр не отправляются,
+	// начатые запросы и потоки дорабатывают.
+	ModeDraining Mode = "draining"
+
+	// ModeDisabled — экземпляр выведен из пула: запросы не отправляются,
+	// проверки здоровья не выполняются.
+	ModeDisabled Mode = "disabled"
+)
+
+// Valid сообщает, известен ли режим.
+func (m Mode) Valid() bool {
+	switch m {
+	case ModeActive, ModeDraining, ModeDisabled:
+		return true
+	}
+	return false
+}
+
+// Instance — один экземпляр сервиса в пуле.
+type Instance struct {
+	URL *url.URL
+
+	active atomic.Int64 // Запросы в обработке (для least_conn)
+
+	mu           sync.Mutex
+	mode         Mode
+	healthy      bool
+	failures     int // Подряд идущие ошибки (5xx или ошибка соединения)
+	ejectedUntil time.Time
+	lastCheck    time.Time
+	lastError    string
+}
+
+// Acquire отмечает начало запроса к экземпляру.
+func (i *Instance) Acquire() { i.active.Add(1) }
+
+// Release отмечает окончание запроса к экземпляру.
+func (i *Instance) Release() { i.active.Add(-1) }
+
+// Mode возвращает режим экземпляра.
+func (i *Instance) Mode() Mode {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.mode
+}
+
+// setMode меняет режим экземпляра и сообщает, изменился ли он.
+func (i *Instance) setMode(m Mode) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	changed := i.mode != m
+	i.mode = m
+	return changed
+}
+
+// available сообщает, можно ли отправлять запросы на экземпляр.
+// Выброшенный пассивной проверкой экземпляр без активных проверок
+// возвращается в пул после истечения срока выброса.
+func (i *Instance) available(now time.Time, activeChecks bool) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.mode != ModeActive {
+		return false
+	}
+	if i.healthy {
+		return true
+	}
+	if !activeChecks && !i.ejectedUntil.IsZero() && now.After(i.ejectedUntil) {
+		i.healthy = true
+		i.failures = 0
+		i.ejectedUntil = time.Time{}
+		return true
+	}
+	return false
+}
+
+// Pool — набор экземпляров одного маршрута с балансировкой,
+// активными и пассивными проверками здоровья и общим circuit breaker-ом.
+type Pool struct {
+	Name      string
+	Breaker   *breaker.Breaker
+	cfg       routes.Pool
+	instances []*Instance
+	next      atomic.Uint64
+	logger    *log.Logger
+	client    *http.Client
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewPool создаёт пул для маршрута. Все экземпляры изначально считаются здоровыми.
+func NewPool(route routes.Route, logger *log.Logger) *Pool {
+	cfg := route.Pool.WithDefaults()
+	p := &Pool{
+		Name:   route.Name,
+		cfg:    cfg,
+		logger: logger,
+		client: &http.Client{Timeout: time.Duration(cfg.HealthCheck.Timeout)},
+		stop:   make(chan struct{}),
+	}
+	p.Breaker = breaker.New(breaker.Config{
+		FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+		OpenFor:          time.Duration(cfg.CircuitBreaker.OpenFor),
+		HalfOpenRequests: cfg.CircuitBreaker.HalfOpenRequests,
+	}, p.circuitChanged)
+	metrics.CircuitState.WithLabelValues(p.Name).Set(float64(breaker.Closed))
+
+	for _, u := range route.UpstreamURLs() {
+		p.instances = append(p.instances, &Instance{URL: u, mode: ModeActive, healthy: true})
+	}
+	return p
+}
+
+// Instances возвращает экземпляры пула.
+func (p *Pool) Instances() []*Instance {
+	return p.instances
+}
+
+// Instance возвращает экземпляр по адресу в том виде, в каком его
+// показывает Status.
+func (p *Pool) Instance(rawURL string) (*Instance, bool) {
+	for _, inst := range p.instances {
+		if inst.URL.String() == rawURL {
+			return inst, true
+		}
+	}
+	return nil, false
+}
+
+// Pick выбирает экземпляр по стратегии балансировки пула.
+func (p *Pool) Pick() (*Instance, error) {
+	now := time.Now()
+	activeChecks := p.cfg.HealthCheck.Path != ""
+
+	n := len(p.instances)
+	start := int(p.next.Add(1)-1) % n
+
+	var best *Instance
+	for k := 0; k < n; k++ {
+		inst := p.instances[(start+k)%n]
+		if !inst.available(now, activeChecks) {
+			continue
+		}
+		if p.cfg.Balancer == routes.BalancerRoundRobin {
+			return inst, nil
+		}
+		if best == nil || inst.active.Load() < best.active.Load() {
+			best = inst
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoHealthy
+	}
+	return best, nil
+}
+
+// Report учитывает результат запроса к экземпляру (пассивная проверка).
+// После MaxFails ошибок подряд экземпляр выбрасывается из пула.
+func (p *Pool) Report(inst *Instance, err error) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	if err == nil {
+		inst.failures = 0
+		return
+	}
+
+	inst.failures++
+	inst.lastError = err.Error()
+	if inst.healthy && inst.failures >= p.cfg.MaxFails {
+		inst.healthy = false
+		inst.ejectedUntil = time.Now().Add(time.Duration(p.cfg.EjectFor))
+		p.logger.Warn("⛔ Upstream %s выброшен из пула %s после %d ошибок: %v", inst.URL, p.Name, inst.failures, err)
+	}
+}
+
+// circuitChanged отражает смену состояния автомата в логах и метриках.
+func (p *Pool) circuitChanged(from, to breaker.State) {
+	metrics.CircuitState.WithLabelValues(p.Name).Set(float64(to))
+	metrics.CircuitTransitions.WithLabelValues(p.Name, to.String()).Inc()
+
+	switch to {
+	case breaker.Open:
+		p.logger.Warn("🔌 Цепь upstream %s разомкнута (%s → %s)", p.Name, from, to)
+	default:
+		p.logger.Info("🔌 Цепь upstream %s: %s → %s", p.Name, from, to)
+	}
+}
+
+// Run запускает активные проверки здоровья, если в конфиге задан путь.
+// Блокируется до Stop или отмены ctx.
+func (p *Pool) Run(ctx context.Context) {
+	hc := p.cfg.HealthCheck
+	if hc.Path == "" {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(hc.Interval))
+	defer ticker.Stop()
+
+	for {
+		p.checkAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop останавливает активные проверки здоровья.
+func (p *Pool) Stop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+func (p *Pool) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, inst := range p.instances {
+		if inst.Mode() == ModeDisabled {
+			continue
+		}
+		wg.Add(1)
+		go func(inst *Instance) {
+			defer wg.Done()
+			p.check(ctx, inst)
+		}(inst)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) check(ctx context.Context, inst *Instance) {
+	err := p.probe(ctx, inst)
+
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	inst.lastCheck = time.Now()
+	if err != nil {
+		inst.lastError = err.Error()
+		if inst.healthy {
+			inst.healthy = false
+			p.logger.Warn("⛔ Upstream %s пула %s не прошёл проверку здоровья: %v", inst.URL, p.Name, err)
+		}
+		return
+	}
+
+	if !inst.healthy {
+		p.logger.Info("✅ Upstream %s возвращён в пул %s", inst.URL, p.Name)
+	}
+	inst.healthy = true
+	inst.failures = 0
+	inst.ejectedUntil = time.Time{}
+	inst.lastError = ""
+}
+
+func (p *Pool) probe(ctx context.Context, inst *Instance) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, inst.URL.JoinPath(p.cfg.HealthCheck.Path).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("статус %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// InstanceStatus — состояние экземпляра для admin API.
+type InstanceStatus struct {
+	URL            string    `json:"url"`
+	Mode           Mode      `json:"mode"`
+	Drained        bool      `json:"drained,omitempty"` // Режим draining и запросов в обработке не осталось
+	Healthy        bool      `json:"healthy"`
+	ActiveRequests int64     `json:"active_requests"`
+	Failures       int       `json:"consecutive_failures"`
+	EjectedUntil   time.Time `json:"ejected_until,omitzero"`
+	LastCheck      time.Time `json:"last_check,omitzero"`
+	LastError      string    `json:"last_error,omitempty"`
+}
+
+// PoolStatus — состояние пула для admin API.
+type PoolStatus struct {
+	Route       string           `json:"route"`
+	Balancer    routes.Balancer  `json:"balancer"`
+	HealthCheck string           `json:"health_check,omitempty"`
+	Circuit     breaker.State    `json:"circuit"`
+	Instances   []InstanceStatus `json:"instances"`
+}
+
+// Status возвращает снимок состояния пула.
+func (p *Pool) Status() PoolStatus {
+	st := PoolStatus{
+		Route:       p.Name,
+		Balancer:    p.cfg.Balancer,
+		HealthCheck: p.cfg.HealthCheck.Path,
+		Circuit:     p.Breaker.State(),
+	}
+	for _, inst := range p.instances {
+		inst.mu.Lock()
+		active := inst.active.Load()
+		st.Instances = append(st.Instances, InstanceStatus{
+			URL:            inst.URL.String(),
+			Mode:           inst.mode,
+			Drained:        inst.mode == ModeDraining && active == 0,
+			Healthy:        inst.healthy,
+			ActiveRequests: active,
+			Failures:       inst.failures,
+			EjectedUntil:   inst.ejectedUntil,
+			LastCheck:      inst.lastCheck,
+			LastError:      inst.lastError,
+		})
+		inst.mu.Unlock()
+	}
+	return st
+}
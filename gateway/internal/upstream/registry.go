@@ -0,0 +1,221 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"vira-gateway/internal/metrics"
+	"vira-gateway/internal/routes"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+// modesKey — hash в Redis с режимами экземпляров: поле — "пул адрес", значение — режим.
+// Экземпляры в режиме active в hash не хранятся.
+const modesKey = "gwupstream:modes"
+
+// ErrUnknownInstance возвращается, если в пуле нет экземпляра с таким адресом.
+var ErrUnknownInstance = errors.New("экземпляр upstream не найден")
+
+// Registry хранит пулы всех маршрутов между перезагрузками таблицы.
+// Если адреса и настройки пула маршрута не изменились, пул переиспользуется
+// вместе с накопленным состоянием здоровья. Режимы экземпляров (draining,
+// disabled) хранятся в Redis, поэтому действуют на всех репликах gateway.
+type Registry struct {
+	ctx    context.Context
+	rdb    *redis.Client
+	logger *log.Logger
+
+	mu    sync.RWMutex
+	pools map[string]*entry
+	modes map[string]Mode // "пул адрес" → режим
+}
+
+type entry struct {
+	pool      *Pool
+	upstreams []string
+	cfg       routes.Pool
+}
+
+// NewRegistry создаёт реестр; активные проверки пулов живут до отмены ctx.
+func NewRegistry(ctx context.Context, rdb *redis.Client, logger *log.Logger) *Registry {
+	return &Registry{
+		ctx:    ctx,
+		rdb:    rdb,
+		logger: logger,
+		pools:  make(map[string]*entry),
+		modes:  make(map[string]Mode),
+	}
+}
+
+// Sync приводит набор пулов к таблице маршрутов: создаёт новые,
+// переиспользует неизменившиеся и останавливает лишние.
+// Пулы вариантов canary доступны по имени routes.VariantPool(маршрут, вариант).
+func (r *Registry) Sync(table *routes.Table) map[string]*Pool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// У маршрута с canary, кроме основного, по пулу на каждый вариант
+	var specs []routes.Route
+	for _, route := range table.Routes {
+		specs = append(specs, route)
+		specs = append(specs, route.VariantRoutes()...)
+	}
+
+	next := make(map[string]*entry, len(specs))
+	for _, route := range specs {
+		if e, ok := r.pools[route.Name]; ok &&
+			reflect.DeepEqual(e.upstreams, route.Upstreams) &&
+			reflect.DeepEqual(e.cfg, route.Pool) {
+			next[route.Name] = e
+			continue
+		}
+
+		p := NewPool(route, r.logger.WithFields(map[string]any{"route": route.Name}))
+		r.applyModes(p)
+		go p.Run(r.ctx)
+		next[route.Name] = &entry{pool: p, upstreams: route.Upstreams, cfg: route.Pool}
+	}
+
+	for name, e := range r.pools {
+		n, ok := next[name]
+		if !ok {
+			metrics.CircuitState.DeleteLabelValues(name)
+		}
+		if !ok || n != e {
+			e.pool.Stop()
+		}
+	}
+	r.pools = next
+
+	out := make(map[string]*Pool, len(next))
+	for name, e := range next {
+		out[name] = e.pool
+	}
+	return out
+}
+
+// Status возвращает состояние всех пулов, отсортированное по имени маршрута.
+func (r *Registry) Status() []PoolStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]PoolStatus, 0, len(r.pools))
+	for _, e := range r.pools {
+		out = append(out, e.pool.Status())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+// SetMode меняет режим экземпляра пула на всех репликах.
+func (r *Registry) SetMode(ctx context.Context, pool, rawURL string, mode Mode) error {
+	if !mode.Valid() {
+		return fmt.Errorf("неизвестный режим %q (ожидается active, draining или disabled)", mode)
+	}
+
+	r.mu.RLock()
+	e, ok := r.pools[pool]
+	r.mu.RUnlock()
+	if !ok {
+		return ErrUnknownInstance
+	}
+	inst, ok := e.pool.Instance(rawURL)
+	if !ok {
+		return ErrUnknownInstance
+	}
+
+	field := modeField(pool, rawURL)
+	var err error
+	if mode == ModeActive {
+		err = r.rdb.HDel(ctx, modesKey, field).Err()
+	} else {
+		err = r.rdb.HSet(ctx, modesKey, field, string(mode)).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка записи режима в Redis: %w", err)
+	}
+
+	r.mu.Lock()
+	if mode == ModeActive {
+		delete(r.modes, field)
+	} else {
+		r.modes[field] = mode
+	}
+	r.mu.Unlock()
+
+	if inst.setMode(mode) {
+		r.logger.Info("🚧 Upstream %s пула %s: режим %s", rawURL, pool, mode)
+	}
+	return nil
+}
+
+// Run перечитывает режимы экземпляров из Redis раз в interval, чтобы
+// изменения, сделанные через другую реплику, дошли до этой.
+// Блокируется до отмены ctx.
+func (r *Registry) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.refresh(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Warn("Не удалось прочитать режимы upstream-ов из Redis: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Registry) refresh(ctx context.Context) error {
+	raw, err := r.rdb.HGetAll(ctx, modesKey).Result()
+	if err != nil {
+		return err
+	}
+
+	modes := make(map[string]Mode, len(raw))
+	for field, value := range raw {
+		if m := Mode(value); m.Valid() {
+			modes[field] = m
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if maps.Equal(r.modes, modes) {
+		return nil
+	}
+	r.modes = modes
+	for _, e := range r.pools {
+		r.applyModes(e.pool)
+	}
+	return nil
+}
+
+// applyModes выставляет экземплярам пула режимы из r.modes.
+// Вызывается под r.mu.
+func (r *Registry) applyModes(p *Pool) {
+	for _, inst := range p.instances {
+		mode, ok := r.modes[modeField(p.Name, inst.URL.String())]
+		if !ok {
+			mode = ModeActive
+		}
+		if inst.setMode(mode) {
+			r.logger.Info("🚧 Upstream %s пула %s: режим %s", inst.URL, p.Name, mode)
+		}
+	}
+}
+
+func modeField(pool, rawURL string) string {
+	return pool + " " + rawURL
+}
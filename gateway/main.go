@@ -1,16 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"os"
+	"time"
 
+	"vira-gateway/internal/admin"
+	"vira-gateway/internal/cache"
+	"vira-gateway/internal/canary"
+	"vira-gateway/internal/idempotency"
+	"vira-gateway/internal/identity"
+	"vira-gateway/internal/maintenance"
 	"vira-gateway/internal/router"
+	"vira-gateway/internal/routes"
+	"vira-gateway/internal/shadow"
+	"vira-gateway/internal/upstream"
 
 	config "github.com/skrolikov/vira-config"
 	log "github.com/skrolikov/vira-logger"
+	redisdb "github.com/skrolikov/vira-redisdb"
+	"github.com/skrolikov/vira/pkg/server"
+	"github.com/skrolikov/vira/pkg/tracing"
 )
 
 func main() {
 	cfg := config.Load()
+	ctx := context.Background()
 
 	// ✅ Инициализация логгера
 	logger := log.New(log.Config{
@@ -27,10 +44,118 @@ func main() {
 
 	logger.Info("🚀 Запуск Vira-Gateway")
 
-	r := router.Setup(cfg, logger)
+	// Трассировка OpenTelemetry
+	shutdownTracing, err := tracing.Init(ctx, "vira-gateway", logger.WithFields(map[string]any{"component": "tracing"}))
+	if err != nil {
+		logger.Fatal("❌ Ошибка инициализации трассировки: %v", err)
+	}
+
+	// Таблица маршрутов
+	routesFile := os.Getenv("GATEWAY_ROUTES_FILE")
+	if routesFile == "" {
+		routesFile = "routes.yaml"
+	}
+	table, err := routes.Load(routesFile)
+	if err != nil {
+		logger.Fatal("❌ Ошибка загрузки маршрутов из %s: %v", routesFile, err)
+	}
+	logger.Info("✅ Загружено маршрутов: %d (%s)", len(table.Routes), routesFile)
+
+	// Redis — общие счётчики rate limit и кэш ответов для всех реплик gateway
+	redisConn, err := redisdb.New(ctx, redisdb.Config{
+		Addr:     cfg.RedisAddr,
+		Password: "",
+		DB:       cfg.RedisDB,
+	}, logger.WithFields(map[string]any{"component": "redis"}))
+	if err != nil {
+		logger.Fatal("❌ Ошибка подключения к Redis: %v", err)
+	}
+
+	srvCfg, err := server.FromEnv(":" + cfg.Port)
+	if err != nil {
+		logger.Fatal("❌ Ошибка настроек сервера: %v", err)
+	}
+	srv := server.New(srvCfg, logger)
+
+	// Пулы upstream-ов с проверками здоровья (останавливаются вместе с сервером)
+	poolsCtx, stopPools := context.WithCancel(ctx)
+	// Режимы экземпляров (draining, disabled) расходятся по репликам через Redis
+	pools := upstream.NewRegistry(poolsCtx, redisConn.Client(), logger.WithFields(map[string]any{"component": "upstream"}))
+	go pools.Run(poolsCtx, 5*time.Second)
+
+	// Кэш ответов GET маршрутов
+	respCache := cache.New(redisConn.Client(), logger.WithFields(map[string]any{"component": "cache"}))
+
+	// Веса canary: изменения из admin API расходятся по репликам через Redis
+	weights := canary.NewWeights(redisConn.Client(), logger.WithFields(map[string]any{"component": "canary"}))
+	go weights.Run(poolsCtx, 5*time.Second)
+
+	// Режим обслуживания маршрутов
+	maint := maintenance.New(redisConn.Client(), logger.WithFields(map[string]any{"component": "maintenance"}))
+	go maint.Run(poolsCtx, 5*time.Second)
+
+	// Подпись личности для сервисов: по ней они отличают запросы через gateway
+	signer := identity.FromEnv()
+	if signer == nil {
+		logger.Warn("⚠️ IDENTITY_SECRET не задан: личность передаётся в сервисы без подписи")
+	}
+
+	r, err := router.Setup(cfg, logger, router.Deps{
+		Redis:       redisConn.Client(),
+		Pools:       pools,
+		Cache:       respCache,
+		Ready:       srv.Ready,
+		Canary:      weights,
+		Maintenance: maint,
+		Idempotency: idempotency.New(redisConn.Client(), logger.WithFields(map[string]any{"component": "idempotency"})),
+		Signer:      signer,
+		Shadow:      shadow.New(signer, logger.WithFields(map[string]any{"component": "shadow"})),
+	}, table)
+	if err != nil {
+		logger.Fatal("❌ Ошибка сборки роутера: %v", err)
+	}
+
+	go routes.Watch(ctx, routesFile, 2*time.Second, logger.WithFields(map[string]any{"component": "routes"}), r.Reload)
+
+	// Служебный API на отдельном порту (не публикуется наружу), под токеном или mTLS
+	adminCfg, err := admin.FromEnv()
+	switch {
+	case errors.Is(err, admin.ErrUnprotected):
+		logger.Warn("⚠️ Admin API отключён: %v", err)
+	case err != nil:
+		logger.Fatal("❌ Ошибка настроек admin API: %v", err)
+	default:
+		adminSrv, err := admin.NewServer(adminCfg, admin.Handler(admin.Deps{
+			Table:       r.Table,
+			Pools:       pools,
+			Cache:       respCache,
+			Canary:      weights,
+			Maintenance: maint,
+		}), srvCfg, logger.WithFields(map[string]any{"component": "admin"}))
+		if err != nil {
+			logger.Fatal("❌ Ошибка настроек admin API: %v", err)
+		}
+
+		go func() {
+			logger.Info("🛠️ Admin API gateway слушает %s", adminCfg.Addr)
+			if err := admin.ListenAndServe(adminSrv); err != nil && err != http.ErrServerClosed {
+				logger.Error("❌ Ошибка запуска admin API: %v", err)
+			}
+		}()
+		srv.OnShutdown("admin API", adminSrv.Close)
+	}
+
+	srv.OnShutdown("пулы upstream-ов", func() error {
+		stopPools()
+		return nil
+	})
+	srv.OnShutdown("Redis", redisConn.Close)
+	srv.OnShutdown("трассировка", func() error {
+		return shutdownTracing(context.Background())
+	})
 
 	logger.Info("✅ Vira-Gateway запущен на порту %s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
+	if err := srv.Run(r); err != nil {
 		logger.Fatal("❌ Ошибка запуска gateway: %v", err)
 	}
 }
@@ -0,0 +1,77 @@
+package apidocs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Path — адрес OpenAPI документа сервиса. Его забирает gateway для общего каталога.
+const Path = "/openapi.json"
+
+// Служебные маршруты, которые не описываются в OpenAPI документе.
+var ignored = []string{"/healthz", "/metrics", "/redis-test", Path}
+
+// Handler отдаёт OpenAPI документ сервиса.
+func Handler(doc string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(doc))
+	}
+}
+
+// chiParam — параметр пути chi с регулярным выражением: {id:[0-9]+}.
+var chiParam = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+
+// Check сверяет маршруты роутера с OpenAPI документом и возвращает
+// расхождения: операции без маршрута и маршруты без описания.
+// ignore — служебные маршруты сервиса сверх общих (например, /v1/* у vira-id).
+func Check(routes chi.Routes, doc string, ignore ...string) ([]string, error) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(doc), &spec); err != nil {
+		return nil, fmt.Errorf("некорректный OpenAPI документ: %w", err)
+	}
+
+	documented := map[string]bool{}
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	mounted := map[string]bool{}
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = chiParam.ReplaceAllString(route, "{$1}")
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		if !slices.Contains(ignored, route) && !slices.Contains(ignore, route) {
+			mounted[method+" "+route] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка обхода маршрутов: %w", err)
+	}
+
+	var drift []string
+	for op := range documented {
+		if !mounted[op] {
+			drift = append(drift, "описан, но не смонтирован: "+op)
+		}
+	}
+	for op := range mounted {
+		if !documented[op] {
+			drift = append(drift, "смонтирован, но не описан: "+op)
+		}
+	}
+	slices.Sort(drift)
+	return drift, nil
+}
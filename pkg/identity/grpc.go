@@ -0,0 +1,31 @@
+package identity
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Метаданные gRPC, с которыми сервис передаёт в vira-id запрос клиента.
+const (
+	MetadataKey       = "x-vira-identity"   // Подписанная gateway личность (Header)
+	MetadataUserAgent = "x-vira-user-agent" // User-Agent клиента
+)
+
+// Outgoing добавляет в исходящие метаданные gRPC подписанную личность,
+// проверенную Auth или Optional, и User-Agent клиента. vira-id берёт из
+// подписи IP клиента, а User-Agent учитывает только вместе с ней.
+// Без проверенной личности добавляется только User-Agent.
+func Outgoing(ctx context.Context, userAgent string) context.Context {
+	var kv []string
+	if value, ok := ctx.Value(valueKey{}).(string); ok && value != "" {
+		kv = append(kv, MetadataKey, value)
+	}
+	if userAgent != "" {
+		kv = append(kv, MetadataUserAgent, userAgent)
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
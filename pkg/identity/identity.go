@@ -0,0 +1,210 @@
+package identity
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/skrolikov/vira-config"
+	log "github.com/skrolikov/vira-logger"
+	middleware "github.com/skrolikov/vira-middleware"
+)
+
+// Header — подписанная gateway личность запроса.
+const Header = "X-Vira-Identity"
+
+// version — формат заголовка: v1.<claims>.<подпись>.
+const version = "v1"
+
+// leeway — допуск расхождения часов gateway и сервиса.
+const leeway = 5 * time.Second
+
+var (
+	ErrMissing = errors.New("нет подписанной личности")
+	ErrInvalid = errors.New("неверная подпись личности")
+	ErrExpired = errors.New("подпись личности истекла")
+)
+
+// Claims — личность, подписанная gateway.
+type Claims struct {
+	UserID    string `json:"sub,omitempty"`
+	Role      string `json:"role,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	RequestID string `json:"rid,omitempty"`
+	ClientIP  string `json:"ip,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	Expires   int64  `json:"exp"`
+}
+
+// Config — настройки проверки личности.
+type Config struct {
+	Secret   string // IDENTITY_SECRET: общий с gateway секрет подписи
+	Required bool   // IDENTITY_REQUIRED: отклонять запросы без подписи (мимо gateway)
+}
+
+// FromEnv читает настройки из переменных окружения.
+func FromEnv() (Config, error) {
+	cfg := Config{Secret: os.Getenv("IDENTITY_SECRET")}
+	if v := os.Getenv("IDENTITY_REQUIRED"); v != "" {
+		required, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, errors.New("IDENTITY_REQUIRED: ожидается true или false")
+		}
+		cfg.Required = required
+	}
+	if cfg.Required && cfg.Secret == "" {
+		return cfg, errors.New("IDENTITY_REQUIRED требует IDENTITY_SECRET")
+	}
+	return cfg, nil
+}
+
+type (
+	ctxKey   struct{}
+	valueKey struct{}
+)
+
+// FromContext возвращает личность, проверенную Auth или Optional.
+func FromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(ctxKey{}).(Claims)
+	return c, ok
+}
+
+// withClaims сохраняет проверенную личность и исходный заголовок:
+// заголовок передаётся дальше в vira-id (Outgoing).
+func withClaims(ctx context.Context, c Claims, value string) context.Context {
+	ctx = context.WithValue(ctx, ctxKey{}, c)
+	return context.WithValue(ctx, valueKey{}, value)
+}
+
+// ClientIP возвращает IP клиента: из подписанной gateway личности, а без
+// неё — адрес соединения. X-Forwarded-For не учитывается: клиент может
+// подставить в него любой адрес.
+func ClientIP(r *http.Request) string {
+	if c, ok := FromContext(r.Context()); ok && c.ClientIP != "" {
+		return c.ClientIP
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Verify проверяет подпись и срок заголовка.
+func Verify(value, secret string, now time.Time) (Claims, error) {
+	var c Claims
+	if value == "" {
+		return c, ErrMissing
+	}
+
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return c, ErrInvalid
+	}
+	unsigned, sig := value[:i], value[i+1:]
+	payload, ok := strings.CutPrefix(unsigned, version+".")
+	if !ok {
+		return c, ErrInvalid
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return c, ErrInvalid
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return c, ErrInvalid
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(raw, &c) != nil {
+		return c, ErrInvalid
+	}
+	if now.After(time.Unix(c.Expires, 0).Add(leeway)) {
+		return c, ErrExpired
+	}
+	return c, nil
+}
+
+// Auth пропускает только запросы с пользователем. Пользователь берётся из
+// подписанного gateway заголовка; без него — из JWT, как middleware.Auth,
+// если подпись не обязательна (IDENTITY_REQUIRED). Без секрета проверяется
+// только JWT. В обоих случаях middleware.GetUserID возвращает пользователя.
+func Auth(cfg Config, appCfg *config.Config, logger *log.Logger) func(http.Handler) http.Handler {
+	jwtAuth := middleware.Auth(appCfg, logger)
+
+	return func(next http.Handler) http.Handler {
+		fallback := jwtAuth(next)
+		if cfg.Secret == "" {
+			return fallback
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value := r.Header.Get(Header)
+			if value == "" && !cfg.Required {
+				fallback.ServeHTTP(w, r)
+				return
+			}
+
+			l := logger.WithContext(r.Context()).WithFields(map[string]any{
+				"path":   r.URL.Path,
+				"method": r.Method,
+			})
+			c, err := Verify(value, cfg.Secret, time.Now())
+			if err != nil {
+				l.Warn("Identity: %v", err)
+				http.Error(w, "invalid identity", http.StatusUnauthorized)
+				return
+			}
+			if c.UserID == "" {
+				l.Warn("Identity: запрос без пользователя")
+				http.Error(w, "missing token", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), middleware.UserIDKey, c.UserID)
+			next.ServeHTTP(w, r.WithContext(withClaims(ctx, c, value)))
+		})
+	}
+}
+
+// Optional проверяет подпись на публичных маршрутах (вход, регистрация):
+// пользователь не нужен, но IP клиента берётся из подписи (ClientIP).
+// Запрос без подписи пропускается, если она не обязательна; с неверной
+// подписью — отклоняется. Без секрета ничего не проверяет.
+func Optional(cfg Config, logger *log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if cfg.Secret == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			value := r.Header.Get(Header)
+			if value == "" && !cfg.Required {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			c, err := Verify(value, cfg.Secret, time.Now())
+			if err != nil {
+				logger.WithContext(r.Context()).WithFields(map[string]any{
+					"path":   r.URL.Path,
+					"method": r.Method,
+				}).Warn("Identity: %v", err)
+				http.Error(w, "invalid identity", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), c, value)))
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	config "github.com/skrolikov/vira-config"
+	log "github.com/skrolikov/vira-logger"
+	middleware "github.com/skrolikov/vira-middleware"
+)
+
+const secret = "test-secret"
+
+// sign подписывает claims так же, как gateway.
+func sign(t *testing.T, c Claims, key string) string {
+	t.Helper()
+	raw, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signRaw(version+"."+base64.RawURLEncoding.EncodeToString(raw), key)
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	valid := Claims{UserID: "u1", Role: "admin", SessionID: "s1", IssuedAt: now.Unix(), Expires: now.Add(30 * time.Second).Unix()}
+	good := sign(t, valid, secret)
+
+	otherClaims := valid
+	otherClaims.UserID = "u2"
+	forged := sign(t, otherClaims, secret)
+	// Чужие claims с подписью исходного заголовка
+	swapped := forged[:strings.LastIndexByte(forged, '.')] + good[strings.LastIndexByte(good, '.'):]
+
+	tests := []struct {
+		name  string
+		value string
+		now   time.Time
+		want  error
+	}{
+		{"верная подпись", good, now, nil},
+		{"пустой заголовок", "", now, ErrMissing},
+		{"другой секрет", sign(t, valid, "other-secret"), now, ErrInvalid},
+		{"подменённые claims", swapped, now, ErrInvalid},
+		{"неизвестная версия", "v2" + good[2:], now, ErrInvalid},
+		{"без подписи", "v1.e30", now, ErrInvalid},
+		{"подпись не base64", good + "!", now, ErrInvalid},
+		{"истёк в пределах допуска", good, now.Add(30*time.Second + leeway), nil},
+		{"истёк", good, now.Add(30*time.Second + leeway + time.Second), ErrExpired},
+		{"подписанный мусор", signRaw("v1.bm90LWpzb24", secret), now, ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Verify(tt.value, secret, tt.now)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && c != valid {
+				t.Fatalf("Verify() = %+v, want %+v", c, valid)
+			}
+		})
+	}
+}
+
+// signRaw подписывает произвольную строку v1.<payload>.
+func signRaw(unsigned, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuth(t *testing.T) {
+	now := time.Now()
+	user := sign(t, Claims{UserID: "u1", IssuedAt: now.Unix(), Expires: now.Add(30 * time.Second).Unix()}, secret)
+	anonymous := sign(t, Claims{IssuedAt: now.Unix(), Expires: now.Add(30 * time.Second).Unix()}, secret)
+	expired := sign(t, Claims{UserID: "u1", IssuedAt: now.Add(-time.Minute).Unix(), Expires: now.Add(-time.Minute).Unix()}, secret)
+
+	tests := []struct {
+		name     string
+		required bool
+		header   string
+		status   int
+		userID   string
+	}{
+		{"пользователь из подписи", true, user, http.StatusOK, "u1"},
+		{"анонимная подпись", true, anonymous, http.StatusUnauthorized, ""},
+		{"истёкшая подпись", true, expired, http.StatusUnauthorized, ""},
+		{"подпись другим секретом", false, sign(t, Claims{UserID: "u1", Expires: now.Add(time.Minute).Unix()}, "other"), http.StatusUnauthorized, ""},
+		{"без подписи при обязательной", true, "", http.StatusUnauthorized, ""},
+		{"без подписи и без JWT", false, "", http.StatusUnauthorized, ""},
+	}
+
+	appCfg := &config.Config{JwtSecret: "jwt-secret"}
+	logger := log.New(log.Config{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userID string
+			var claims Claims
+			h := Auth(Config{Secret: secret, Required: tt.required}, appCfg, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID = middleware.GetUserID(r)
+				claims, _ = FromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				r.Header.Set(Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if userID != tt.userID || claims.UserID != tt.userID {
+				t.Fatalf("user = %q (claims %q), want %q", userID, claims.UserID, tt.userID)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	now := time.Now()
+	withIP := sign(t, Claims{ClientIP: "203.0.113.7", IssuedAt: now.Unix(), Expires: now.Add(30 * time.Second).Unix()}, secret)
+	withoutIP := sign(t, Claims{IssuedAt: now.Unix(), Expires: now.Add(30 * time.Second).Unix()}, secret)
+
+	tests := []struct {
+		name   string
+		header string
+		xff    string
+		status int
+		ip     string
+	}{
+		{"IP из подписи", withIP, "198.51.100.1", http.StatusOK, "203.0.113.7"},
+		{"подпись без IP", withoutIP, "", http.StatusOK, "192.0.2.1"},
+		{"без подписи X-Forwarded-For не учитывается", "", "198.51.100.1", http.StatusOK, "192.0.2.1"},
+		{"неверная подпись", sign(t, Claims{ClientIP: "203.0.113.7", Expires: now.Add(time.Minute).Unix()}, "other"), "", http.StatusUnauthorized, ""},
+	}
+
+	logger := log.New(log.Config{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ip string
+			h := Optional(Config{Secret: secret}, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ip = ClientIP(r)
+			}))
+
+			r := httptest.NewRequest(http.MethodPost, "/login", nil)
+			r.RemoteAddr = "192.0.2.1:5555"
+			if tt.header != "" {
+				r.Header.Set(Header, tt.header)
+			}
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if ip != tt.ip {
+				t.Fatalf("ClientIP() = %q, want %q", ip, tt.ip)
+			}
+		})
+	}
+}
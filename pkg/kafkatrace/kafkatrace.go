@@ -0,0 +1,132 @@
+package kafkatrace
+
+import (
+	"context"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	kafka "github.com/skrolikov/vira-kafka"
+	log "github.com/skrolikov/vira-logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Producer — Kafka-продюсер событий, который передаёт контекст трассировки
+// (traceparent) в заголовках сообщения. Настраивается тем же
+// kafka.ProducerConfig, что и vira-kafka Producer; отдельный тип нужен,
+// потому что vira-kafka пока не умеет заголовки сообщений.
+type Producer struct {
+	writer *kafkago.Writer
+	topic  string
+	logger *log.Logger
+	tracer trace.Tracer
+}
+
+// NewProducer создаёт продюсер по конфигурации vira-kafka. Спаны
+// отправки пишутся трейсером с именем tracerName (обычно имя сервиса);
+// Tracer, Middlewares и Metrics из конфигурации не используются.
+func NewProducer(tracerName string, cfg kafka.ProducerConfig) *Producer {
+	if cfg.Logger == nil {
+		cfg.Logger = log.DefaultLogger()
+	}
+
+	writer := &kafkago.Writer{
+		Addr:         kafkago.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafkago.LeastBytes{},
+		RequiredAcks: cfg.RequiredAcks,
+		Async:        cfg.Async,
+		BatchTimeout: cfg.BatchTimeout,
+		Compression:  cfg.Compression,
+		MaxAttempts:  cfg.MaxAttempts,
+	}
+
+	cfg.Logger.Info("✅ Kafka producer создан для topic: %s", cfg.Topic)
+
+	return &Producer{writer: writer, topic: cfg.Topic, logger: cfg.Logger, tracer: otel.Tracer(tracerName)}
+}
+
+// Send отправляет сообщение в Kafka в рамках спана продюсера.
+func (p *Producer) Send(ctx context.Context, key string, value []byte) error {
+	ctx, span := p.tracer.Start(ctx, p.topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", p.topic),
+			attribute.String("messaging.kafka.message.key", key),
+		),
+	)
+	defer span.End()
+
+	msg := kafkago.Message{
+		Key:   []byte(key),
+		Value: value,
+		Time:  time.Now(),
+	}
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{Headers: &msg.Headers})
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := p.writer.WriteMessages(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		p.logger.WithContext(ctx).Error("❌ Ошибка отправки сообщения в Kafka: %v", err)
+		return err
+	}
+
+	p.logger.WithContext(ctx).Debug("📤 Сообщение отправлено: key=%s", key)
+	return nil
+}
+
+// Close закрывает соединение с Kafka.
+func (p *Producer) Close() error {
+	err := p.writer.Close()
+	if err != nil {
+		p.logger.Error("❌ Ошибка при закрытии Kafka producer: %v", err)
+	} else {
+		p.logger.Info("🔌 Kafka producer закрыт")
+	}
+	return err
+}
+
+// HeaderCarrier — propagation.TextMapCarrier поверх заголовков сообщения Kafka.
+// Продюсер кладёт туда traceparent, консьюмер продолжает по нему трассу.
+type HeaderCarrier struct {
+	Headers *[]kafkago.Header
+}
+
+var _ propagation.TextMapCarrier = HeaderCarrier{}
+
+// Get возвращает значение заголовка key.
+func (c HeaderCarrier) Get(key string) string {
+	for _, h := range *c.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set заменяет или добавляет заголовок key.
+func (c HeaderCarrier) Set(key, value string) {
+	for i, h := range *c.Headers {
+		if h.Key == key {
+			(*c.Headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.Headers = append(*c.Headers, kafkago.Header{Key: key, Value: []byte(value)})
+}
+
+// Keys возвращает имена всех заголовков.
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.Headers))
+	for _, h := range *c.Headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	log "github.com/skrolikov/vira-logger"
+)
+
+// Config — настройки HTTP сервера. Значения по умолчанию подставляет FromEnv.
+type Config struct {
+	Addr string
+
+	ReadHeaderTimeout time.Duration // HTTP_READ_HEADER_TIMEOUT
+	ReadTimeout       time.Duration // HTTP_READ_TIMEOUT
+	WriteTimeout      time.Duration // HTTP_WRITE_TIMEOUT
+	IdleTimeout       time.Duration // HTTP_IDLE_TIMEOUT
+	MaxHeaderBytes    int           // HTTP_MAX_HEADER_BYTES
+	MaxBodyBytes      int64         // HTTP_MAX_BODY_BYTES (0 — без ограничения)
+
+	DrainDelay      time.Duration // SHUTDOWN_DRAIN_DELAY: пауза между снятием готовности и закрытием порта
+	ShutdownTimeout time.Duration // SHUTDOWN_TIMEOUT: сколько ждать завершения запросов
+}
+
+// FromEnv собирает настройки сервера на addr из переменных окружения.
+func FromEnv(addr string) (Config, error) {
+	cfg := Config{Addr: addr}
+	var err error
+
+	durations := []struct {
+		name string
+		dst  *time.Duration
+		def  time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", &cfg.ReadHeaderTimeout, 5 * time.Second},
+		{"HTTP_READ_TIMEOUT", &cfg.ReadTimeout, 30 * time.Second},
+		{"HTTP_WRITE_TIMEOUT", &cfg.WriteTimeout, 60 * time.Second},
+		{"HTTP_IDLE_TIMEOUT", &cfg.IdleTimeout, 120 * time.Second},
+		{"SHUTDOWN_DRAIN_DELAY", &cfg.DrainDelay, 5 * time.Second},
+		{"SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout, 20 * time.Second},
+	}
+	for _, d := range durations {
+		if *d.dst, err = envDuration(d.name, d.def); err != nil {
+			return cfg, err
+		}
+	}
+
+	maxHeader, err := envInt("HTTP_MAX_HEADER_BYTES", 1<<20)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.MaxHeaderBytes = int(maxHeader)
+
+	if cfg.MaxBodyBytes, err = envInt("HTTP_MAX_BODY_BYTES", 10<<20); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Server — HTTP сервер с таймаутами, ограничением тела запроса
+// и плавной остановкой по SIGINT/SIGTERM.
+//
+// Остановка: готовность снимается (Ready возвращает false, /healthz отвечает 503),
+// через DrainDelay балансировщик перестаёт слать запросы, затем сервер
+// дожидается текущих запросов и по очереди закрывает зависимости из OnShutdown.
+type Server struct {
+	cfg    Config
+	srv    *http.Server
+	logger *log.Logger
+
+	ready   atomic.Bool
+	closers []closer
+}
+
+type closer struct {
+	name string
+	fn   func() error
+}
+
+// New создаёт сервер. Обработчик передаётся в Run, поэтому сервер можно
+// создать раньше роутера и использовать Ready в проверках здоровья.
+func New(cfg Config, logger *log.Logger) *Server {
+	s := &Server{cfg: cfg, logger: logger}
+	s.srv = &http.Server{
+		Addr:              cfg.Addr,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	s.ready.Store(true)
+	return s
+}
+
+// Ready сообщает, принимает ли сервер новые запросы.
+// Во время остановки возвращает false.
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// OnShutdown добавляет зависимость, которую нужно закрыть после остановки
+// HTTP сервера. Зависимости закрываются в порядке добавления: сначала те,
+// что пишут в другие (Kafka продюсер), затем хранилища (Redis, БД).
+func (s *Server) OnShutdown(name string, fn func() error) {
+	s.closers = append(s.closers, closer{name: name, fn: fn})
+}
+
+// Run запускает сервер с handler и блокируется до сигнала остановки.
+// Возвращает ошибку, если порт не удалось открыть.
+func (s *Server) Run(handler http.Handler) error {
+	s.srv.Handler = limitBody(s.cfg.MaxBodyBytes, handler)
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	select {
+	case err := <-errCh:
+		return err
+	case sig := <-stop:
+		s.logger.Info("🛑 Получен сигнал %s, остановка сервера...", sig)
+	}
+
+	s.shutdown()
+	return nil
+}
+
+func (s *Server) shutdown() {
+	// Снимаем готовность и даём балансировщику время заметить это
+	s.ready.Store(false)
+	if s.cfg.DrainDelay > 0 {
+		s.logger.Info("⏳ Готовность снята, ждём %s перед закрытием порта", s.cfg.DrainDelay)
+		time.Sleep(s.cfg.DrainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.logger.Warn("Не все запросы завершились за %s, соединения закрыты принудительно: %v", s.cfg.ShutdownTimeout, err)
+		s.srv.Close()
+	} else {
+		s.logger.Info("✅ HTTP сервер остановлен")
+	}
+
+	for _, c := range s.closers {
+		if err := c.fn(); err != nil {
+			s.logger.Error("Ошибка закрытия %s: %v", c.name, err)
+			continue
+		}
+		s.logger.Info("✅ Закрыто: %s", c.name)
+	}
+}
+
+// limitBody отвечает 413 на запросы с телом больше max.
+func limitBody(max int64, next http.Handler) http.Handler {
+	if max <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > max {
+			http.Error(w, "слишком большое тело запроса", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: некорректная длительность %q", name, v)
+	}
+	return d, nil
+}
+
+func envInt(name string, def int64) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s: некорректное число %q", name, v)
+	}
+	return n, nil
+}
@@ -0,0 +1,168 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	log "github.com/skrolikov/vira-logger"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+)
+
+// Init настраивает глобальный TracerProvider и W3C propagator (traceparent, baggage).
+// Экспортёр выбирается переменными окружения:
+//
+//	OTEL_TRACES_EXPORTER         otlp, stdout, file или none. По умолчанию otlp,
+//	                             если задан OTEL_EXPORTER_OTLP_ENDPOINT, иначе none
+//	OTEL_EXPORTER_OTLP_ENDPOINT  адрес коллектора (OTLP/HTTP), например http://otel-collector:4318
+//	OTEL_TRACES_FILE             файл для экспортёра file (по умолчанию traces.json)
+//
+// Сэмплирование настраивается стандартными OTEL_TRACES_SAMPLER и OTEL_TRACES_SAMPLER_ARG.
+// Возвращает функцию, которая дописывает буфер спанов при остановке.
+func Init(ctx context.Context, service string, logger *log.Logger) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporter, closer, err := newExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		// Контекст трассировки всё равно передаётся дальше, спаны не пишутся
+		logger.Info("🔭 Трассировка выключена (OTEL_TRACES_EXPORTER=none)")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", service),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка описания ресурса трассировки: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	logger.Info("🔭 Трассировка включена: экспортёр %s", exporterName())
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+func exporterName() string {
+	name := os.Getenv("OTEL_TRACES_EXPORTER")
+	if name == "" {
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+			return "otlp"
+		}
+		return "none"
+	}
+	return name
+}
+
+func newExporter(ctx context.Context) (sdktrace.SpanExporter, io.Closer, error) {
+	switch name := exporterName(); name {
+	case "none":
+		return nil, nil, nil
+	case "otlp":
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка создания OTLP экспортёра: %w", err)
+		}
+		return exp, nil, nil
+	case "stdout":
+		exp, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exp, nil, err
+	case "file":
+		path := os.Getenv("OTEL_TRACES_FILE")
+		if path == "" {
+			path = "traces.json"
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка открытия файла трассировки: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exp, f, nil
+	default:
+		return nil, nil, fmt.Errorf("неизвестный экспортёр трассировки %q", name)
+	}
+}
+
+// Middleware создаёт серверный спан на каждый запрос chi-роутера,
+// продолжая трассу из входящего traceparent. Имя спана — метод и шаблон
+// маршрута chi (GET /sessions/{id}), чтобы не плодить имена по ID.
+func Middleware(service string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			if rc := chi.RouteContext(r.Context()); rc != nil {
+				if pattern := rc.RoutePattern(); pattern != "" {
+					span := trace.SpanFromContext(r.Context())
+					span.SetName(r.Method + " " + pattern)
+					span.SetAttributes(attribute.String("http.route", pattern))
+				}
+			}
+		})
+
+		return otelhttp.NewHandler(named, service,
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method
+			}),
+		)
+	}
+}
+
+// Transport оборачивает RoundTripper клиентскими спанами и передаёт
+// контекст трассировки в заголовке traceparent.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// GRPCServerHandler создаёт серверный спан на каждый вызов gRPC,
+// продолжая трассу из метаданных traceparent.
+func GRPCServerHandler() stats.Handler {
+	return otelgrpc.NewServerHandler()
+}
+
+// GRPCClientHandler создаёт клиентский спан на каждый вызов gRPC
+// и передаёт контекст трассировки в метаданных.
+func GRPCClientHandler() stats.Handler {
+	return otelgrpc.NewClientHandler()
+}
+
+// TraceID возвращает ID трассы из контекста или пустую строку.
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -5,11 +5,11 @@ import (
 	"encoding/json"
 	"time"
 
-	kafka "github.com/skrolikov/vira-kafka"
 	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
 )
 
-func EmitDevUserRegisteredEvent(ctx context.Context, producer *kafka.Producer, logger *log.Logger, userID, city string, registeredAt time.Time) error {
+func EmitDevUserRegisteredEvent(ctx context.Context, producer *kafkatrace.Producer, logger *log.Logger, userID, city string, registeredAt time.Time) error {
 	event := map[string]interface{}{
 		"type":          "dev.user.registered",
 		"user_id":       userID,
@@ -26,7 +26,7 @@ func EmitDevUserRegisteredEvent(ctx context.Context, producer *kafka.Producer, l
 	return producer.Send(ctx, "vira-dev.users", data)
 }
 
-func EmitDevUserLoggedInEvent(ctx context.Context, producer *kafka.Producer, logger *log.Logger, userID, ip, userAgent string, loginAt time.Time) error {
+func EmitDevUserLoggedInEvent(ctx context.Context, producer *kafkatrace.Producer, logger *log.Logger, userID, ip, userAgent string, loginAt time.Time) error {
 	event := map[string]interface{}{
 		"type":       "dev.user.logged_in",
 		"user_id":    userID,
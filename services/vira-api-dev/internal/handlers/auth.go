@@ -5,14 +5,24 @@ import (
 	"net/http"
 	"vira-api-dev/internal/service"
 	"vira-api-dev/internal/types"
+
+	"github.com/skrolikov/vira/pkg/identity"
 )
 
+// RegisterHandler регистрирует пользователя в vira-id и создаёт локальный профиль
+//
+// @Summary      Регистрация
+// @Description  Регистрация в vira-id и создание профиля
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.RegisterProfileRequest  true  "Данные регистрации"
+// @Success      201      {object}  types.DevAuthResponse
+// @Failure      400      {string}  string  "Неверный запрос или ошибка регистрации"
+// @Router       /register [post]
 func RegisterHandler(svc *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var in struct {
-			types.RegisterRequest
-			City string `json:"city"`
-		}
+		var in types.RegisterProfileRequest
 		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
@@ -22,7 +32,7 @@ func RegisterHandler(svc *service.AuthService) http.HandlerFunc {
 			r.Context(),
 			in.RegisterRequest,
 			types.ProfileData{City: in.City},
-			r.RemoteAddr,
+			identity.ClientIP(r),
 			r.UserAgent(),
 		)
 		if err != nil {
@@ -36,6 +46,18 @@ func RegisterHandler(svc *service.AuthService) http.HandlerFunc {
 	}
 }
 
+// LoginHandler авторизует пользователя через vira-id и возвращает профиль
+//
+// @Summary      Вход
+// @Description  Авторизация по логину и паролю через vira-id
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.LoginRequest  true  "Данные входа"
+// @Success      200      {object}  types.DevAuthResponse
+// @Failure      400      {string}  string  "Неверный формат запроса"
+// @Failure      401      {string}  string  "Неверный логин или пароль"
+// @Router       /login [post]
 func LoginHandler(svc *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req types.LoginRequest
@@ -47,7 +69,7 @@ func LoginHandler(svc *service.AuthService) http.HandlerFunc {
 		resp, err := svc.LoginProxy(
 			r.Context(),
 			req,
-			r.RemoteAddr,
+			identity.ClientIP(r),
 			r.UserAgent(),
 		)
 		if err != nil {
@@ -9,18 +9,18 @@ import (
 	"vira-api-dev/internal/types"
 	"vira-api-dev/internal/viraid"
 
-	kafka "github.com/skrolikov/vira-kafka"
 	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
 )
 
 type AuthService struct {
 	IDClient *viraid.Client
 	Repo     types.UserProfileRepository
-	Producer *kafka.Producer
+	Producer *kafkatrace.Producer
 	Logger   *log.Logger
 }
 
-func NewAuthService(idClient *viraid.Client, repo types.UserProfileRepository, producer *kafka.Producer, logger *log.Logger) *AuthService {
+func NewAuthService(idClient *viraid.Client, repo types.UserProfileRepository, producer *kafkatrace.Producer, logger *log.Logger) *AuthService {
 	return &AuthService{
 		IDClient: idClient,
 		Repo:     repo,
@@ -30,7 +30,7 @@ func NewAuthService(idClient *viraid.Client, repo types.UserProfileRepository, p
 }
 
 func (s *AuthService) RegisterProxy(ctx context.Context, req types.RegisterRequest, extra types.ProfileData, ip, userAgent string) (*types.DevAuthResponse, error) {
-	authResp, err := s.IDClient.Register(ctx, req)
+	authResp, err := s.IDClient.Register(ctx, req, userAgent)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка регистрации через vira-id: %w", err)
 	}
@@ -59,7 +59,7 @@ func (s *AuthService) RegisterProxy(ctx context.Context, req types.RegisterReque
 		}
 	}
 
-	go s.emitDevRegisterEvent(ctx, uid, extra.City)
+	go s.emitDevRegisterEvent(context.WithoutCancel(ctx), uid, extra.City)
 
 	return &types.DevAuthResponse{
 		Tokens:  authResp.Tokens,
@@ -68,7 +68,7 @@ func (s *AuthService) RegisterProxy(ctx context.Context, req types.RegisterReque
 }
 
 func (s *AuthService) LoginProxy(ctx context.Context, req types.LoginRequest, ip, userAgent string) (*types.DevAuthResponse, error) {
-	authResp, err := s.IDClient.Login(ctx, req)
+	authResp, err := s.IDClient.Login(ctx, req, userAgent)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка авторизации через vira-id: %w", err)
 	}
@@ -90,7 +90,7 @@ func (s *AuthService) LoginProxy(ctx context.Context, req types.LoginRequest, ip
 		}
 	}
 
-	go s.emitDevLoginEvent(ctx, uid, ip, userAgent)
+	go s.emitDevLoginEvent(context.WithoutCancel(ctx), uid, ip, userAgent)
 
 	return &types.DevAuthResponse{
 		Tokens:  authResp.Tokens,
@@ -1,61 +1,88 @@
 package viraid
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
+	"time"
 	"vira-api-dev/internal/types"
+
+	"github.com/skrolikov/vira/pkg/identity"
+	"github.com/skrolikov/vira/pkg/tracing"
+	viraidv1 "github.com/skrolikov/vira/services/vira-id/api/viraid/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// callTimeout — предел одного вызова vira-id.
+const callTimeout = 10 * time.Second
+
 type Client struct {
-	BaseURL    string
-	HTTPClient *http.Client
+	conn *grpc.ClientConn
+	api  viraidv1.IdentityServiceClient
+}
+
+// NewClient создаёт gRPC клиент vira-id по адресу host:port. Соединение
+// открывается при первом вызове. Вызовы идут с клиентскими спанами,
+// чтобы vira-id продолжал трассу вызывающего.
+func NewClient(addr string) (*Client, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(tracing.GRPCClientHandler()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания клиента vira-id: %w", err)
+	}
+	return &Client{conn: conn, api: viraidv1.NewIdentityServiceClient(conn)}, nil
 }
 
-func NewClient(baseURL string) *Client {
-	return &Client{BaseURL: baseURL, HTTPClient: &http.Client{}}
+// Close закрывает соединение с vira-id.
+func (c *Client) Close() error {
+	return c.conn.Close()
 }
 
-// Register
-func (c *Client) Register(ctx context.Context, req types.RegisterRequest) (*types.AuthResponse, error) {
-	buf := new(bytes.Buffer)
-	if err := json.NewEncoder(buf).Encode(req); err != nil {
-		return nil, err
-	}
-	resp, err := c.HTTPClient.Post(c.BaseURL+"/register", "application/json", buf)
+// Register вызывает vira-id от имени клиента: подписанная gateway личность
+// (с IP клиента) и User-Agent передаются в метаданных, и защита входа
+// vira-id считает попытки по клиенту, а не по адресу этого сервиса.
+func (c *Client) Register(ctx context.Context, req types.RegisterRequest, userAgent string) (*types.AuthResponse, error) {
+	ctx, cancel := context.WithTimeout(identity.Outgoing(ctx, userAgent), callTimeout)
+	defer cancel()
+
+	resp, err := c.api.Register(ctx, &viraidv1.RegisterRequest{
+		Username: req.Username,
+		Password: req.Password,
+		Email:    req.Email,
+	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("vira-id register: %w", err)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("vira-id register: status %d", resp.StatusCode)
-	}
-	var out types.AuthResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return nil, err
-	}
-	return &out, nil
+	return authResponse(resp), nil
 }
 
-// Login
-func (c *Client) Login(ctx context.Context, req types.LoginRequest) (*types.AuthResponse, error) {
-	buf := new(bytes.Buffer)
-	if err := json.NewEncoder(buf).Encode(req); err != nil {
-		return nil, err
-	}
-	resp, err := c.HTTPClient.Post(c.BaseURL+"/login", "application/json", buf)
+// Login вызывает vira-id от имени клиента: подписанная gateway личность
+// (с IP клиента) и User-Agent передаются в метаданных, и защита входа
+// vira-id считает попытки по клиенту, а не по адресу этого сервиса.
+func (c *Client) Login(ctx context.Context, req types.LoginRequest, userAgent string) (*types.AuthResponse, error) {
+	ctx, cancel := context.WithTimeout(identity.Outgoing(ctx, userAgent), callTimeout)
+	defer cancel()
+
+	resp, err := c.api.Login(ctx, &viraidv1.LoginRequest{
+		Username: req.Username,
+		Password: req.Password,
+	})
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("vira-id login: status %d", resp.StatusCode)
+		return nil, fmt.Errorf("vira-id login: %w", err)
 	}
+	return authResponse(resp), nil
+}
+
+func authResponse(resp *viraidv1.AuthResponse) *types.AuthResponse {
 	var out types.AuthResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return nil, err
+	out.Tokens = types.TokenPair{
+		Access:  resp.GetTokens().GetAccessToken(),
+		Refresh: resp.GetTokens().GetRefreshToken(),
 	}
-	return &out, nil
+	out.User.ID = resp.GetUser().GetId()
+	out.User.Username = resp.GetUser().GetUsername()
+	out.User.Role = resp.GetUser().GetRole()
+	return &out
 }
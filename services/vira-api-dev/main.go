@@ -3,31 +3,43 @@ package main
 import (
 	"context"
 	"database/sql"
-	"net/http"
+	"flag"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	_ "github.com/lib/pq"
 
-	"vira-api-dev/internal/handlers"
+	"vira-api-dev/docs"
 	"vira-api-dev/internal/repo"
 	"vira-api-dev/internal/service"
 	"vira-api-dev/internal/viraid"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	kafkago "github.com/segmentio/kafka-go"
 	config "github.com/skrolikov/vira-config"
 	kafka "github.com/skrolikov/vira-kafka"
 	log "github.com/skrolikov/vira-logger"
-	middleware "github.com/skrolikov/vira-middleware"
 	redisdb "github.com/skrolikov/vira-redisdb"
+	"github.com/skrolikov/vira/pkg/apidocs"
+	"github.com/skrolikov/vira/pkg/identity"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
+	"github.com/skrolikov/vira/pkg/server"
+	"github.com/skrolikov/vira/pkg/tracing"
 )
 
+// @title Vira DEV API
+// @version 1.0
+// @description Сервис профилей разработчиков Vira.
+// @host vira-api-dev:8080
+// @BasePath /
+//
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description Access токен: "Bearer <token>"
 func main() {
-	cfg := config.Load()
+	checkOpenAPI := flag.Bool("check-openapi", false, "сверить OpenAPI документ с маршрутами и выйти")
+	flag.Parse()
+
 	ctx := context.Background()
 
 	baseLogger := log.New(log.Config{
@@ -42,8 +54,36 @@ func main() {
 		Compress:   true,
 	})
 
+	// Проверка документации не требует окружения и подключений
+	if *checkOpenAPI {
+		os.Exit(checkDocs(baseLogger))
+	}
+
+	cfg := config.Load()
 	baseLogger.Info("🚀 Запуск Vira-DEV")
 
+	// Трассировка OpenTelemetry
+	shutdownTracing, err := tracing.Init(ctx, "vira-api-dev", baseLogger.WithFields(map[string]any{"component": "tracing"}))
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка инициализации трассировки: %v", err)
+	}
+
+	// HTTP сервер: таймауты, лимит тела, плавная остановка
+	srvCfg, err := server.FromEnv(":" + cfg.Port)
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек сервера: %v", err)
+	}
+	srv := server.New(srvCfg, baseLogger)
+
+	// Подписанная gateway личность: без неё запросы мимо gateway не проходят
+	identityCfg, err := identity.FromEnv()
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек личности: %v", err)
+	}
+	if identityCfg.Secret == "" {
+		baseLogger.Warn("⚠️ IDENTITY_SECRET не задан: пользователь определяется только по JWT")
+	}
+
 	// Подключение к Redis
 	redisLogger := baseLogger.WithFields(map[string]any{"component": "redis"})
 	redisConn, err := redisdb.New(ctx, redisdb.Config{
@@ -54,7 +94,6 @@ func main() {
 	if err != nil {
 		redisLogger.Fatal("❌ Ошибка подключения к Redis: %v", err)
 	}
-	defer redisConn.Close()
 	rdb := redisConn.Client()
 
 	// Подключение к PostgreSQL
@@ -62,15 +101,14 @@ func main() {
 	if err != nil {
 		baseLogger.Fatal("❌ Ошибка подключения к БД: %v", err)
 	}
-	defer db.Close()
 
 	if err := db.Ping(); err != nil {
 		baseLogger.Fatal("❌ Ping к БД не прошёл: %v", err)
 	}
 
-	// Настройка Kafka Producer
+	// Настройка Kafka Producer (контекст трассировки идёт в заголовках сообщений)
 	kafkaLogger := baseLogger.WithFields(map[string]any{"component": "kafka"})
-	producer := kafka.NewProducer(kafka.ProducerConfig{
+	producer := kafkatrace.NewProducer("vira-api-dev", kafka.ProducerConfig{
 		Brokers:      []string{cfg.KafkaAddr},
 		Topic:        "vira-dev.users",
 		BatchTimeout: 100 * time.Millisecond,
@@ -81,68 +119,61 @@ func main() {
 		MaxAttempts:  5,
 
 		Logger: kafkaLogger,
-		Tracer: nil, // future: otel.Tracer("vira-api-dev")
 	})
-	defer func() {
-		if err := producer.Close(); err != nil {
-			kafkaLogger.Error("Ошибка закрытия Kafka продюсера: %v", err)
-		}
-	}()
-
-	// Инициализация зависимостей
-	idClient := viraid.NewClient(cfg.ViraIDEndpoint)
+
+	// Инициализация зависимостей: клиент Vira-ID по gRPC (VIRA_ID_GRPC_ADDR)
+	idAddr := os.Getenv("VIRA_ID_GRPC_ADDR")
+	if idAddr == "" {
+		idAddr = "vira-id:9090"
+	}
+	idClient, err := viraid.NewClient(idAddr)
+	if err != nil {
+		baseLogger.Fatal("❌ %v", err)
+	}
 	userRepo := repo.NewUserProfileRepo(db)
 	authService := service.NewAuthService(idClient, userRepo, producer, baseLogger)
 
-	// HTTP роутер
-	r := chi.NewRouter()
-	r.Use(middleware.RequestID())
-	r.Use(middleware.ContextLogger(baseLogger))
-
-	r.Post("/register", handlers.RegisterHandler(authService))
-	r.Post("/login", handlers.LoginHandler(authService))
-
-	// Пример Redis-маршрута
-	r.Get("/redis-test", func(w http.ResponseWriter, r *http.Request) {
-		logger := baseLogger.WithContext(r.Context())
-		if err := rdb.Set(r.Context(), "test_key", "123", 0).Err(); err != nil {
-			logger.WithFields(map[string]any{"err": err}).Error("Ошибка Redis")
-			http.Error(w, "ошибка Redis", http.StatusInternalServerError)
-			return
-		}
-		val, _ := rdb.Get(r.Context(), "test_key").Result()
-		w.Write([]byte("Redis работает, значение: " + val))
+	r := newRouter(routerDeps{
+		cfg:      cfg,
+		logger:   baseLogger,
+		identity: identityCfg,
+		auth:     authService,
+		profiles: userRepo,
+		db:       db,
+		rdb:      rdb,
+		ready:    srv.Ready,
 	})
 
-	// Метрики Prometheus
-	r.Get("/metrics", promhttp.Handler().ServeHTTP)
+	// Порядок закрытия: продюсер дописывает события, затем хранилища
+	srv.OnShutdown("Kafka продюсер", producer.Close)
+	srv.OnShutdown("клиент vira-id", idClient.Close)
+	srv.OnShutdown("Redis", redisConn.Close)
+	srv.OnShutdown("БД", db.Close)
+	srv.OnShutdown("трассировка", func() error {
+		return shutdownTracing(context.Background())
+	})
 
-	// Запуск HTTP-сервера
-	srv := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: r,
+	baseLogger.Info("✅ Vira-DEV запущен на порту %s", cfg.Port)
+	if err := srv.Run(r); err != nil {
+		baseLogger.Fatal("❌ Ошибка запуска сервера: %v", err)
 	}
+}
 
-	go func() {
-		baseLogger.Info("✅ Vira-DEV запущен на порту %s", cfg.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			baseLogger.Fatal("❌ Ошибка запуска сервера: %v", err)
-		}
-	}()
-
-	// Graceful shutdown
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-
-	<-stop
-	baseLogger.Info("🛑 Остановка сервера...")
-
-	ctxTimeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := srv.Shutdown(ctxTimeout); err != nil {
-		baseLogger.Error("Ошибка при остановке сервера: %v", err)
-	} else {
-		baseLogger.Info("✅ Сервер успешно остановлен")
+// checkDocs сверяет OpenAPI документ с маршрутами роутера. Возвращает код выхода.
+func checkDocs(logger *log.Logger) int {
+	r := newRouter(routerDeps{cfg: &config.Config{}, logger: logger})
+	drift, err := apidocs.Check(r, docs.SwaggerInfo.ReadDoc())
+	if err != nil {
+		logger.Error("❌ %v", err)
+		return 1
+	}
+	for _, d := range drift {
+		logger.Error("❌ OpenAPI: %s", d)
+	}
+	if len(drift) > 0 {
+		logger.Error("❌ OpenAPI документ расходится с маршрутами, обновите аннотации и выполните swag init")
+		return 1
 	}
+	logger.Info("✅ OpenAPI документ совпадает с маршрутами")
+	return 0
 }
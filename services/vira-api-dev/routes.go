@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+
+	"vira-api-dev/docs"
+	"vira-api-dev/internal/courses"
+	"vira-api-dev/internal/handlers"
+	"vira-api-dev/internal/service"
+	"vira-api-dev/internal/types"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	config "github.com/skrolikov/vira-config"
+	log "github.com/skrolikov/vira-logger"
+	middleware "github.com/skrolikov/vira-middleware"
+	"github.com/skrolikov/vira/pkg/apidocs"
+	"github.com/skrolikov/vira/pkg/identity"
+	"github.com/skrolikov/vira/pkg/tracing"
+)
+
+// routerDeps — зависимости обработчиков. В режиме -check-openapi роутер
+// собирается без подключений к БД, Redis и Kafka, и поля остаются пустыми.
+type routerDeps struct {
+	cfg      *config.Config
+	logger   *log.Logger
+	identity identity.Config
+	auth     *service.AuthService
+	profiles types.UserProfileRepository
+	db       *sql.DB
+	rdb      *redis.Client
+	ready    func() bool
+}
+
+func newRouter(d routerDeps) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware("vira-api-dev"))
+	r.Use(middleware.RequestID())
+	r.Use(middleware.ContextLogger(d.logger))
+
+	// Подпись gateway проверяется и на входе: она передаётся в vira-id
+	// вместе с IP клиента (viraid.Client)
+	public := r.With(identity.Optional(d.identity, d.logger))
+	public.Post("/register", handlers.RegisterHandler(d.auth))
+	public.Post("/login", handlers.LoginHandler(d.auth))
+
+	// Каталог курсов: публичный, кэшируется gateway
+	r.Get("/courses", courses.GetCoursesHandler())
+
+	r.Group(func(r chi.Router) {
+		r.Use(identity.Auth(d.identity, d.cfg, d.logger))
+		r.Get("/me", handlers.ProfileHandler(d.profiles))
+	})
+
+	// OpenAPI документ для каталога gateway
+	r.Get(apidocs.Path, apidocs.Handler(docs.SwaggerInfo.ReadDoc()))
+
+	// Проверка здоровья для gateway: БД и Redis должны отвечать,
+	// а во время остановки — 503, чтобы gateway снял экземпляр
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !d.ready() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		if err := d.db.PingContext(r.Context()); err != nil {
+			http.Error(w, "db: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err := d.rdb.Ping(r.Context()).Err(); err != nil {
+			http.Error(w, "redis: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	// Пример Redis-маршрута
+	r.Get("/redis-test", func(w http.ResponseWriter, r *http.Request) {
+		logger := d.logger.WithContext(r.Context())
+		if err := d.rdb.Set(r.Context(), "test_key", "123", 0).Err(); err != nil {
+			logger.WithFields(map[string]any{"err": err}).Error("Ошибка Redis")
+			http.Error(w, "ошибка Redis", http.StatusInternalServerError)
+			return
+		}
+		val, _ := d.rdb.Get(r.Context(), "test_key").Result()
+		w.Write([]byte("Redis работает, значение: " + val))
+	})
+
+	// Метрики Prometheus
+	r.Get("/metrics", promhttp.Handler().ServeHTTP)
+
+	return r
+}
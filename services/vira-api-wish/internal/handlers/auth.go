@@ -7,17 +7,25 @@ import (
 	"vira-api-wish/internal/types"
 )
 
+// RegisterHandler регистрирует пользователя в vira-id и создаёт локальный профиль
+//
+// @Summary      Регистрация
+// @Description  Регистрация в vira-id и создание профиля
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.RegisterProfileRequest  true  "Данные регистрации"
+// @Success      201      {object}  types.DevAuthResponse
+// @Failure      400      {string}  string  "Неверный запрос или ошибка регистрации"
+// @Router       /register [post]
 func RegisterHandler(svc *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var in struct {
-			types.RegisterRequest
-			City string `json:"city"`
-		}
+		var in types.RegisterProfileRequest
 		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
-		resp, err := svc.RegisterProxy(r.Context(), in.RegisterRequest, types.ProfileData{City: in.City})
+		resp, err := svc.RegisterProxy(r.Context(), in.RegisterRequest, types.ProfileData{City: in.City}, r.UserAgent())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -28,6 +36,18 @@ func RegisterHandler(svc *service.AuthService) http.HandlerFunc {
 	}
 }
 
+// LoginHandler авторизует пользователя через vira-id и возвращает профиль
+//
+// @Summary      Вход
+// @Description  Авторизация по логину и паролю через vira-id
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.LoginRequest  true  "Данные входа"
+// @Success      200      {object}  types.DevAuthResponse
+// @Failure      400      {string}  string  "Неверный формат запроса"
+// @Failure      401      {string}  string  "Неверный логин или пароль"
+// @Router       /login [post]
 func LoginHandler(svc *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req types.LoginRequest
@@ -35,7 +55,7 @@ func LoginHandler(svc *service.AuthService) http.HandlerFunc {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
-		resp, err := svc.LoginProxy(r.Context(), req)
+		resp, err := svc.LoginProxy(r.Context(), req, r.UserAgent())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
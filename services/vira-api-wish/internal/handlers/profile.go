@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"vira-api-wish/internal/types"
+
+	middleware "github.com/skrolikov/vira-middleware"
+)
+
+// ProfileHandler возвращает профиль текущего пользователя в модуле.
+// 404 означает, что пользователь не зарегистрирован в модуле.
+//
+// @Summary      Профиль текущего пользователя
+// @Description  Возвращает профиль модуля по access токену
+// @Tags         profile
+// @Produce      json
+// @Success      200  {object}  types.UserProfile
+// @Failure      401  {string}  string  "Пользователь не аутентифицирован"
+// @Failure      404  {string}  string  "Профиль не найден"
+// @Failure      500  {string}  string  "Ошибка чтения профиля"
+// @Security     ApiKeyAuth
+// @Router       /me [get]
+func ProfileHandler(repo types.UserProfileRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := middleware.GetUserID(r)
+		if userID == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		profile, err := repo.GetByUserID(r.Context(), userID)
+		if errors.Is(err, types.ErrProfileNotFound) {
+			http.Error(w, "profile not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "profile read failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile)
+	}
+}
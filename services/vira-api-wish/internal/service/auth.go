@@ -17,8 +17,8 @@ func NewAuthService(idClient *viraid.Client, repo types.UserProfileRepository) *
 	return &AuthService{IDClient: idClient, Repo: repo}
 }
 
-func (s *AuthService) RegisterProxy(ctx context.Context, req types.RegisterRequest, extra types.ProfileData) (*types.DevAuthResponse, error) {
-	authResp, err := s.IDClient.Register(ctx, req)
+func (s *AuthService) RegisterProxy(ctx context.Context, req types.RegisterRequest, extra types.ProfileData, userAgent string) (*types.DevAuthResponse, error) {
+	authResp, err := s.IDClient.Register(ctx, req, userAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -49,8 +49,8 @@ func (s *AuthService) RegisterProxy(ctx context.Context, req types.RegisterReque
 	}, nil
 }
 
-func (s *AuthService) LoginProxy(ctx context.Context, req types.LoginRequest) (*types.DevAuthResponse, error) {
-	authResp, err := s.IDClient.Login(ctx, req)
+func (s *AuthService) LoginProxy(ctx context.Context, req types.LoginRequest, userAgent string) (*types.DevAuthResponse, error) {
+	authResp, err := s.IDClient.Login(ctx, req, userAgent)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +45,12 @@ type ProfileData struct {
 	City string `json:"city"`
 }
 
+// Запрос регистрации от клиента: учётные данные для vira-id и профиль
+type RegisterProfileRequest struct {
+	RegisterRequest
+	City string `json:"city"`
+}
+
 // Ошибка «профиль не найден»
 var ErrProfileNotFound = errors.New("profile not found")
 
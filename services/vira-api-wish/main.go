@@ -3,21 +3,37 @@ package main
 import (
 	"context"
 	"database/sql"
-	"net/http"
-	"vira-api-wish/internal/handlers"
+	"flag"
+	"os"
+	"vira-api-wish/docs"
 	"vira-api-wish/internal/repo"
 	"vira-api-wish/internal/service"
 	"vira-api-wish/internal/viraid"
 
-	"github.com/go-chi/chi/v5"
 	_ "github.com/lib/pq"
 	config "github.com/skrolikov/vira-config"
 	log "github.com/skrolikov/vira-logger"
 	redisdb "github.com/skrolikov/vira-redisdb"
+	"github.com/skrolikov/vira/pkg/apidocs"
+	"github.com/skrolikov/vira/pkg/identity"
+	"github.com/skrolikov/vira/pkg/server"
+	"github.com/skrolikov/vira/pkg/tracing"
 )
 
+// @title Vira Wish API
+// @version 1.0
+// @description Сервис профилей Vira-Wish.
+// @host vira-api-wish:8080
+// @BasePath /
+//
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description Access токен: "Bearer <token>"
 func main() {
-	cfg := config.Load()
+	checkOpenAPI := flag.Bool("check-openapi", false, "сверить OpenAPI документ с маршрутами и выйти")
+	flag.Parse()
+
 	ctx := context.Background()
 
 	// создаём базовый логгер (без вызова WithContext)
@@ -33,8 +49,36 @@ func main() {
 		Compress:   true,
 	})
 
+	// Проверка документации не требует окружения и подключений
+	if *checkOpenAPI {
+		os.Exit(checkDocs(baseLogger))
+	}
+
+	cfg := config.Load()
 	baseLogger.Info("🚀 Запуск Vira-Wish")
 
+	// Трассировка OpenTelemetry
+	shutdownTracing, err := tracing.Init(ctx, "vira-api-wish", baseLogger.WithFields(map[string]any{"component": "tracing"}))
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка инициализации трассировки: %v", err)
+	}
+
+	// HTTP сервер: таймауты, лимит тела, плавная остановка
+	srvCfg, err := server.FromEnv(":" + cfg.Port)
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек сервера: %v", err)
+	}
+	srv := server.New(srvCfg, baseLogger)
+
+	// Подписанная gateway личность: без неё запросы мимо gateway не проходят
+	identityCfg, err := identity.FromEnv()
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек личности: %v", err)
+	}
+	if identityCfg.Secret == "" {
+		baseLogger.Warn("⚠️ IDENTITY_SECRET не задан: пользователь определяется только по JWT")
+	}
+
 	// Redis
 	redisConn, err := redisdb.New(ctx, redisdb.Config{
 		Addr:     cfg.RedisAddr,
@@ -46,7 +90,6 @@ func main() {
 	if err != nil {
 		baseLogger.Fatal("❌ Ошибка подключения к Redis: %v", err)
 	}
-	defer redisConn.Close()
 	rdb := redisConn.Client()
 
 	// БД Postgres
@@ -54,44 +97,65 @@ func main() {
 	if err != nil {
 		baseLogger.Fatal("db connect: %v", err)
 	}
-	defer func() {
-		if err := db.Close(); err != nil {
-			baseLogger.Error("failed to close database connection: %v", err)
-		}
-	}()
 
 	// Проверка соединения с БД
 	if err := db.Ping(); err != nil {
 		baseLogger.Fatal("db ping failed: %v", err)
 	}
 
-	// Клиент Vira-ID
-	idClient := viraid.NewClient(cfg.ViraIDEndpoint)
+	// Клиент Vira-ID по gRPC (VIRA_ID_GRPC_ADDR)
+	idAddr := os.Getenv("VIRA_ID_GRPC_ADDR")
+	if idAddr == "" {
+		idAddr = "vira-id:9090"
+	}
+	idClient, err := viraid.NewClient(idAddr)
+	if err != nil {
+		baseLogger.Fatal("❌ %v", err)
+	}
 
 	// Репозиторий и сервис
 	upr := repo.NewUserProfileRepo(db)
 	authSvc := service.NewAuthService(idClient, upr)
 
-	r := chi.NewRouter()
-
-	r.Post("/register", handlers.RegisterHandler(authSvc))
-	r.Post("/login", handlers.LoginHandler(authSvc))
-
-	r.Get("/redis-test", func(w http.ResponseWriter, r *http.Request) {
-		logger := baseLogger.WithContext(r.Context())
-		if err := rdb.Set(r.Context(), "test_key", "123", 0).Err(); err != nil {
-			logger.WithFields(map[string]any{
-				"err": err,
-			}).Error("Ошибка при записи в Redis")
-			http.Error(w, "ошибка Redis", http.StatusInternalServerError)
-			return
-		}
-		val, _ := rdb.Get(r.Context(), "test_key").Result()
-		w.Write([]byte("Redis работает, значение: " + val))
+	r := newRouter(routerDeps{
+		cfg:      cfg,
+		logger:   baseLogger,
+		identity: identityCfg,
+		auth:     authSvc,
+		profiles: upr,
+		db:       db,
+		rdb:      rdb,
+		ready:    srv.Ready,
+	})
+
+	srv.OnShutdown("клиент vira-id", idClient.Close)
+	srv.OnShutdown("Redis", redisConn.Close)
+	srv.OnShutdown("БД", db.Close)
+	srv.OnShutdown("трассировка", func() error {
+		return shutdownTracing(context.Background())
 	})
 
 	baseLogger.Info("✅ Vira-Wish запущен на порту %s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
+	if err := srv.Run(r); err != nil {
 		baseLogger.Fatal("❌ Ошибка запуска сервера: %v", err)
 	}
 }
+
+// checkDocs сверяет OpenAPI документ с маршрутами роутера. Возвращает код выхода.
+func checkDocs(logger *log.Logger) int {
+	r := newRouter(routerDeps{cfg: &config.Config{}, logger: logger})
+	drift, err := apidocs.Check(r, docs.SwaggerInfo.ReadDoc())
+	if err != nil {
+		logger.Error("❌ %v", err)
+		return 1
+	}
+	for _, d := range drift {
+		logger.Error("❌ OpenAPI: %s", d)
+	}
+	if len(drift) > 0 {
+		logger.Error("❌ OpenAPI документ расходится с маршрутами, обновите аннотации и выполните swag init")
+		return 1
+	}
+	logger.Info("✅ OpenAPI документ совпадает с маршрутами")
+	return 0
+}
@@ -8,14 +8,22 @@ import (
 	"syscall"
 	"time"
 
-	// если нужен какой-то сервис
+	kafkago "github.com/segmentio/kafka-go"
 	config "github.com/skrolikov/vira-config"
 	db "github.com/skrolikov/vira-db"
 	kafka "github.com/skrolikov/vira-kafka"
 	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
+	"github.com/skrolikov/vira/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type UserLoggedInEvent struct {
+// UserEvent — сообщение topic-а vira-events. Старое событие входа
+// (events.LoginEvent) отличается полем type, события пользователя
+// (events.UserEventPayload) — полем event_type.
+type UserEvent struct {
 	Type      string `json:"type"`
 	UserID    string `json:"user_id"`
 	Username  string `json:"username"`
@@ -25,8 +33,19 @@ type UserLoggedInEvent struct {
 	SessionID string `json:"session_id"`
 	Success   bool   `json:"success"`
 	Device    string `json:"device"`
+
+	EventType string         `json:"event_type"`
+	Timestamp time.Time      `json:"timestamp"`
+	Metadata  map[string]any `json:"metadata"`
 }
 
+// События vira-id (events.EventType), которые обрабатывает consumer.
+const (
+	eventLoggedIn    = "user.logged_in"
+	eventLoginFailed = "user.login_failed"
+	eventLocked      = "user.locked"
+)
+
 func main() {
 	cfg := config.Load()
 
@@ -43,6 +62,14 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Трассировка OpenTelemetry
+	shutdownTracing, err := tracing.Init(ctx, "vira-events-consumer", logger.WithFields(map[string]any{"component": "tracing"}))
+	if err != nil {
+		logger.Fatal("❌ Ошибка инициализации трассировки: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	tracer := otel.Tracer("vira-events-consumer")
+
 	// graceful shutdown по сигналам
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
@@ -78,9 +105,8 @@ func main() {
 		MaxRetryAttempts:  3,
 		RetryBackoff:      200 * time.Millisecond,
 
-		// при желании можно прокинуть метрики и трейсер:
 		Metrics: nil,
-		Tracer:  nil,
+		Tracer:  tracer,
 
 		Logger: logger.WithFields(map[string]any{"component": "kafka-consumer"}),
 	})
@@ -92,6 +118,67 @@ func main() {
 
 	logger.Info("🛰️ Kafka consumer готов к работе")
 
+	// process обрабатывает одно сообщение в контексте его трассы.
+	// Входы и неудачные попытки входа сохраняются в историю входов,
+	// блокировка входа — только в лог: в истории ей нет места.
+	process := func(ctx context.Context, msg kafkago.Message) {
+		// декодируем
+		var event UserEvent
+		if err := consumer.DecodeMessage(msg, &event); err != nil {
+			logger.Error("Ошибка декодирования сообщения: %v", err)
+			consumer.CommitMessage(ctx, msg) // пропускаем «сломанное» сообщение
+			return
+		}
+
+		switch {
+		case event.Type == "UserLoggedIn":
+			// парсим время
+			parsedTime, err := time.Parse(time.RFC3339, event.Time)
+			if err != nil {
+				logger.Warn("Некорректное время в событии %q, ставим now: %v", event.Time, err)
+				parsedTime = time.Now()
+			}
+			event.Timestamp = parsedTime
+
+		case event.EventType == eventLoggedIn, event.EventType == eventLoginFailed:
+			event.UA = event.Device
+			event.Success = event.EventType == eventLoggedIn
+
+		case event.EventType == eventLocked:
+			logger.WithContext(ctx).Warn("🔒 Вход заблокирован: user_id=%s username=%s ip=%s на %v (до %v)",
+				event.UserID, event.Username, event.IP, event.Metadata["locked_for"], event.Metadata["locked_until"])
+			consumer.CommitMessage(ctx, msg)
+			return
+
+		default:
+			// просто метим как обработанное
+			consumer.CommitMessage(ctx, msg)
+			return
+		}
+
+		// сохраняем в БД
+		if err := userLoginRepo.Save(ctx,
+			event.UserID,
+			event.Username,
+			event.IP,
+			event.UA,
+			event.SessionID,
+			event.Timestamp,
+			event.Success,
+			event.Device,
+		); err != nil {
+			logger.Error("Ошибка сохранения события в БД: %v", err)
+			return
+		}
+
+		// подтверждаем оффсет
+		if err := consumer.CommitMessage(ctx, msg); err != nil {
+			logger.Error("Не удалось закоммитить сообщение: %v", err)
+		}
+
+		logger.Info("✅ Вход сохранён: user_id=%s username=%s success=%t", event.UserID, event.Username, event.Success)
+	}
+
 	// --- Основной цикл обработки через каналы ---
 	msgCh, errCh := consumer.Consume(ctx)
 outer:
@@ -110,48 +197,19 @@ outer:
 				break outer
 			}
 
-			// декодируем
-			var event UserLoggedInEvent
-			if err := consumer.DecodeMessage(msg, &event); err != nil {
-				logger.Error("Ошибка декодирования сообщения: %v", err)
-				consumer.CommitMessage(ctx, msg) // пропускаем «сломанное» сообщение
-				continue
-			}
-
-			if event.Type != "UserLoggedIn" {
-				// просто метим как обработанное
-				consumer.CommitMessage(ctx, msg)
-				continue
-			}
-
-			// парсим время
-			parsedTime, err := time.Parse(time.RFC3339, event.Time)
-			if err != nil {
-				logger.Warn("Некорректное время в событии %q, ставим now: %v", event.Time, err)
-				parsedTime = time.Now()
-			}
-
-			// сохраняем в БД
-			if err := userLoginRepo.Save(ctx,
-				event.UserID,
-				event.Username,
-				event.IP,
-				event.UA,
-				event.SessionID,
-				parsedTime,
-				event.Success,
-				event.Device,
-			); err != nil {
-				logger.Error("Ошибка сохранения события в БД: %v", err)
-				continue
-			}
-
-			// подтверждаем оффсет
-			if err := consumer.CommitMessage(ctx, msg); err != nil {
-				logger.Error("Не удалось закоммитить сообщение: %v", err)
-			}
-
-			logger.Info("✅ UserLoggedIn сохранён: user_id=%s username=%s", event.UserID, event.Username)
+			// Продолжаем трассу продюсера из заголовков сообщения
+			msgCtx := otel.GetTextMapPropagator().Extract(ctx, kafkatrace.HeaderCarrier{Headers: &msg.Headers})
+			msgCtx, span := tracer.Start(msgCtx, msg.Topic+" process",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					attribute.String("messaging.system", "kafka"),
+					attribute.String("messaging.destination.name", msg.Topic),
+					attribute.Int("messaging.kafka.destination.partition", msg.Partition),
+					attribute.Int64("messaging.kafka.message.offset", msg.Offset),
+				),
+			)
+			process(msgCtx, msg)
+			span.End()
 		}
 	}
 
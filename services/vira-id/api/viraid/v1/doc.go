@@ -0,0 +1,9 @@
+// Package viraidv1 — gRPC API Vira-ID (identity.proto): сервер, клиент
+// и HTTP/JSON шлюз. Модуль api отдельный: vira-id и модули, которые
+// ходят в vira-id, импортируют один и тот же сгенерированный код.
+//
+// Нужны protoc, protoc-gen-go, protoc-gen-go-grpc и protoc-gen-grpc-gateway
+// (google/api/annotations.proto — из googleapis в пути импорта protoc).
+package viraidv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative --grpc-gateway_out=../.. --grpc-gateway_opt=paths=source_relative viraid/v1/identity.proto
@@ -24,9 +24,14 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/auth/confirm": {
-            "get": {
-                "description": "Подтверждает пользователя по email и токену подтверждения. Требует обязательные параметры email и token в query string.",
+        "/admin/login/unlock": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Снимает блокировку и обнуляет счётчики неудачных попыток входа для имени пользователя и (или) IP. Только для администраторов.",
                 "consumes": [
                     "application/json"
                 ],
@@ -34,20 +39,59 @@ const docTemplate = `{
                     "text/plain"
                 ],
                 "tags": [
-                    "Auth"
+                    "admin"
                 ],
-                "summary": "Подтверждение пользователя",
+                "summary": "Разблокировать вход",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Email пользователя для подтверждения",
-                        "name": "email",
-                        "in": "query",
-                        "required": true
+                        "description": "Имя пользователя и (или) IP",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.UnlockLoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
+                    "401": {
+                        "description": "Пользователь не авторизован",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Нет роли admin",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/confirm": {
+            "get": {
+                "description": "Подтверждает email по токену из ссылки в письме. Ссылка ведёт на фронтенд (CONFIRM_URL), который передаёт токен сюда. Токен одноразовый и действует ограниченное время.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Подтверждение пользователя",
+                "parameters": [
                     {
                         "type": "string",
-                        "description": "Токен подтверждения, отправленный пользователю на email",
+                        "description": "Токен подтверждения из ссылки в письме",
                         "name": "token",
                         "in": "query",
                         "required": true
@@ -61,7 +105,53 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "необходимо указать email и токен\" или \"не удалось подтвердить пользователя: описание ошибки",
+                        "description": "Не указан токен, либо токен недействителен или устарел",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/confirm/resend": {
+            "post": {
+                "description": "Отправляет новую ссылку подтверждения, старая перестаёт действовать. Ответ одинаков для любых адресов, чтобы по нему нельзя было узнать, зарегистрирован ли email. Частота писем на адрес ограничена.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Повторное письмо подтверждения",
+                "parameters": [
+                    {
+                        "description": "Email",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.ResendConfirmRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Письмо отправлено, если адрес ожидает подтверждения",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Письмо отправлялось недавно, см. Retry-After",
                         "schema": {
                             "type": "string"
                         }
@@ -71,7 +161,7 @@ const docTemplate = `{
         },
         "/login": {
             "post": {
-                "description": "Авторизация по логину и паролю",
+                "description": "Авторизация по логину и паролю. После неудачной попытки следующая по тому же имени возможна через растущую задержку, серия неудач временно блокирует вход по имени или с IP (LOGIN_*)",
                 "consumes": [
                     "application/json"
                 ],
@@ -101,15 +191,21 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Неверный формат запроса",
                         "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
+                            "type": "string"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized",
+                        "description": "Неверный логин или пароль, либо email не подтверждён",
                         "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много неудачных попыток, см. Retry-After",
+                        "schema": {
+                            "type": "string"
                         }
                     }
                 }
@@ -117,13 +213,15 @@ const docTemplate = `{
         },
         "/logout": {
             "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "description": "Удаляет refresh токен и завершает сессию",
                 "consumes": [
                     "application/json"
                 ],
-                "produces": [
-                    "application/json"
-                ],
                 "tags": [
                     "auth"
                 ],
@@ -141,15 +239,18 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "204": {
-                        "description": "No Content",
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "401": {
+                        "description": "Неверный токен",
                         "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
+                            "type": "string"
                         }
                     }
                 }
@@ -162,10 +263,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Возвращает ID и имя пользователя, основываясь на информации из JWT (или другого механизма аутентификации).",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Возвращает ID и имя пользователя по access токену.",
                 "produces": [
                     "application/json"
                 ],
@@ -181,13 +279,13 @@ const docTemplate = `{
                         }
                     },
                     "401": {
-                        "description": "unauthorized\" \"Пользователь не аутентифицирован",
+                        "description": "Пользователь не аутентифицирован",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "user not found\" \"Пользователь не найден в базе данных",
+                        "description": "Пользователь не найден в базе данных",
                         "schema": {
                             "type": "string"
                         }
@@ -195,6 +293,149 @@ const docTemplate = `{
                 }
             }
         },
+        "/password/change": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Меняет пароль по текущему. Новый пароль проверяется по тем же правилам, что при регистрации, и не должен совпадать с текущим. Все сессии пользователя закрываются; с keep_current_session текущая остаётся открытой.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Смена пароля",
+                "parameters": [
+                    {
+                        "description": "Текущий и новый пароли",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.ChangePasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Новый пароль не соответствует требованиям (PASSWORD_*); неверный формат запроса — текстом",
+                        "schema": {
+                            "$ref": "#/definitions/types.ValidationErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Текущий пароль неверный",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много неудачных попыток, см. Retry-After",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/password/forgot": {
+            "post": {
+                "description": "Отправляет на email пользователя ссылку сброса пароля (PASSWORD_RESET_URL). Ответ одинаков для любых имён и адресов, чтобы по нему нельзя было узнать, есть ли учётная запись. Частота писем ограничена.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Забыли пароль",
+                "parameters": [
+                    {
+                        "description": "Имя пользователя или email",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.ForgotPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Письмо отправлено, если учётная запись существует",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат запроса",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Письмо отправлялось недавно, см. Retry-After",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/password/reset": {
+            "post": {
+                "description": "Задаёт новый пароль по одноразовому токену из письма и закрывает все сессии пользователя.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Сброс пароля",
+                "parameters": [
+                    {
+                        "description": "Токен и новый пароль",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.ResetPasswordRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Пароль не соответствует требованиям (PASSWORD_*); неверный формат запроса или недействительный токен — текстом",
+                        "schema": {
+                            "$ref": "#/definitions/types.ValidationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/refresh": {
             "post": {
                 "description": "Обновляет пару токенов по refresh-токену",
@@ -227,15 +468,15 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Неверный формат запроса",
                         "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
+                            "type": "string"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized",
+                        "description": "Неверный или отозванный токен",
                         "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
+                            "type": "string"
                         }
                     }
                 }
@@ -243,7 +484,7 @@ const docTemplate = `{
         },
         "/register": {
             "post": {
-                "description": "Создание нового пользователя",
+                "description": "Создание нового пользователя. На email уходит ссылка подтверждения: до подтверждения вход невозможен. Пароль проверяется по политике (длина, классы символов, имя пользователя, список утёкших паролей); ошибки возвращаются по полям",
                 "consumes": [
                     "application/json"
                 ],
@@ -273,15 +514,9 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
-                        }
-                    },
-                    "409": {
-                        "description": "Conflict",
+                        "description": "Ошибки полей; неверный JSON или существующий пользователь — текстом",
                         "schema": {
-                            "$ref": "#/definitions/types.ErrorResponse"
+                            "$ref": "#/definitions/types.ValidationErrorResponse"
                         }
                     }
                 }
@@ -295,9 +530,6 @@ const docTemplate = `{
                     }
                 ],
                 "description": "Возвращает список активных сессий текущего пользователя. Поддерживается пагинация с помощью параметра cursor.",
-                "consumes": [
-                    "application/json"
-                ],
                 "produces": [
                     "application/json"
                 ],
@@ -308,7 +540,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Курсор для пагинации (числовое значение). Если не указан, возвращает первые 20 сессий.",
+                        "description": "Курсор для пагинации. Если не указан, возвращает первые 20 сессий.",
                         "name": "cursor",
                         "in": "query"
                     }
@@ -321,13 +553,13 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid cursor\" \"Неверный формат курсора",
+                        "description": "Неверный формат курсора",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "401": {
-                        "description": "Unauthorized\" \"Пользователь не авторизован",
+                        "description": "Пользователь не авторизован",
                         "schema": {
                             "type": "string"
                         }
@@ -349,9 +581,6 @@ const docTemplate = `{
                     }
                 ],
                 "description": "Удаляет сессию пользователя из Redis по ID сессии. Также удаляет refresh-токен, если он совпадает с удаляемой сессией.",
-                "consumes": [
-                    "application/json"
-                ],
                 "produces": [
                     "text/plain"
                 ],
@@ -379,7 +608,7 @@ const docTemplate = `{
                         }
                     },
                     "401": {
-                        "description": "Unauthorized\" \"Пользователь не авторизован",
+                        "description": "Пользователь не авторизован",
                         "schema": {
                             "type": "string"
                         }
@@ -416,13 +645,58 @@ const docTemplate = `{
                 }
             }
         },
-        "types.ErrorResponse": {
+        "types.ChangePasswordRequest": {
             "type": "object",
             "properties": {
-                "error": {
-                    "description": "Текст ошибки",
+                "keep_current_session": {
+                    "description": "Не закрывать сессию, из которой пришёл запрос",
+                    "type": "boolean",
+                    "example": true
+                },
+                "new_password": {
+                    "description": "Новый пароль",
+                    "type": "string",
+                    "example": "NewSecret123"
+                },
+                "old_password": {
+                    "description": "Текущий пароль",
                     "type": "string",
-                    "example": "Неверный токен"
+                    "example": "secret123"
+                }
+            }
+        },
+        "types.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Машинный код ошибки",
+                    "type": "string",
+                    "example": "too_short"
+                },
+                "field": {
+                    "description": "Поле запроса",
+                    "type": "string",
+                    "example": "password"
+                },
+                "message": {
+                    "description": "Текст для пользователя",
+                    "type": "string",
+                    "example": "пароль должен содержать не менее 8 символов"
+                }
+            }
+        },
+        "types.ForgotPasswordRequest": {
+            "type": "object",
+            "properties": {
+                "language": {
+                    "description": "Язык письма: ru или en (по умолчанию из Accept-Language)",
+                    "type": "string",
+                    "example": "ru"
+                },
+                "login": {
+                    "description": "Имя пользователя или email",
+                    "type": "string",
+                    "example": "john@example.com"
                 }
             }
         },
@@ -465,14 +739,19 @@ const docTemplate = `{
             "type": "object",
             "properties": {
                 "email": {
-                    "description": "Email (опционально)",
+                    "description": "Email: на него приходит ссылка подтверждения",
                     "type": "string",
                     "example": "john@example.com"
                 },
+                "language": {
+                    "description": "Язык писем: ru или en (по умолчанию из Accept-Language)",
+                    "type": "string",
+                    "example": "ru"
+                },
                 "password": {
-                    "description": "Пароль",
+                    "description": "Пароль: требования задаёт PASSWORD_*",
                     "type": "string",
-                    "example": "secret123"
+                    "example": "Secret123"
                 },
                 "username": {
                     "description": "Имя пользователя",
@@ -481,6 +760,36 @@ const docTemplate = `{
                 }
             }
         },
+        "types.ResendConfirmRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "description": "Email, указанный при регистрации",
+                    "type": "string",
+                    "example": "john@example.com"
+                },
+                "language": {
+                    "description": "Язык письма: ru или en (по умолчанию из Accept-Language)",
+                    "type": "string",
+                    "example": "ru"
+                }
+            }
+        },
+        "types.ResetPasswordRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "description": "Новый пароль",
+                    "type": "string",
+                    "example": "NewSecret123"
+                },
+                "token": {
+                    "description": "Токен из ссылки в письме",
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015..."
+                }
+            }
+        },
         "types.SessionInfo": {
             "type": "object",
             "properties": {
@@ -548,6 +857,21 @@ const docTemplate = `{
                 }
             }
         },
+        "types.UnlockLoginRequest": {
+            "type": "object",
+            "properties": {
+                "ip": {
+                    "description": "IP-адрес",
+                    "type": "string",
+                    "example": "203.0.113.7"
+                },
+                "username": {
+                    "description": "Имя пользователя (как при входе)",
+                    "type": "string",
+                    "example": "john_doe"
+                }
+            }
+        },
         "types.UserInfo": {
             "type": "object",
             "properties": {
@@ -567,6 +891,31 @@ const docTemplate = `{
                     "example": "john_doe"
                 }
             }
+        },
+        "types.ValidationErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Все ошибки одной строкой",
+                    "type": "string",
+                    "example": "пароль должен содержать не менее 8 символов"
+                },
+                "fields": {
+                    "description": "Ошибки по полям",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.FieldError"
+                    }
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "Access токен: \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -574,7 +923,7 @@ const docTemplate = `{
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
-	Host:             "vira-id:8000",
+	Host:             "vira-id:8080",
 	BasePath:         "/",
 	Schemes:          []string{},
 	Title:            "Vira ID API",
@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"time"
 
-	kafka "github.com/skrolikov/vira-kafka"
 	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
 )
 
 // LoginEvent представляет событие входа пользователя в систему.
@@ -59,7 +59,7 @@ type LoginSession interface {
 //   - s: сессия входа, реализующая интерфейс LoginSession
 //
 // В случае ошибки сериализации или отправки события записывает предупреждение в лог.
-func EmitLoginEvent(ctx context.Context, producer *kafka.Producer, logger *log.Logger, userID, username string, s LoginSession) {
+func EmitLoginEvent(ctx context.Context, producer *kafkatrace.Producer, logger *log.Logger, userID, username string, s LoginSession) {
 	event := LoginEvent{
 		Type:     "UserLoggedIn",
 		UserID:   userID,
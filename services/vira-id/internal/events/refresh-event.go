@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"time"
 
-	kafka "github.com/skrolikov/vira-kafka"
 	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
 )
 
 // RefreshEvent представляет событие обновления токена пользователя.
@@ -44,7 +44,7 @@ type RefreshEvent struct {
 //   - session: сессия пользователя, реализующая интерфейс LoginSession для получения IP и User-Agent
 //
 // В случае ошибки сериализации или отправки события логирует предупреждение.
-func EmitRefreshEvent(ctx context.Context, producer *kafka.Producer, logger *log.Logger, userID string, session LoginSession) {
+func EmitRefreshEvent(ctx context.Context, producer *kafkatrace.Producer, logger *log.Logger, userID string, session LoginSession) {
 	event := RefreshEvent{
 		Type:   "UserTokenRefreshed",
 		UserID: userID,
@@ -9,8 +9,8 @@ import (
 	"time"
 	"vira-id/internal/metrics"
 
-	kafka "github.com/skrolikov/vira-kafka"
 	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
 )
 
 // EventType — тип события в системе сообщений
@@ -27,6 +27,18 @@ const (
 
 	// UserUpdatedEvent — событие обновления данных пользователя
 	UserUpdatedEvent EventType = "user.updated"
+
+	// UserPasswordResetEvent — событие сброса пароля по ссылке из письма
+	UserPasswordResetEvent EventType = "user.password_reset"
+
+	// UserPasswordChangedEvent — событие смены пароля пользователем
+	UserPasswordChangedEvent EventType = "user.password_changed"
+
+	// UserLoginFailedEvent — событие неудачного входа (неверный пароль)
+	UserLoginFailedEvent EventType = "user.login_failed"
+
+	// UserLockedEvent — событие блокировки входа после серии неудачных попыток
+	UserLockedEvent EventType = "user.locked"
 )
 
 // UserEventPayload — структура полезной нагрузки пользовательского события
@@ -76,12 +88,12 @@ type EventEmitter interface {
 
 // KafkaEventEmitter — реализация EventEmitter для Kafka
 type KafkaEventEmitter struct {
-	producer *kafka.Producer
+	producer *kafkatrace.Producer
 	logger   *log.Logger
 }
 
 // NewKafkaEventEmitter создаёт новый эмиттер для Kafka
-func NewKafkaEventEmitter(producer *kafka.Producer, logger *log.Logger) *KafkaEventEmitter {
+func NewKafkaEventEmitter(producer *kafkatrace.Producer, logger *log.Logger) *KafkaEventEmitter {
 	return &KafkaEventEmitter{producer: producer, logger: logger}
 }
 
@@ -124,16 +136,39 @@ func randomString(length int) string {
 	return fmt.Sprintf("%x", b)[:length]
 }
 
+// emitTimeout — предел отправки одного события.
+const emitTimeout = 5 * time.Second
+
+// Emit отправляет событие пользователя в Kafka и пишет ошибку в лог
+// (fire-and-forget). Вызывающий сам отвязывает ctx от отмены запроса
+// (context.WithoutCancel), если событие отправляется в горутине.
+// Без источника в Metadata подставляется auth_service.
+func Emit(ctx context.Context, producer *kafkatrace.Producer, logger *log.Logger, eventType EventType, payload UserEventPayload) {
+	ctx, cancel := context.WithTimeout(ctx, emitTimeout)
+	defer cancel()
+
+	if payload.Metadata == nil {
+		payload.Metadata = Metadata{}
+	}
+	if _, ok := payload.Metadata["source"]; !ok {
+		payload.Metadata["source"] = "auth_service"
+	}
+
+	if err := NewKafkaEventEmitter(producer, logger).EmitUserEvent(ctx, eventType, payload); err != nil {
+		logger.WithContext(ctx).Error("Ошибка при отправке события %s: %v", eventType, err)
+	}
+}
+
 // EmitUserRegisteredEvent отправляет событие регистрации пользователя в Kafka
 func EmitUserRegisteredEvent(
 	ctx context.Context,
-	producer *kafka.Producer,
+	producer *kafkatrace.Producer,
 	logger *log.Logger,
 	userID, username, ip, device string,
 	ts time.Time,
 ) error {
-	// Создаём отдельный контекст с таймаутом, чтобы не зависеть от контекста запроса
-	ctxKafka, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Отвязываемся от отмены контекста запроса, но сохраняем трассу
+	ctxKafka, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
 	defer cancel()
 
 	emitter := NewKafkaEventEmitter(producer, logger)
@@ -151,12 +186,12 @@ func EmitUserRegisteredEvent(
 // EmitUserLoggedInEvent отправляет событие входа пользователя в Kafka
 func EmitUserLoggedInEvent(
 	ctx context.Context,
-	producer *kafka.Producer,
+	producer *kafkatrace.Producer,
 	logger *log.Logger,
 	userID, username, ip, device string,
 ) {
-	// Создаём отдельный контекст с таймаутом, чтобы не зависеть от контекста запроса
-	ctxKafka, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Отвязываемся от отмены контекста запроса, но сохраняем трассу
+	ctxKafka, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
 	defer cancel()
 
 	emitter := NewKafkaEventEmitter(producer, logger)
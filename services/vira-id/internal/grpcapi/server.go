@@ -0,0 +1,354 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"vira-id/internal/service"
+	"vira-id/internal/types"
+	"vira-id/internal/validators"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	db "github.com/skrolikov/vira-db"
+	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/identity"
+	"github.com/skrolikov/vira/pkg/tracing"
+	viraidv1 "github.com/skrolikov/vira/services/vira-id/api/viraid/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server — gRPC API Vira-ID поверх того же service.AuthService, что и REST.
+type Server struct {
+	viraidv1.UnimplementedIdentityServiceServer
+
+	auth     *service.AuthService
+	identity identity.Config
+	logger   *log.Logger
+}
+
+// New создаёт реализацию IdentityService.
+func New(auth *service.AuthService, id identity.Config, logger *log.Logger) *Server {
+	return &Server{auth: auth, identity: id, logger: logger}
+}
+
+// NewGRPCServer создаёт gRPC сервер с зарегистрированным IdentityService.
+func NewGRPCServer(s *Server) *grpc.Server {
+	g := grpc.NewServer(grpc.StatsHandler(tracing.GRPCServerHandler()))
+	viraidv1.RegisterIdentityServiceServer(g, s)
+	return g
+}
+
+// Gateway — HTTP/JSON шлюз к IdentityService (пути /v1/... из identity.proto).
+// Вызывает Server напрямую, без сетевого перехода. Заголовок подписанной
+// личности передаётся в метаданные, как от gRPC клиента. Поля JSON —
+// в snake_case, как в REST API.
+func Gateway(ctx context.Context, s *Server) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithIncomingHeaderMatcher(func(key string) (string, bool) {
+			if key == identity.Header {
+				return identity.MetadataKey, true
+			}
+			return runtime.DefaultHeaderMatcher(key)
+		}),
+	)
+	if err := viraidv1.RegisterIdentityServiceHandlerServer(ctx, mux, s); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+func (s *Server) Register(ctx context.Context, req *viraidv1.RegisterRequest) (*viraidv1.AuthResponse, error) {
+	ip, userAgent, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.auth.Register(ctx, types.RegisterRequest{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+		Email:    req.GetEmail(),
+	}, ip, userAgent)
+	var fields validators.Errors
+	switch {
+	case errors.As(err, &fields):
+		return nil, invalidArgument(fields)
+	case errors.Is(err, db.ErrDuplicateUsername), errors.Is(err, db.ErrDuplicateEmail):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return authResponse(resp), nil
+}
+
+// invalidArgument — InvalidArgument с ошибками полей в деталях BadRequest.
+func invalidArgument(fields validators.Errors) error {
+	br := &errdetails.BadRequest{}
+	for _, f := range fields {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       f.Field,
+			Description: f.Message,
+			Reason:      f.Code,
+		})
+	}
+	st, err := status.New(codes.InvalidArgument, fields.Error()).WithDetails(br)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, fields.Error())
+	}
+	return st.Err()
+}
+
+func (s *Server) Login(ctx context.Context, req *viraidv1.LoginRequest) (*viraidv1.AuthResponse, error) {
+	ip, userAgent, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	username := strings.TrimSpace(req.GetUsername())
+	password := req.GetPassword()
+	if username == "" || password == "" {
+		return nil, status.Error(codes.InvalidArgument, "Неверный логин или пароль")
+	}
+
+	resp, err := s.auth.Login(ctx, types.LoginRequest{Username: username, Password: password}, ip, userAgent)
+	var blocked *service.LoginBlockedError
+	switch {
+	case errors.As(err, &blocked):
+		st, derr := status.New(codes.ResourceExhausted, blocked.Error()).WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(blocked.RetryAfter),
+		})
+		if derr != nil {
+			return nil, status.Error(codes.ResourceExhausted, blocked.Error())
+		}
+		return nil, st.Err()
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		s.logger.WithContext(ctx).Error("Ошибка входа: %v", err)
+		return nil, status.Error(codes.Internal, "ошибка сервера")
+	}
+	return authResponse(resp), nil
+}
+
+func (s *Server) Refresh(ctx context.Context, req *viraidv1.RefreshRequest) (*viraidv1.TokenPair, error) {
+	ip, userAgent, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "нужен refresh_token")
+	}
+
+	tokens, err := s.auth.RefreshToken(ctx, req.GetRefreshToken(), ip, userAgent)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return &viraidv1.TokenPair{AccessToken: tokens.Access, RefreshToken: tokens.Refresh}, nil
+}
+
+func (s *Server) Logout(ctx context.Context, req *viraidv1.LogoutRequest) (*emptypb.Empty, error) {
+	if strings.TrimSpace(req.GetRefreshToken()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "нужен refresh_token")
+	}
+
+	err := s.auth.Logout(ctx, req.GetRefreshToken())
+	switch {
+	case errors.Is(err, service.ErrInvalidRefreshToken):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrSessionNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		return nil, s.internal(ctx, "Logout", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) GetUser(ctx context.Context, _ *viraidv1.GetUserRequest) (*viraidv1.User, error) {
+	userID, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.auth.GetUser(ctx, userID)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "пользователь не найден")
+	}
+	return userMessage(*user), nil
+}
+
+func (s *Server) ListSessions(ctx context.Context, _ *viraidv1.ListSessionsRequest) (*viraidv1.ListSessionsResponse, error) {
+	userID, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.auth.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, s.internal(ctx, "ListSessions", err)
+	}
+
+	resp := &viraidv1.ListSessionsResponse{Sessions: make([]*viraidv1.Session, 0, len(sessions))}
+	for _, sess := range sessions {
+		resp.Sessions = append(resp.Sessions, &viraidv1.Session{
+			Id:        sess.ID,
+			Ip:        sess.IP,
+			Device:    sess.Device,
+			LoginTime: timestamppb.New(sess.LoginTime),
+		})
+	}
+	return resp, nil
+}
+
+func (s *Server) RevokeSession(ctx context.Context, req *viraidv1.RevokeSessionRequest) (*emptypb.Empty, error) {
+	userID, err := s.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.GetSessionId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "нужен session_id")
+	}
+
+	err = s.auth.RevokeSession(ctx, userID, req.GetSessionId())
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		return nil, s.internal(ctx, "RevokeSession", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) IntrospectToken(ctx context.Context, req *viraidv1.IntrospectTokenRequest) (*viraidv1.IntrospectTokenResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "нужен token")
+	}
+
+	info, err := s.auth.IntrospectToken(ctx, req.GetToken())
+	if err != nil {
+		return nil, s.internal(ctx, "IntrospectToken", err)
+	}
+	if !info.Active {
+		return &viraidv1.IntrospectTokenResponse{}, nil
+	}
+	return &viraidv1.IntrospectTokenResponse{
+		Active:    true,
+		UserId:    info.UserID,
+		Role:      info.Role,
+		SessionId: info.SessionID,
+		ExpiresAt: timestamppb.New(info.ExpiresAt),
+	}, nil
+}
+
+// caller определяет пользователя вызова по тем же правилам, что identity.Auth
+// для REST: подписанная gateway личность, а без неё — access токен из
+// authorization, если подпись не обязательна.
+func (s *Server) caller(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if s.identity.Secret != "" {
+		value := first(md, identity.MetadataKey)
+		if value != "" || s.identity.Required {
+			c, err := identity.Verify(value, s.identity.Secret, time.Now())
+			if err != nil {
+				return "", status.Error(codes.Unauthenticated, err.Error())
+			}
+			if c.UserID == "" {
+				return "", status.Error(codes.Unauthenticated, "запрос без пользователя")
+			}
+			return c.UserID, nil
+		}
+	}
+
+	token, ok := strings.CutPrefix(first(md, "authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", status.Error(codes.Unauthenticated, "нет access токена")
+	}
+	info, err := s.auth.IntrospectToken(ctx, token)
+	if err != nil {
+		return "", s.internal(ctx, "caller", err)
+	}
+	if !info.Active {
+		return "", status.Error(codes.Unauthenticated, "недействительный access токен")
+	}
+	return info.UserID, nil
+}
+
+// internal логирует ошибку и возвращает клиенту Internal без подробностей.
+func (s *Server) internal(ctx context.Context, method string, err error) error {
+	s.logger.WithContext(ctx).Error("gRPC %s: %v", method, err)
+	return status.Error(codes.Internal, "ошибка сервера")
+}
+
+// client возвращает IP и User-Agent клиента. IP берётся из подписанной
+// gateway личности: её передают HTTP шлюз и сервисы, вызывающие vira-id
+// от имени клиента (identity.Outgoing). User-Agent из identity.MetadataUserAgent
+// учитывается только вместе с ней. Без подписи IP — адрес соединения gRPC
+// или, для HTTP шлюза, адрес, который шлюз дописал в x-forwarded-for
+// последним: левые значения может подставить клиент. Неверная подпись,
+// а при IDENTITY_REQUIRED и её отсутствие — Unauthenticated, как в caller.
+func (s *Server) client(ctx context.Context) (ip, userAgent string, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if s.identity.Secret != "" {
+		value := first(md, identity.MetadataKey)
+		if value != "" || s.identity.Required {
+			c, err := identity.Verify(value, s.identity.Secret, time.Now())
+			if err != nil {
+				return "", "", status.Error(codes.Unauthenticated, err.Error())
+			}
+			if c.ClientIP != "" {
+				ip = c.ClientIP
+				userAgent = first(md, identity.MetadataUserAgent)
+			}
+		}
+	}
+
+	if ip == "" {
+		if p, ok := peer.FromContext(ctx); ok {
+			ip, _, _ = net.SplitHostPort(p.Addr.String())
+		} else if xff := md.Get("x-forwarded-for"); len(xff) > 0 {
+			hops := strings.Split(xff[len(xff)-1], ",")
+			ip = strings.TrimSpace(hops[len(hops)-1])
+		}
+	}
+
+	if userAgent == "" {
+		userAgent = first(md, runtime.MetadataPrefix+"user-agent")
+	}
+	if userAgent == "" {
+		userAgent = first(md, "user-agent")
+	}
+	return ip, userAgent, nil
+}
+
+func first(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func authResponse(resp *types.AuthResponse) *viraidv1.AuthResponse {
+	return &viraidv1.AuthResponse{
+		Tokens: &viraidv1.TokenPair{AccessToken: resp.Tokens.Access, RefreshToken: resp.Tokens.Refresh},
+		User:   userMessage(resp.User),
+	}
+}
+
+func userMessage(u types.UserInfo) *viraidv1.User {
+	return &viraidv1.User{Id: u.ID, Username: u.Username, Role: u.Role}
+}
@@ -0,0 +1,75 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/identity"
+)
+
+const secret = "test-secret"
+
+// sign подписывает анонимную личность так же, как gateway.
+func sign(t *testing.T, key string, expires time.Time) string {
+	t.Helper()
+	raw, err := json.Marshal(identity.Claims{IssuedAt: time.Now().Unix(), Expires: expires.Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := "v1." + base64.RawURLEncoding.EncodeToString(raw)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestGatewayIdentity(t *testing.T) {
+	valid := sign(t, secret, time.Now().Add(time.Minute))
+
+	tests := []struct {
+		name     string
+		cfg      identity.Config
+		path     string
+		identity string
+		want     int
+	}{
+		{"login без подписи", identity.Config{Secret: secret, Required: true}, "/v1/login", "", http.StatusUnauthorized},
+		{"register без подписи", identity.Config{Secret: secret, Required: true}, "/v1/register", "", http.StatusUnauthorized},
+		{"refresh без подписи", identity.Config{Secret: secret, Required: true}, "/v1/refresh", "", http.StatusUnauthorized},
+		{"login с чужой подписью", identity.Config{Secret: secret, Required: true}, "/v1/login", sign(t, "other-secret", time.Now().Add(time.Minute)), http.StatusUnauthorized},
+		{"login с истёкшей подписью", identity.Config{Secret: secret, Required: true}, "/v1/login", sign(t, secret, time.Now().Add(-time.Minute)), http.StatusUnauthorized},
+		{"неверная подпись без IDENTITY_REQUIRED", identity.Config{Secret: secret}, "/v1/login", "v1.e30.bad", http.StatusUnauthorized},
+		// Пустое тело отклоняется уже после проверки личности
+		{"login с подписью", identity.Config{Secret: secret, Required: true}, "/v1/login", valid, http.StatusBadRequest},
+		{"login без подписи, когда она не обязательна", identity.Config{Secret: secret}, "/v1/login", "", http.StatusBadRequest},
+		{"login без секрета", identity.Config{}, "/v1/login", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := Gateway(context.Background(), New(nil, tt.cfg, log.New(log.Config{})))
+			if err != nil {
+				t.Fatalf("Gateway() error = %v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{}`))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.identity != "" {
+				r.Header.Set(identity.Header, tt.identity)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Fatalf("POST %s = %d %s, want %d", tt.path, rec.Code, rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
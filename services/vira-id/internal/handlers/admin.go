@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"vira-id/internal/service"
+	"vira-id/internal/types"
+
+	middleware "github.com/skrolikov/vira-middleware"
+)
+
+// RoleAdmin — роль пользователей, которым доступны /admin/... эндпоинты.
+const RoleAdmin = "admin"
+
+// AdminOnly пропускает только пользователей с ролью admin. Стоит после
+// identity.Auth; роль берётся из БД, а не из токена, чтобы снятие роли
+// действовало сразу.
+func AdminOnly(authService *service.AuthService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := middleware.GetUserID(r)
+			if userID == "" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			user, err := authService.GetUser(r.Context(), userID)
+			if err != nil || user.Role != RoleAdmin {
+				http.Error(w, "Доступ запрещён", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UnlockLoginHandler снимает блокировку входа по имени пользователя и (или) IP.
+//
+// @Summary      Разблокировать вход
+// @Description  Снимает блокировку и обнуляет счётчики неудачных попыток входа для имени пользователя и (или) IP. Только для администраторов.
+// @Tags         admin
+// @Accept       json
+// @Produce      plain
+// @Param        request  body      types.UnlockLoginRequest  true  "Имя пользователя и (или) IP"
+// @Success      204
+// @Failure      400      {string}  string  "Неверный формат запроса"
+// @Failure      401      {string}  string  "Пользователь не авторизован"
+// @Failure      403      {string}  string  "Нет роли admin"
+// @Security     ApiKeyAuth
+// @Router       /admin/login/unlock [post]
+func UnlockLoginHandler(authService *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.UnlockLoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		req.Username = strings.TrimSpace(req.Username)
+		req.IP = strings.TrimSpace(req.IP)
+		if req.Username == "" && req.IP == "" {
+			http.Error(w, "Нужно имя пользователя или IP", http.StatusBadRequest)
+			return
+		}
+
+		if err := authService.UnlockLogin(r.Context(), req.Username, req.IP); err != nil {
+			authService.Logger.WithContext(r.Context()).Error("Ошибка разблокировки входа: %v", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -1,29 +1,83 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"vira-id/internal/service"
+	"vira-id/internal/types"
 )
 
+// ConfirmUserHandler подтверждает email пользователя по токену из письма.
+//
+// @Summary      Подтверждение пользователя
+// @Description  Подтверждает email по токену из ссылки в письме. Ссылка ведёт на фронтенд (CONFIRM_URL), который передаёт токен сюда. Токен одноразовый и действует ограниченное время.
+// @Tags         auth
+// @Produce      plain
+// @Param        token  query     string  true  "Токен подтверждения из ссылки в письме"
+// @Success      200    {string}  string  "Пользователь успешно подтверждён"
+// @Failure      400    {string}  string  "Не указан токен, либо токен недействителен или устарел"
+// @Router       /confirm [get]
 func ConfirmUserHandler(authService *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
-		email := r.URL.Query().Get("email")
 		token := r.URL.Query().Get("token")
-
-		if email == "" || token == "" {
-			http.Error(w, "необходимо указать email и токен", http.StatusBadRequest)
+		if token == "" {
+			http.Error(w, "необходимо указать токен", http.StatusBadRequest)
 			return
 		}
 
-		err := authService.ConfirmUser(ctx, email, token)
-		if err != nil {
-			http.Error(w, "не удалось подтвердить пользователя: "+err.Error(), http.StatusBadRequest)
+		err := authService.ConfirmUser(r.Context(), token)
+		switch {
+		case errors.Is(err, service.ErrInvalidConfirmToken):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err != nil:
+			authService.Logger.WithContext(r.Context()).Error("Ошибка подтверждения пользователя: %v", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("Пользователь успешно подтверждён"))
+		}
+	}
+}
+
+// ResendConfirmHandler повторно отправляет письмо подтверждения.
+//
+// @Summary      Повторное письмо подтверждения
+// @Description  Отправляет новую ссылку подтверждения, старая перестаёт действовать. Ответ одинаков для любых адресов, чтобы по нему нельзя было узнать, зарегистрирован ли email. Частота писем на адрес ограничена.
+// @Tags         auth
+// @Accept       json
+// @Produce      plain
+// @Param        request  body      types.ResendConfirmRequest  true  "Email"
+// @Success      202      {string}  string  "Письмо отправлено, если адрес ожидает подтверждения"
+// @Failure      400      {string}  string  "Неверный формат запроса"
+// @Failure      429      {string}  string  "Письмо отправлялось недавно, см. Retry-After"
+// @Router       /confirm/resend [post]
+func ResendConfirmHandler(authService *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.ResendConfirmRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
 			return
 		}
+		if req.Language == "" {
+			req.Language = r.Header.Get("Accept-Language")
+		}
 
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Пользователь успешно подтверждён"))
+		err := authService.ResendConfirmation(r.Context(), req.Email, req.Language)
+		var throttled *service.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds()+0.5)))
+			http.Error(w, throttled.Error(), http.StatusTooManyRequests)
+		case err != nil:
+			authService.Logger.WithContext(r.Context()).Error("Ошибка повторной отправки письма: %v", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("Если адрес ожидает подтверждения, письмо отправлено"))
+		}
 	}
 }
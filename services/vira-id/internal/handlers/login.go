@@ -2,14 +2,30 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"vira-id/internal/service"
 	"vira-id/internal/types"
+
+	"github.com/skrolikov/vira/pkg/identity"
 )
 
 // LoginHandler обрабатывает авторизацию пользователя
+//
+// @Summary      Вход
+// @Description  Авторизация по логину и паролю. После неудачной попытки следующая по тому же имени возможна через растущую задержку, серия неудач временно блокирует вход по имени или с IP (LOGIN_*)
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.LoginRequest  true  "Данные входа"
+// @Success      200      {object}  types.AuthResponse
+// @Failure      400      {string}  string  "Неверный формат запроса"
+// @Failure      401      {string}  string  "Неверный логин или пароль, либо email не подтверждён"
+// @Failure      429      {string}  string  "Слишком много неудачных попыток, см. Retry-After"
+// @Router       /login [post]
 func LoginHandler(authService *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req types.LoginRequest
@@ -19,10 +35,10 @@ func LoginHandler(authService *service.AuthService) http.HandlerFunc {
 			return
 		}
 
+		// Пароль передаётся как есть: пробелы — его часть
 		req.Username = strings.TrimSpace(req.Username)
-		req.Password = strings.TrimSpace(req.Password)
 
-		if len(req.Username) < 3 || len(req.Password) < 6 {
+		if req.Username == "" || req.Password == "" {
 			http.Error(w, "Неверный логин или пароль", http.StatusBadRequest)
 			return
 		}
@@ -31,9 +47,19 @@ func LoginHandler(authService *service.AuthService) http.HandlerFunc {
 		ip := getIP(r)
 
 		resp, err := authService.Login(r.Context(), req, ip, userAgent)
-		if err != nil {
+		var blocked *service.LoginBlockedError
+		switch {
+		case errors.As(err, &blocked):
+			w.Header().Set("Retry-After", strconv.Itoa(int(blocked.RetryAfter.Seconds()+0.5)))
+			http.Error(w, blocked.Error(), http.StatusTooManyRequests)
+			return
+		case errors.Is(err, service.ErrInvalidCredentials):
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
+		case err != nil:
+			authService.Logger.WithContext(r.Context()).Error("Ошибка входа: %v", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -41,9 +67,8 @@ func LoginHandler(authService *service.AuthService) http.HandlerFunc {
 	}
 }
 
+// getIP — IP клиента из подписанной gateway личности (identity.Optional
+// или identity.Auth на маршруте), иначе адрес соединения.
 func getIP(r *http.Request) string {
-	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
-		return strings.Split(ip, ",")[0]
-	}
-	return strings.Split(r.RemoteAddr, ":")[0]
+	return identity.ClientIP(r)
 }
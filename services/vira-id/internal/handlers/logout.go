@@ -2,18 +2,28 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
+	"vira-id/internal/service"
 	"vira-id/internal/types"
 
 	"log"
-
-	"github.com/redis/go-redis/v9"
-	config "github.com/skrolikov/vira-config"
-	jwt "github.com/skrolikov/vira-jwt"
 )
 
-func LogoutHandler(cfg *config.Config, rdb *redis.Client) http.HandlerFunc {
+// LogoutHandler завершает сессию по refresh токену.
+//
+// @Summary      Выход
+// @Description  Удаляет refresh токен и завершает сессию
+// @Tags         auth
+// @Accept       json
+// @Param        request  body      types.LogoutRequest  true  "Refresh токен"
+// @Success      204
+// @Failure      400      {string}  string  "Неверный формат запроса"
+// @Failure      401      {string}  string  "Неверный токен"
+// @Security     ApiKeyAuth
+// @Router       /logout [post]
+func LogoutHandler(svc *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req types.LogoutRequest
 
@@ -22,43 +32,17 @@ func LogoutHandler(cfg *config.Config, rdb *redis.Client) http.HandlerFunc {
 			return
 		}
 
-		claims, err := jwt.ParseToken(req.RefreshToken, cfg.JwtSecret)
-		if err != nil || !jwt.IsTokenType(claims, "refresh") {
+		err := svc.Logout(r.Context(), req.RefreshToken)
+		switch {
+		case errors.Is(err, service.ErrInvalidRefreshToken):
 			http.Error(w, "Неверный токен", http.StatusUnauthorized)
-			return
-		}
-
-		userID, ok := claims["user_id"].(string)
-		if !ok || userID == "" {
-			http.Error(w, "Невалидный user_id", http.StatusUnauthorized)
-			return
-		}
-
-		// Проверяем, совпадает ли токен с сохранённым refresh в Redis
-		key := "refresh:" + userID
-		savedToken, err := rdb.Get(r.Context(), key).Result()
-		if err == redis.Nil {
+		case errors.Is(err, service.ErrSessionNotFound):
 			http.Error(w, "Токен не найден", http.StatusUnauthorized)
-			return
-		} else if err != nil {
-			log.Printf("Ошибка Redis при получении токена: %v", err)
+		case err != nil:
+			log.Printf("Ошибка завершения сессии: %v", err)
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
-			return
-		}
-
-		if savedToken == req.RefreshToken {
-			// Удаляем основной refresh токен (завершение сессии)
-			if err := rdb.Del(r.Context(), key).Err(); err != nil {
-				log.Printf("Ошибка удаления refresh токена: %v", err)
-			}
+		default:
+			w.WriteHeader(http.StatusNoContent) // 204 No Content
 		}
-
-		// Удаляем сессию по ключу session:<userID>:<refresh>
-		sessionKey := "session:" + userID + ":" + req.RefreshToken
-		if err := rdb.Del(r.Context(), sessionKey).Err(); err != nil {
-			log.Printf("Ошибка удаления сессии: %v", err)
-		}
-
-		w.WriteHeader(http.StatusNoContent) // 204 No Content
 	}
 }
@@ -10,6 +10,17 @@ import (
 	db "github.com/skrolikov/vira-db"
 )
 
+// MeHandler возвращает данные текущего пользователя.
+//
+// @Summary      Получить информацию о текущем пользователе
+// @Description  Возвращает ID и имя пользователя по access токену.
+// @Tags         Пользователь
+// @Produce      json
+// @Success      200  {object}  types.UserInfo  "Информация о пользователе"
+// @Failure      401  {string}  string          "Пользователь не аутентифицирован"
+// @Failure      404  {string}  string          "Пользователь не найден в базе данных"
+// @Security     ApiKeyAuth
+// @Router       /me [get]
 func MeHandler(repo db.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := middleware.GetUserID(r)
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"vira-id/internal/service"
+	"vira-id/internal/types"
+
+	middleware "github.com/skrolikov/vira-middleware"
+	"github.com/skrolikov/vira/pkg/identity"
+)
+
+// ForgotPasswordHandler отправляет письмо со ссылкой сброса пароля.
+//
+// @Summary      Забыли пароль
+// @Description  Отправляет на email пользователя ссылку сброса пароля (PASSWORD_RESET_URL). Ответ одинаков для любых имён и адресов, чтобы по нему нельзя было узнать, есть ли учётная запись. Частота писем ограничена.
+// @Tags         auth
+// @Accept       json
+// @Produce      plain
+// @Param        request  body      types.ForgotPasswordRequest  true  "Имя пользователя или email"
+// @Success      202      {string}  string  "Письмо отправлено, если учётная запись существует"
+// @Failure      400      {string}  string  "Неверный формат запроса"
+// @Failure      429      {string}  string  "Письмо отправлялось недавно, см. Retry-After"
+// @Router       /password/forgot [post]
+func ForgotPasswordHandler(authService *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.ForgotPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Login) == "" {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		if req.Language == "" {
+			req.Language = r.Header.Get("Accept-Language")
+		}
+
+		err := authService.ForgotPassword(r.Context(), req.Login, req.Language)
+		var throttled *service.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds()+0.5)))
+			http.Error(w, throttled.Error(), http.StatusTooManyRequests)
+		case err != nil:
+			authService.Logger.WithContext(r.Context()).Error("Ошибка запроса сброса пароля: %v", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("Если учётная запись существует, письмо отправлено"))
+		}
+	}
+}
+
+// ResetPasswordHandler задаёт новый пароль по токену из письма.
+//
+// @Summary      Сброс пароля
+// @Description  Задаёт новый пароль по одноразовому токену из письма и закрывает все сессии пользователя.
+// @Tags         auth
+// @Accept       json
+// @Produce      plain
+// @Param        request  body      types.ResetPasswordRequest  true  "Токен и новый пароль"
+// @Success      204
+// @Failure      400      {object}  types.ValidationErrorResponse  "Пароль не соответствует требованиям (PASSWORD_*); неверный формат запроса или недействительный токен — текстом"
+// @Router       /password/reset [post]
+func ResetPasswordHandler(authService *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.ResetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		err := authService.ResetPassword(r.Context(), req.Token, req.Password, getIP(r), r.UserAgent())
+		switch {
+		case validationError(w, err):
+		case errors.Is(err, service.ErrInvalidResetToken):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err != nil:
+			authService.Logger.WithContext(r.Context()).Error("Ошибка сброса пароля: %v", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// ChangePasswordHandler меняет пароль текущего пользователя.
+//
+// @Summary      Смена пароля
+// @Description  Меняет пароль по текущему. Новый пароль проверяется по тем же правилам, что при регистрации, и не должен совпадать с текущим. Все сессии пользователя закрываются; с keep_current_session текущая остаётся открытой.
+// @Tags         auth
+// @Accept       json
+// @Produce      plain
+// @Param        request  body      types.ChangePasswordRequest  true  "Текущий и новый пароли"
+// @Success      204
+// @Failure      400      {object}  types.ValidationErrorResponse  "Новый пароль не соответствует требованиям (PASSWORD_*); неверный формат запроса — текстом"
+// @Failure      401      {string}  string  "Пользователь не авторизован"
+// @Failure      403      {string}  string  "Текущий пароль неверный"
+// @Failure      429      {string}  string  "Слишком много неудачных попыток, см. Retry-After"
+// @Security     ApiKeyAuth
+// @Router       /password/change [post]
+func ChangePasswordHandler(authService *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := middleware.GetUserID(r)
+		if userID == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req types.ChangePasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OldPassword == "" {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+
+		keep := ""
+		if req.KeepCurrentSession {
+			keep = currentSession(r, authService)
+		}
+
+		err := authService.ChangePassword(r.Context(), userID, req.OldPassword, req.NewPassword, keep, getIP(r), r.UserAgent())
+		var blocked *service.LoginBlockedError
+		switch {
+		case errors.As(err, &blocked):
+			w.Header().Set("Retry-After", strconv.Itoa(int(blocked.RetryAfter.Seconds()+0.5)))
+			http.Error(w, blocked.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, service.ErrWrongPassword):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case validationError(w, err):
+		case err != nil:
+			authService.Logger.WithContext(r.Context()).Error("Ошибка смены пароля: %v", err)
+			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// currentSession возвращает ID сессии запроса: из подписанной gateway
+// личности или, если запрос пришёл с JWT, из access токена.
+func currentSession(r *http.Request, authService *service.AuthService) string {
+	if c, ok := identity.FromContext(r.Context()); ok {
+		return c.SessionID
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	info, err := authService.IntrospectToken(r.Context(), token)
+	if err != nil || !info.Active {
+		return ""
+	}
+	return info.SessionID
+}
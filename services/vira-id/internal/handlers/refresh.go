@@ -8,6 +8,18 @@ import (
 	"vira-id/internal/types"
 )
 
+// RefreshHandler выдаёт новую пару токенов по refresh токену.
+//
+// @Summary      Обновление токена
+// @Description  Обновляет пару токенов по refresh-токену
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.RefreshRequest  true  "Refresh токен"
+// @Success      200      {object}  types.TokenPair
+// @Failure      400      {string}  string  "Неверный формат запроса"
+// @Failure      401      {string}  string  "Неверный или отозванный токен"
+// @Router       /refresh [post]
 func RefreshHandler(svc *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req types.RefreshRequest
@@ -16,7 +28,7 @@ func RefreshHandler(svc *service.AuthService) http.HandlerFunc {
 			return
 		}
 
-		tokens, err := svc.RefreshToken(r.Context(), req.RefreshToken, r.RemoteAddr, r.UserAgent())
+		tokens, err := svc.RefreshToken(r.Context(), req.RefreshToken, getIP(r), r.UserAgent())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
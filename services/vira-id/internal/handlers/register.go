@@ -10,6 +10,16 @@ import (
 
 // RegisterHandler обрабатывает регистрацию нового пользователя
 // Добавлены kafka.Producer и logger для отправки Kafka-события
+//
+// @Summary      Регистрация
+// @Description  Создание нового пользователя. На email уходит ссылка подтверждения: до подтверждения вход невозможен. Пароль проверяется по политике (длина, классы символов, имя пользователя, список утёкших паролей); ошибки возвращаются по полям
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.RegisterRequest  true  "Данные регистрации"
+// @Success      201      {object}  types.AuthResponse
+// @Failure      400      {object}  types.ValidationErrorResponse  "Ошибки полей; неверный JSON или существующий пользователь — текстом"
+// @Router       /register [post]
 func RegisterHandler(authService *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req types.RegisterRequest
@@ -18,13 +28,19 @@ func RegisterHandler(authService *service.AuthService) http.HandlerFunc {
 			return
 		}
 
+		if req.Language == "" {
+			req.Language = r.Header.Get("Accept-Language")
+		}
+
 		userAgent := r.UserAgent()
 		ip := getIP(r)
 
 		resp, err := authService.Register(r.Context(), req, ip, userAgent)
 		if err != nil {
-			// Обработка ошибок и ответ с нужным статусом
-			http.Error(w, err.Error(), http.StatusBadRequest) // пример
+			if !validationError(w, err) {
+				// Пользователь уже существует и прочие ошибки
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
 			return
 		}
 
@@ -1,19 +1,32 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
+	"vira-id/internal/service"
+
 	middleware "github.com/skrolikov/vira-middleware"
 
-	"github.com/gorilla/mux"
+	"github.com/go-chi/chi/v5"
 
 	"log"
-
-	"github.com/redis/go-redis/v9"
-	config "github.com/skrolikov/vira-config"
 )
 
-func DeleteSessionHandler(cfg *config.Config, rdb *redis.Client) http.HandlerFunc {
+// DeleteSessionHandler удаляет сессию текущего пользователя.
+//
+// @Summary      Удалить сессию пользователя
+// @Description  Удаляет сессию пользователя из Redis по ID сессии. Также удаляет refresh-токен, если он совпадает с удаляемой сессией.
+// @Tags         Сессии
+// @Produce      plain
+// @Param        id   path      string  true  "ID сессии для удаления"
+// @Success      204  "Сессия успешно удалена"
+// @Failure      400  {string}  string  "Неверный ID сессии"
+// @Failure      401  {string}  string  "Пользователь не авторизован"
+// @Failure      500  {string}  string  "Ошибка сервера"
+// @Security     ApiKeyAuth
+// @Router       /sessions/{id} [delete]
+func DeleteSessionHandler(svc *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := middleware.GetUserID(r)
 		if userID == "" {
@@ -21,30 +34,20 @@ func DeleteSessionHandler(cfg *config.Config, rdb *redis.Client) http.HandlerFun
 			return
 		}
 
-		vars := mux.Vars(r)
-		sessionID := vars["id"]
+		sessionID := chi.URLParam(r, "id")
 		if sessionID == "" {
 			http.Error(w, "Неверный ID сессии", http.StatusBadRequest)
 			return
 		}
 
-		// Удаляем сессию из Redis
-		sessionKey := "session:" + userID + ":" + sessionID
-		if err := rdb.Del(r.Context(), sessionKey).Err(); err != nil {
+		// Удаляем сессию и её refresh токен; уже закрытая сессия — не ошибка
+		err := svc.RevokeSession(r.Context(), userID, sessionID)
+		if err != nil && !errors.Is(err, service.ErrSessionNotFound) {
 			log.Printf("Ошибка удаления сессии: %v", err)
 			http.Error(w, "Ошибка сервера", http.StatusInternalServerError)
 			return
 		}
 
-		// Если этот refresh токен совпадает с основным в ключе refresh:<userID>, удаляем и его (если надо)
-		key := "refresh:" + userID
-		savedToken, err := rdb.Get(r.Context(), key).Result()
-		if err == nil && savedToken == sessionID {
-			if err := rdb.Del(r.Context(), key).Err(); err != nil {
-				log.Printf("Ошибка удаления refresh токена: %v", err)
-			}
-		}
-
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -14,6 +14,19 @@ import (
 	config "github.com/skrolikov/vira-config"
 )
 
+// SessionsHandler возвращает активные сессии текущего пользователя.
+//
+// @Summary      Получить список сессий пользователя
+// @Description  Возвращает список активных сессий текущего пользователя. Поддерживается пагинация с помощью параметра cursor.
+// @Tags         Сессии
+// @Produce      json
+// @Param        cursor  query     string  false  "Курсор для пагинации. Если не указан, возвращает первые 20 сессий."
+// @Success      200     {object}  types.SessionsResponse  "Список сессий и следующий курсор для пагинации"
+// @Failure      400     {string}  string  "Неверный формат курсора"
+// @Failure      401     {string}  string  "Пользователь не авторизован"
+// @Failure      500     {string}  string  "Ошибка сервера"
+// @Security     ApiKeyAuth
+// @Router       /sessions [get]
 func SessionsHandler(cfg *config.Config, rdb *redis.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 1. Авторизация: вытаскиваем userID из контекста, должен быть установлен middleware.Auth
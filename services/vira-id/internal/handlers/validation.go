@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"vira-id/internal/types"
+	"vira-id/internal/validators"
+)
+
+// validationError отвечает 400 с ошибками по полям, если err — ошибка
+// проверки данных. Возвращает false для прочих ошибок.
+func validationError(w http.ResponseWriter, err error) bool {
+	var fields validators.Errors
+	if !errors.As(err, &fields) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(types.ValidationErrorResponse{
+		Error:  fields.Error(),
+		Fields: fields,
+	})
+	return true
+}
@@ -0,0 +1,52 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/skrolikov/vira-logger"
+)
+
+// Maildir складывает письма файлами в каталог формата Maildir (tmp/, new/, cur/)
+// вместо отправки. Для локальной разработки: письмо открывается любым
+// почтовым клиентом или просто читается как текст.
+type Maildir struct {
+	dir    string
+	from   string
+	logger *log.Logger
+}
+
+// NewMaildir создаёт каталог писем, если его нет.
+func NewMaildir(dir, from string, logger *log.Logger) (*Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("каталог писем %s: %w", dir, err)
+		}
+	}
+	return &Maildir{dir: dir, from: from, logger: logger}, nil
+}
+
+// Send записывает письмо в tmp/ и переносит в new/: читатель каталога
+// не увидит недописанный файл.
+func (m *Maildir) Send(ctx context.Context, msg Message) error {
+	raw, err := compose(m.from, msg)
+	if err != nil {
+		return fmt.Errorf("сборка письма: %w", err)
+	}
+
+	name := fmt.Sprintf("%d.%s.vira", time.Now().UnixNano(), randomHex(4))
+	tmp := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return fmt.Errorf("запись письма: %w", err)
+	}
+	path := filepath.Join(m.dir, "new", name)
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("запись письма: %w", err)
+	}
+
+	m.logger.WithContext(ctx).Info("📧 Письмо «%s» для %s сохранено в %s", msg.Subject, msg.To, path)
+	return nil
+}
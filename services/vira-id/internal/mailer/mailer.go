@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/skrolikov/vira-logger"
+)
+
+// Message — письмо одному получателю: текстовая и HTML версии.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer отправляет письма. Реализации: SMTP для окружений с почтовым
+// сервером и Maildir для локальной разработки.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config — настройки почты.
+type Config struct {
+	Transport string     // smtp или maildir
+	From      string     // Адрес отправителя
+	SMTP      SMTPConfig // Настройки SMTP (Transport=smtp)
+	Maildir   string     // Каталог писем (Transport=maildir)
+}
+
+// FromEnv читает настройки почты из окружения:
+// MAIL_TRANSPORT (smtp или maildir, по умолчанию maildir), MAIL_FROM,
+// SMTP_HOST, SMTP_PORT (по умолчанию 587), SMTP_USERNAME, SMTP_PASSWORD, MAILDIR.
+func FromEnv() (Config, error) {
+	cfg := Config{
+		Transport: os.Getenv("MAIL_TRANSPORT"),
+		From:      os.Getenv("MAIL_FROM"),
+		SMTP: SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     587,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		},
+		Maildir: os.Getenv("MAILDIR"),
+	}
+	if cfg.Transport == "" {
+		cfg.Transport = "maildir"
+	}
+	if cfg.From == "" {
+		cfg.From = "Vira <no-reply@vira.local>"
+	}
+	if cfg.Maildir == "" {
+		cfg.Maildir = "maildir"
+	}
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port <= 0 {
+			return Config{}, fmt.Errorf("SMTP_PORT: некорректный порт %q", v)
+		}
+		cfg.SMTP.Port = port
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return Config{}, fmt.Errorf("MAIL_FROM: %w", err)
+	}
+
+	switch cfg.Transport {
+	case "smtp":
+		if cfg.SMTP.Host == "" {
+			return Config{}, fmt.Errorf("SMTP_HOST обязателен для MAIL_TRANSPORT=smtp")
+		}
+	case "maildir":
+	default:
+		return Config{}, fmt.Errorf("MAIL_TRANSPORT: неизвестный транспорт %q (smtp или maildir)", cfg.Transport)
+	}
+	return cfg, nil
+}
+
+// New создаёт Mailer по настройкам.
+func New(cfg Config, logger *log.Logger) (Mailer, error) {
+	if cfg.Transport == "smtp" {
+		return NewSMTP(cfg.SMTP, cfg.From), nil
+	}
+	return NewMaildir(cfg.Maildir, cfg.From, logger)
+}
+
+// compose собирает письмо в формате RFC 5322: multipart/alternative
+// с текстовой и HTML частями в quoted-printable.
+func compose(from string, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	header := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMessage-ID: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%q\r\n\r\n",
+		from, msg.To, mime.QEncoding.Encode("utf-8", msg.Subject), time.Now().Format(time.RFC1123Z), messageID(from), body.Boundary())
+	buf.WriteString(header)
+
+	parts := []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	}
+	for _, p := range parts {
+		if p.content == "" {
+			continue
+		}
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// messageID — уникальный Message-ID в домене отправителя.
+func messageID(from string) string {
+	domain := "vira.local"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndexByte(addr.Address, '@'); i >= 0 {
+			domain = addr.Address[i+1:]
+		}
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), randomHex(8), domain)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
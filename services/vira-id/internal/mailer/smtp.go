@@ -0,0 +1,101 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPConfig — настройки SMTP сервера.
+type SMTPConfig struct {
+	Host     string
+	Port     int    // 465 — TLS сразу, иначе STARTTLS, если сервер его поддерживает
+	Username string // Пусто — без авторизации
+	Password string
+}
+
+// SMTP отправляет письма через SMTP сервер.
+type SMTP struct {
+	cfg  SMTPConfig
+	from string
+}
+
+// NewSMTP создаёт SMTP отправитель.
+func NewSMTP(cfg SMTPConfig, from string) *SMTP {
+	return &SMTP{cfg: cfg, from: from}
+}
+
+// Send отправляет письмо. Соединение ограничено дедлайном ctx
+// (без дедлайна — 30 секундами).
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	raw, err := compose(m.from, msg)
+	if err != nil {
+		return fmt.Errorf("сборка письма: %w", err)
+	}
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return fmt.Errorf("адрес отправителя: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("адрес получателя: %w", err)
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("подключение к SMTP %s: %w", addr, err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(30 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+
+	tlsCfg := &tls.Config{ServerName: m.cfg.Host}
+	if m.cfg.Port == 465 {
+		conn = tls.Client(conn, tlsCfg)
+	}
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok && m.cfg.Port != 465 {
+		if err := c.StartTLS(tlsCfg); err != nil {
+			return fmt.Errorf("SMTP STARTTLS: %w", err)
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP авторизация: %w", err)
+		}
+	}
+
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("SMTP RCPT TO: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	return c.Quit()
+}
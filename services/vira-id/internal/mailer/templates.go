@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// DefaultLanguage — язык писем, если клиент не указал поддерживаемый.
+const DefaultLanguage = "ru"
+
+// languages — языки, на которые переведены все шаблоны.
+var languages = []string{"ru", "en"}
+
+// Шаблоны писем: <имя>.<язык>.txt с блоками subject и text и <имя>.<язык>.html.
+//
+//go:embed templates
+var templatesFS embed.FS
+
+// Каждый файл разбирается отдельно: блоки subject разных писем не пересекаются.
+var (
+	textTemplates = map[string]*texttemplate.Template{}
+	htmlTemplates = map[string]*htmltemplate.Template{}
+)
+
+func init() {
+	files, _ := fs.Glob(templatesFS, "templates/*")
+	for _, file := range files {
+		name := path.Base(file)
+		switch path.Ext(name) {
+		case ".txt":
+			textTemplates[name] = texttemplate.Must(texttemplate.New(name).ParseFS(templatesFS, file))
+		case ".html":
+			htmlTemplates[name] = htmltemplate.Must(htmltemplate.New(name).ParseFS(templatesFS, file))
+		}
+	}
+}
+
+// Language выбирает язык письма по значению вида "en", "en-US"
+// или заголовку Accept-Language. Неизвестный язык — DefaultLanguage.
+func Language(value string) string {
+	for _, part := range strings.Split(value, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		for _, lang := range languages {
+			if base == lang {
+				return lang
+			}
+		}
+	}
+	return DefaultLanguage
+}
+
+// Render собирает письмо по шаблону name на языке lang.
+func Render(name, lang, to string, data any) (Message, error) {
+	file := name + "." + Language(lang)
+
+	text := textTemplates[file+".txt"]
+	html := htmlTemplates[file+".html"]
+	if text == nil || html == nil {
+		return Message{}, fmt.Errorf("нет шаблона письма %s", file)
+	}
+
+	var subject, body, page bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("шаблон %s: %w", file, err)
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("шаблон %s: %w", file, err)
+	}
+	if err := html.Execute(&page, data); err != nil {
+		return Message{}, fmt.Errorf("шаблон %s: %w", file, err)
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    body.String(),
+		HTML:    page.String(),
+	}, nil
+}
@@ -32,8 +32,51 @@ var KafkaErrors = prometheus.NewCounterVec(
 	[]string{"event_type"},
 )
 
+// EmailsSent — счётчик писем пользователям по шаблону и результату отправки.
+//
+// Метрика: emails_sent_total
+// Labels:
+// - template: шаблон письма (например, confirm)
+// - result: sent или error
+var EmailsSent = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "emails_sent_total",
+		Help: "Количество писем пользователям по результату отправки",
+	},
+	[]string{"template", "result"},
+)
+
+// LoginAttempts — счётчик попыток входа по результату.
+//
+// Метрика: login_attempts_total
+// Labels:
+// - result: success, invalid (неверное имя или пароль), blocked (задержка или блокировка), unconfirmed
+var LoginAttempts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "login_attempts_total",
+		Help: "Количество попыток входа по результату",
+	},
+	[]string{"result"},
+)
+
+// LoginLockouts — счётчик блокировок входа после серии неудачных попыток.
+//
+// Метрика: login_lockouts_total
+// Labels:
+// - scope: account (по имени пользователя) или ip
+var LoginLockouts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "login_lockouts_total",
+		Help: "Количество блокировок входа после неудачных попыток",
+	},
+	[]string{"scope"},
+)
+
 func init() {
 	// Регистрируем метрики в Prometheus, чтобы они были доступны для сбора
 	prometheus.MustRegister(KafkaEvents)
 	prometheus.MustRegister(KafkaErrors)
+	prometheus.MustRegister(EmailsSent)
+	prometheus.MustRegister(LoginAttempts)
+	prometheus.MustRegister(LoginLockouts)
 }
@@ -8,25 +8,34 @@ import (
 	"time"
 
 	"vira-id/internal/events"
+	"vira-id/internal/mailer"
+	"vira-id/internal/metrics"
 	"vira-id/internal/types"
+	"vira-id/internal/validators"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	config "github.com/skrolikov/vira-config"
 	db "github.com/skrolikov/vira-db"
 	hash "github.com/skrolikov/vira-hash"
-	kafka "github.com/skrolikov/vira-kafka"
 	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
 )
 
 // AuthService — сервис аутентификации и регистрации пользователей.
 // Инкапсулирует логику работы с пользователями, хранение сессий,
 // генерацию токенов и отправку событий.
 type AuthService struct {
-	Cfg      *config.Config    // Конфигурация приложения
-	Repo     db.UserRepository // Репозиторий пользователей (интерфейс к БД)
-	Redis    *redis.Client     // Клиент Redis для хранения сессий
-	Producer *kafka.Producer   // Kafka-продюсер для отправки событий
-	Logger   *log.Logger       // Логгер для записи логов
+	Cfg      *config.Config       // Конфигурация приложения
+	Repo     db.UserRepository    // Репозиторий пользователей (интерфейс к БД)
+	Redis    *redis.Client        // Клиент Redis для хранения сессий
+	Producer *kafkatrace.Producer // Kafka-продюсер для отправки событий
+	Mailer   mailer.Mailer        // Отправка писем пользователям
+	Confirm  LinkConfig           // Настройки писем подтверждения email
+	Reset    LinkConfig           // Настройки писем сброса пароля
+	Policy   validators.Policy    // Требования к паролям
+	Lockout  LockoutConfig        // Защита входа от подбора пароля
+	Logger   *log.Logger          // Логгер для записи логов
 }
 
 // NewAuthService — конструктор для AuthService, инициализирует поля.
@@ -34,7 +43,12 @@ func NewAuthService(
 	cfg *config.Config,
 	repo db.UserRepository,
 	rdb *redis.Client,
-	producer *kafka.Producer,
+	producer *kafkatrace.Producer,
+	mail mailer.Mailer,
+	confirm LinkConfig,
+	reset LinkConfig,
+	policy validators.Policy,
+	lockout LockoutConfig,
 	logger *log.Logger,
 ) *AuthService {
 	return &AuthService{
@@ -42,19 +56,25 @@ func NewAuthService(
 		Repo:     repo,
 		Redis:    rdb,
 		Producer: producer,
+		Mailer:   mail,
+		Confirm:  confirm,
+		Reset:    reset,
+		Policy:   policy,
+		Lockout:  lockout,
 		Logger:   logger,
 	}
 }
 
 // Register — регистрация нового пользователя.
 // Выполняет проверку данных, хеширует пароль, создаёт пользователя в БД,
-// генерирует токены, сохраняет сессию в Redis и отправляет событие регистрации.
+// генерирует токены, сохраняет сессию в Redis, отправляет письмо подтверждения
+// и событие регистрации.
 func (s *AuthService) Register(
 	ctx context.Context,
 	req types.RegisterRequest,
 	ip, userAgent string,
 ) (*types.AuthResponse, error) {
-	// Валидация данных регистрации (имплементировать validateRegistration)
+	// Валидация данных регистрации по политике паролей
 	if err := s.validateRegistration(req); err != nil {
 		return nil, err
 	}
@@ -68,15 +88,13 @@ func (s *AuthService) Register(
 		return nil, db.ErrDuplicateUsername
 	}
 
-	// Если указан email — проверяем уникальность email
-	if req.Email != "" {
-		existsEmail, err := s.Repo.ExistsByEmail(req.Email)
-		if err != nil {
-			return nil, fmt.Errorf("ошибка проверки email: %w", err)
-		}
-		if existsEmail {
-			return nil, db.ErrDuplicateEmail
-		}
+	// Проверяем уникальность email
+	existsEmail, err := s.Repo.ExistsByEmail(req.Email)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки email: %w", err)
+	}
+	if existsEmail {
+		return nil, db.ErrDuplicateEmail
 	}
 
 	// Хешируем пароль
@@ -86,31 +104,31 @@ func (s *AuthService) Register(
 		return nil, fmt.Errorf("ошибка сервера: %w", err)
 	}
 
-	// Генерация токена подтверждения (нужно реализовать generateConfirmToken)
-	confirmToken := generateConfirmToken()
-
-	// Создаём пользователя с ролью user, не подтверждённого, с токеном подтверждения
-	userID, err := s.Repo.CreateUserExtended(req.Username, hashedPass, req.Email, "user", false, confirmToken)
+	// Создаём пользователя с ролью user, не подтверждённого. Токен подтверждения
+	// хранится не в БД, а в Redis (см. issueConfirmToken)
+	userID, err := s.Repo.CreateUserExtended(req.Username, hashedPass, req.Email, "user", false, "")
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: Отправить email с confirmToken (интеграция почты)
+	// Письмо со ссылкой подтверждения отправляем в фоне: без него вход невозможен,
+	// но при сбое почты пользователь запросит письмо повторно
+	go s.sendConfirmation(context.WithoutCancel(ctx), userID, req.Username, req.Email, req.Language)
 
 	// Генерируем JWT или другие токены доступа и обновления
-	tokens, err := s.generateTokens(userID)
+	sessionID := uuid.NewString()
+	tokens, err := s.generateTokens(userID, "user", sessionID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Сохраняем сессию с refresh-токеном, IP и User-Agent в Redis
-	_, err = s.saveSession(ctx, userID, tokens.Refresh, ip, userAgent)
-	if err != nil {
+	if err := s.saveSession(ctx, sessionID, userID, tokens.Refresh, ip, userAgent); err != nil {
 		return nil, err
 	}
 
 	// Асинхронно отправляем событие регистрации в Kafka
-	go s.emitRegistrationEvent(ctx, userID, req.Username, ip, userAgent)
+	go s.emitRegistrationEvent(context.WithoutCancel(ctx), userID, req.Username, ip, userAgent)
 
 	// Возвращаем токены и базовую информацию о пользователе
 	return &types.AuthResponse{
@@ -123,52 +141,58 @@ func (s *AuthService) Register(
 	}, nil
 }
 
-// ConfirmUser — подтверждение пользователя по email и токену подтверждения.
-// Вызывает метод репозитория для подтверждения и обрабатывает ошибки.
-func (s *AuthService) ConfirmUser(ctx context.Context, email, token string) error {
-	err := s.Repo.ConfirmUser(email, token)
-	if err != nil {
-		if errors.Is(err, db.ErrUserNotFound) {
-			return errors.New("пользователь не найден или уже подтвержден")
-		}
-		return fmt.Errorf("ошибка подтверждения пользователя: %w", err)
-	}
-	return nil
-}
-
 // Login — аутентификация пользователя по username и паролю.
-// Проверяет пользователя, пароль, подтверждение, генерирует токены,
-// сохраняет сессию и отправляет событие входа.
+// Проверяет блокировку входа (LockoutConfig), пользователя, пароль и
+// подтверждение, генерирует токены, сохраняет сессию и отправляет событие
+// входа. Неизвестное имя, неверный пароль и неподтверждённый email
+// неразличимы: ErrInvalidCredentials, и каждый случай считается неудачей.
 func (s *AuthService) Login(
 	ctx context.Context,
 	req types.LoginRequest,
 	ip, userAgent string,
 ) (*types.AuthResponse, error) {
-	user, err := s.Repo.GetUserByUsername(req.Username)
-	if err != nil {
-		if errors.Is(err, db.ErrUserNotFound) {
-			return nil, errors.New("пользователь не найден")
+	if err := s.checkLogin(ctx, req.Username, ip); err != nil {
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			metrics.LoginAttempts.WithLabelValues("blocked").Inc()
 		}
 		return nil, err
 	}
 
-	if !hash.CheckPasswordHash(user.PasswordHash, req.Password) {
-		return nil, errors.New("неверный пароль")
+	user, err := s.Repo.GetUserByUsername(req.Username)
+	if errors.Is(err, db.ErrUserNotFound) {
+		// Сравнение с фиктивным хэшем уравнивает время ответа
+		hash.CheckPasswordHash(dummyHash(), req.Password)
+		metrics.LoginAttempts.WithLabelValues("invalid").Inc()
+		s.loginFailed(ctx, req.Username, ip, userAgent, nil)
+		return nil, ErrInvalidCredentials
+	} else if err != nil {
+		return nil, err
 	}
 
+	if !hash.CheckPasswordHash(user.PasswordHash, req.Password) {
+		metrics.LoginAttempts.WithLabelValues("invalid").Inc()
+		s.loginFailed(ctx, req.Username, ip, userAgent, user)
+		return nil, ErrInvalidCredentials
+	}
 	if !user.Confirmed {
-		return nil, errors.New("пользователь не подтверждён. Проверьте почту")
+		// Без событий неудачного входа: пароль верный
+		metrics.LoginAttempts.WithLabelValues("unconfirmed").Inc()
+		s.loginFailed(ctx, req.Username, ip, userAgent, nil)
+		return nil, ErrInvalidCredentials
 	}
 
-	tokens, err := s.generateTokens(user.ID)
+	sessionID := uuid.NewString()
+	tokens, err := s.generateTokens(user.ID, user.Role, sessionID)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = s.saveSession(ctx, user.ID, tokens.Refresh, ip, userAgent)
-	if err != nil {
+	if err := s.saveSession(ctx, sessionID, user.ID, tokens.Refresh, ip, userAgent); err != nil {
 		return nil, err
 	}
+	metrics.LoginAttempts.WithLabelValues("success").Inc()
+	s.loginSucceeded(ctx, req.Username)
 
 	// Обновляем время последнего входа
 	user.LastLoginAt = sql.NullTime{Time: time.Now(), Valid: true}
@@ -177,7 +201,7 @@ func (s *AuthService) Login(
 	}
 
 	// Отправляем событие входа асинхронно
-	go events.EmitUserLoggedInEvent(ctx, s.Producer, s.Logger, user.ID, user.Username, ip, userAgent)
+	go events.EmitUserLoggedInEvent(context.WithoutCancel(ctx), s.Producer, s.Logger, user.ID, user.Username, ip, userAgent)
 
 	return &types.AuthResponse{
 		Tokens: *tokens,
@@ -189,26 +213,15 @@ func (s *AuthService) Login(
 	}, nil
 }
 
-// ChangePassword — смена пароля пользователя.
-// Проверяет старый пароль, хеширует новый, обновляет в БД.
-func (s *AuthService) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
+// GetUser — основные данные пользователя по ID.
+func (s *AuthService) GetUser(ctx context.Context, userID string) (*types.UserInfo, error) {
 	user, err := s.Repo.GetUserByID(userID)
 	if err != nil {
-		return err
-	}
-
-	if !hash.CheckPasswordHash(user.PasswordHash, oldPassword) {
-		return errors.New("старый пароль неверный")
-	}
-
-	newHash, err := hash.HashPassword(newPassword)
-	if err != nil {
-		return err
-	}
-
-	if err := s.Repo.UpdatePassword(userID, newHash); err != nil {
-		return err
+		return nil, err
 	}
-
-	return nil
+	return &types.UserInfo{
+		ID:       user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+	}, nil
 }
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	db "github.com/skrolikov/vira-db"
+)
+
+// confirmKind — вид токенов подтверждения email в Redis.
+const confirmKind = "confirm"
+
+// ConfirmFromEnv читает настройки подтверждения email: CONFIRM_URL,
+// CONFIRM_TOKEN_TTL (по умолчанию 24h), CONFIRM_RESEND_INTERVAL (1m)
+// и CONFIRM_RESEND_PER_HOUR (5).
+func ConfirmFromEnv() (LinkConfig, error) {
+	return linkConfigFromEnv("CONFIRM", LinkConfig{
+		URL:            "http://localhost:3000/confirm",
+		TTL:            24 * time.Hour,
+		ResendInterval: time.Minute,
+		ResendPerHour:  5,
+	})
+}
+
+// ErrInvalidConfirmToken — ссылка подтверждения неизвестна, истекла или заменена новой.
+var ErrInvalidConfirmToken = errors.New("ссылка подтверждения недействительна или устарела")
+
+// sendConfirmation отправляет письмо со ссылкой подтверждения email.
+func (s *AuthService) sendConfirmation(ctx context.Context, userID, username, email, lang string) {
+	s.sendLink(ctx, confirmKind, "confirm", s.Confirm, userID, username, email, lang)
+}
+
+// ConfirmUser подтверждает email пользователя по токену из письма.
+// Токен одноразовый: после подтверждения он удаляется.
+func (s *AuthService) ConfirmUser(ctx context.Context, token string) error {
+	userID, err := s.consumeToken(ctx, confirmKind, token)
+	if err == redis.Nil {
+		return ErrInvalidConfirmToken
+	} else if err != nil {
+		return fmt.Errorf("ошибка Redis: %w", err)
+	}
+
+	user, err := s.Repo.GetUserByID(userID)
+	if errors.Is(err, db.ErrUserNotFound) {
+		return ErrInvalidConfirmToken
+	} else if err != nil {
+		return fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	if !user.Confirmed {
+		user.Confirmed = true
+		if err := s.Repo.UpdateUser(user); err != nil {
+			return fmt.Errorf("ошибка подтверждения пользователя: %w", err)
+		}
+	}
+	return nil
+}
+
+// ResendConfirmation повторно отправляет письмо подтверждения на email.
+// Чтобы по ответу нельзя было узнать, зарегистрирован ли адрес, для неизвестных
+// и уже подтверждённых адресов тоже возвращает nil. Ограничение частоты
+// действует на адрес независимо от того, есть ли он в базе.
+func (s *AuthService) ResendConfirmation(ctx context.Context, email, lang string) error {
+	email = strings.TrimSpace(email)
+	if err := s.throttle(ctx, confirmKind, email, s.Confirm); err != nil {
+		return err
+	}
+
+	user, err := s.Repo.GetUserByEmail(email)
+	if errors.Is(err, db.ErrUserNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+	if user.Confirmed {
+		return nil
+	}
+
+	// В фоне, как при регистрации: время ответа не выдаёт, есть ли адрес в базе
+	go s.sendConfirmation(context.WithoutCancel(ctx), user.ID, user.Username, user.Email, lang)
+	return nil
+}
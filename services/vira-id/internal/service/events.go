@@ -4,8 +4,17 @@ import (
 	"context"
 	"time"
 	"vira-id/internal/events"
+
+	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
 )
 
+// emitEvent отправляет событие пользователя в фоне: ответ клиенту его не
+// ждёт. Тесты подменяют её, чтобы проверить отправленные события.
+var emitEvent = func(ctx context.Context, producer *kafkatrace.Producer, logger *log.Logger, eventType events.EventType, payload events.UserEventPayload) {
+	go events.Emit(context.WithoutCancel(ctx), producer, logger, eventType, payload)
+}
+
 // emitRegistrationEvent отправляет событие регистрации пользователя в Kafka.
 // Используется context с таймаутом 3 секунды, чтобы не блокировать навсегда
 // в случае проблем с отправкой.
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"vira-id/internal/mailer"
+	"vira-id/internal/metrics"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LinkConfig — настройки писем со ссылкой на одноразовый токен
+// (подтверждение email, сброс пароля).
+type LinkConfig struct {
+	URL            string        // Страница фронтенда, на которую ведёт ссылка из письма (?token=...)
+	TTL            time.Duration // Срок действия ссылки
+	ResendInterval time.Duration // Минимальный интервал между письмами на один адрес
+	ResendPerHour  int           // Предел писем на один адрес за час
+}
+
+// linkConfigFromEnv читает <prefix>_URL, <prefix>_TOKEN_TTL,
+// <prefix>_RESEND_INTERVAL и <prefix>_RESEND_PER_HOUR поверх значений по умолчанию.
+func linkConfigFromEnv(prefix string, cfg LinkConfig) (LinkConfig, error) {
+	if v := os.Getenv(prefix + "_URL"); v != "" {
+		cfg.URL = v
+	}
+	if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return LinkConfig{}, fmt.Errorf("%s_URL: нужен абсолютный http(s) адрес, получено %q", prefix, cfg.URL)
+	}
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{prefix + "_TOKEN_TTL", &cfg.TTL},
+		{prefix + "_RESEND_INTERVAL", &cfg.ResendInterval},
+	}
+	for _, d := range durations {
+		v := os.Getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			return LinkConfig{}, fmt.Errorf("%s: некорректная длительность %q", d.env, v)
+		}
+		*d.dst = parsed
+	}
+	if v := os.Getenv(prefix + "_RESEND_PER_HOUR"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return LinkConfig{}, fmt.Errorf("%s_RESEND_PER_HOUR: некорректное значение %q", prefix, v)
+		}
+		cfg.ResendPerHour = n
+	}
+	return cfg, nil
+}
+
+// ThrottledError — письмо на этот адрес отправлялось слишком недавно.
+type ThrottledError struct {
+	RetryAfter time.Duration // Через сколько можно повторить
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("письмо уже отправлено, повторите через %s", e.RetryAfter.Round(time.Second))
+}
+
+// throttle ограничивает частоту писем вида kind на адрес: не чаще
+// ResendInterval и не больше ResendPerHour в час.
+func (s *AuthService) throttle(ctx context.Context, kind, address string, cfg LinkConfig) error {
+	key := kind + "_throttle:" + hashToken(strings.ToLower(strings.TrimSpace(address)))
+
+	ok, err := s.Redis.SetNX(ctx, key+":wait", 1, cfg.ResendInterval).Result()
+	if err != nil {
+		return fmt.Errorf("ошибка Redis: %w", err)
+	}
+	if !ok {
+		ttl, _ := s.Redis.PTTL(ctx, key+":wait").Result()
+		return &ThrottledError{RetryAfter: max(ttl, time.Second)}
+	}
+
+	pipe := s.Redis.TxPipeline()
+	count := pipe.Incr(ctx, key+":hour")
+	pipe.ExpireNX(ctx, key+":hour", time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("ошибка Redis: %w", err)
+	}
+	if count.Val() > int64(cfg.ResendPerHour) {
+		ttl, _ := s.Redis.PTTL(ctx, key+":hour").Result()
+		return &ThrottledError{RetryAfter: max(ttl, time.Second)}
+	}
+	return nil
+}
+
+// Одноразовые токены хранятся в Redis только в виде sha256:
+// {kind}:{hash} — ID пользователя, {kind}_user:{userID} — hash действующего
+// токена (новое письмо отменяет ссылку из старого). Оба ключа живут TTL ссылки.
+func tokenKey(kind, hash string) string       { return kind + ":" + hash }
+func tokenUserKey(kind, userID string) string { return kind + "_user:" + userID }
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueToken выдаёт пользователю новый токен вида kind и отзывает предыдущий.
+func (s *AuthService) issueToken(ctx context.Context, kind, userID string, ttl time.Duration) (string, error) {
+	token, err := generateLinkToken()
+	if err != nil {
+		return "", err
+	}
+	hash := hashToken(token)
+
+	old, err := s.Redis.Get(ctx, tokenUserKey(kind, userID)).Result()
+	if err != nil && err != redis.Nil {
+		return "", fmt.Errorf("ошибка Redis: %w", err)
+	}
+
+	pipe := s.Redis.TxPipeline()
+	if old != "" {
+		pipe.Del(ctx, tokenKey(kind, old))
+	}
+	pipe.Set(ctx, tokenKey(kind, hash), userID, ttl)
+	pipe.Set(ctx, tokenUserKey(kind, userID), hash, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("ошибка сохранения токена: %w", err)
+	}
+	return token, nil
+}
+
+// peekToken возвращает владельца токена вида kind, не расходуя токен.
+// Если токена нет или он устарел — redis.Nil.
+func (s *AuthService) peekToken(ctx context.Context, kind, token string) (string, error) {
+	return s.Redis.Get(ctx, tokenKey(kind, hashToken(token))).Result()
+}
+
+// consume забирает токен KEYS[1] и удаляет индекс KEYS[2] (с префиксом
+// пользователя в ARGV[2]), только если индекс всё ещё указывает на этот
+// токен (ARGV[1]): новая ссылка, выданная параллельно, остаётся действующей.
+var consume = redis.NewScript(`
+local userID = redis.call("GET", KEYS[1])
+if not userID then
+	return false
+end
+redis.call("DEL", KEYS[1])
+local index = ARGV[2] .. userID
+if redis.call("GET", index) == ARGV[1] then
+	redis.call("DEL", index)
+end
+return userID
+`)
+
+// consumeToken атомарно забирает токен вида kind: второй запрос с тем же
+// токеном получит redis.Nil. Возвращает ID пользователя.
+func (s *AuthService) consumeToken(ctx context.Context, kind, token string) (string, error) {
+	hash := hashToken(token)
+	return consume.Run(ctx, s.Redis, []string{tokenKey(kind, hash)}, hash, tokenUserKey(kind, "")).Text()
+}
+
+// sendLink выдаёт токен вида kind и отправляет письмо template со ссылкой на него.
+// Вызывается в фоне: ошибки только логируются, письмо можно запросить повторно.
+func (s *AuthService) sendLink(ctx context.Context, kind, template string, cfg LinkConfig, userID, username, email, lang string) {
+	logger := s.Logger.WithContext(ctx)
+
+	token, err := s.issueToken(ctx, kind, userID, cfg.TTL)
+	if err != nil {
+		logger.Error("Ошибка выдачи токена для письма %s: %v", template, err)
+		return
+	}
+
+	link := cfg.URL
+	if strings.Contains(link, "?") {
+		link += "&token=" + token
+	} else {
+		link += "?token=" + token
+	}
+
+	msg, err := mailer.Render(template, lang, email, map[string]any{
+		"Username": username,
+		"Link":     link,
+		"Hours":    int(cfg.TTL.Hours()),
+		"Minutes":  int(cfg.TTL.Minutes()),
+	})
+	if err != nil {
+		logger.Error("Ошибка сборки письма %s: %v", template, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := s.Mailer.Send(ctx, msg); err != nil {
+		metrics.EmailsSent.WithLabelValues(template, "error").Inc()
+		logger.Error("Ошибка отправки письма %s: %v", template, err)
+		return
+	}
+	metrics.EmailsSent.WithLabelValues(template, "sent").Inc()
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	log "github.com/skrolikov/vira-logger"
+)
+
+func TestConsumeToken(t *testing.T) {
+	const ttl = time.Hour
+
+	tests := []struct {
+		name string
+		// setup выдаёт токены и возвращает тот, который расходуется
+		setup func(t *testing.T, s *AuthService, mr *miniredis.Miniredis) string
+		kind  string
+		want  string // ID пользователя; пусто — токен недействителен
+	}{
+		{
+			name: "действующий токен",
+			setup: func(t *testing.T, s *AuthService, _ *miniredis.Miniredis) string {
+				return issue(t, s, "reset", "u1", ttl)
+			},
+			kind: "reset",
+			want: "u1",
+		},
+		{
+			name: "повторное использование",
+			setup: func(t *testing.T, s *AuthService, _ *miniredis.Miniredis) string {
+				token := issue(t, s, "reset", "u1", ttl)
+				if _, err := s.consumeToken(context.Background(), "reset", token); err != nil {
+					t.Fatalf("первый consumeToken() error = %v", err)
+				}
+				return token
+			},
+			kind: "reset",
+		},
+		{
+			name: "после просмотра токен не расходуется",
+			setup: func(t *testing.T, s *AuthService, _ *miniredis.Miniredis) string {
+				token := issue(t, s, "confirm", "u1", ttl)
+				if userID, err := s.peekToken(context.Background(), "confirm", token); err != nil || userID != "u1" {
+					t.Fatalf("peekToken() = %q, %v", userID, err)
+				}
+				return token
+			},
+			kind: "confirm",
+			want: "u1",
+		},
+		{
+			name: "новый токен отменяет старый",
+			setup: func(t *testing.T, s *AuthService, _ *miniredis.Miniredis) string {
+				token := issue(t, s, "reset", "u1", ttl)
+				issue(t, s, "reset", "u1", ttl)
+				return token
+			},
+			kind: "reset",
+		},
+		{
+			name: "истёкший токен",
+			setup: func(t *testing.T, s *AuthService, mr *miniredis.Miniredis) string {
+				token := issue(t, s, "reset", "u1", ttl)
+				mr.FastForward(ttl)
+				return token
+			},
+			kind: "reset",
+		},
+		{
+			name: "токен другого вида",
+			setup: func(t *testing.T, s *AuthService, _ *miniredis.Miniredis) string {
+				return issue(t, s, "confirm", "u1", ttl)
+			},
+			kind: "reset",
+		},
+		{
+			name: "неизвестный токен",
+			setup: func(*testing.T, *AuthService, *miniredis.Miniredis) string {
+				return "не-выдавался"
+			},
+			kind: "reset",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer rdb.Close()
+			s := &AuthService{Redis: rdb, Logger: log.New(log.Config{})}
+
+			token := tt.setup(t, s, mr)
+			userID, err := s.consumeToken(context.Background(), tt.kind, token)
+			if tt.want == "" {
+				if !errors.Is(err, redis.Nil) {
+					t.Fatalf("consumeToken() = %q, %v, want redis.Nil", userID, err)
+				}
+				return
+			}
+			if err != nil || userID != tt.want {
+				t.Fatalf("consumeToken() = %q, %v, want %q", userID, err, tt.want)
+			}
+
+			// Токен одноразовый: ни он, ни индекс пользователя не остаются
+			if _, err := s.consumeToken(context.Background(), tt.kind, token); !errors.Is(err, redis.Nil) {
+				t.Fatalf("повторный consumeToken() error = %v, want redis.Nil", err)
+			}
+			if mr.Exists(tokenUserKey(tt.kind, tt.want)) {
+				t.Fatal("индекс токена пользователя не удалён")
+			}
+		})
+	}
+}
+
+// issue выдаёт токен вида kind пользователю userID.
+func issue(t *testing.T, s *AuthService, kind, userID string, ttl time.Duration) string {
+	t.Helper()
+	token, err := s.issueToken(context.Background(), kind, userID, ttl)
+	if err != nil {
+		t.Fatalf("issueToken() error = %v", err)
+	}
+	return token
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vira-id/internal/events"
+	"vira-id/internal/metrics"
+
+	"github.com/redis/go-redis/v9"
+	db "github.com/skrolikov/vira-db"
+	hash "github.com/skrolikov/vira-hash"
+)
+
+// LockoutConfig — защита входа от подбора пароля.
+//
+// Попытки по одному имени доходят до проверки пароля не чаще раза
+// в DelayBase; после каждой неудачи интервал удваивается: 2×DelayBase,
+// 4×DelayBase... (не больше MaxDelay).
+// MaxAttempts неудач за Window блокируют вход по имени на LockDuration;
+// каждая следующая блокировка в пределах MaxLockDuration вдвое длиннее
+// (не больше MaxLockDuration). С одного IP допускается IPMaxAttempts неудач
+// за Window по любым именам, затем IP блокируется на LockDuration.
+// После неудач с IP попытки с него по любым именам идут не чаще раза
+// в IPDelayBase×2^(неудач−1) (не больше IPMaxDelay): перебор по многим
+// именам тоже замедляется.
+//
+// Счётчики ведутся и для несуществующих имён: по ответу нельзя понять,
+// есть ли учётная запись.
+type LockoutConfig struct {
+	MaxAttempts     int           // Неудач по имени до блокировки
+	IPMaxAttempts   int           // Неудач с одного IP до блокировки IP
+	Window          time.Duration // Окно подсчёта неудач
+	DelayBase       time.Duration // Интервал между попытками без неудач (0 — без задержек)
+	MaxDelay        time.Duration // Предел задержки между попытками
+	IPDelayBase     time.Duration // Интервал между попытками с IP после первой неудачи (0 — без задержек)
+	IPMaxDelay      time.Duration // Предел задержки между попытками с IP
+	LockDuration    time.Duration // Первая блокировка
+	MaxLockDuration time.Duration // Предел блокировки и срок памяти о прошлых блокировках
+}
+
+// LockoutFromEnv читает LOGIN_MAX_ATTEMPTS (по умолчанию 5), LOGIN_IP_MAX_ATTEMPTS (50),
+// LOGIN_ATTEMPT_WINDOW (15m), LOGIN_DELAY_BASE (1s), LOGIN_MAX_DELAY (30s),
+// LOGIN_IP_DELAY_BASE (100ms), LOGIN_IP_MAX_DELAY (5s), LOGIN_LOCK_DURATION (15m)
+// и LOGIN_MAX_LOCK_DURATION (24h).
+func LockoutFromEnv() (LockoutConfig, error) {
+	cfg := LockoutConfig{
+		MaxAttempts:     5,
+		IPMaxAttempts:   50,
+		Window:          15 * time.Minute,
+		DelayBase:       time.Second,
+		MaxDelay:        30 * time.Second,
+		IPDelayBase:     100 * time.Millisecond,
+		IPMaxDelay:      5 * time.Second,
+		LockDuration:    15 * time.Minute,
+		MaxLockDuration: 24 * time.Hour,
+	}
+
+	ints := []struct {
+		env string
+		dst *int
+	}{
+		{"LOGIN_MAX_ATTEMPTS", &cfg.MaxAttempts},
+		{"LOGIN_IP_MAX_ATTEMPTS", &cfg.IPMaxAttempts},
+	}
+	for _, i := range ints {
+		v := os.Getenv(i.env)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return LockoutConfig{}, fmt.Errorf("%s: некорректное значение %q", i.env, v)
+		}
+		*i.dst = n
+	}
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"LOGIN_ATTEMPT_WINDOW", &cfg.Window},
+		{"LOGIN_DELAY_BASE", &cfg.DelayBase},
+		{"LOGIN_MAX_DELAY", &cfg.MaxDelay},
+		{"LOGIN_IP_DELAY_BASE", &cfg.IPDelayBase},
+		{"LOGIN_IP_MAX_DELAY", &cfg.IPMaxDelay},
+		{"LOGIN_LOCK_DURATION", &cfg.LockDuration},
+		{"LOGIN_MAX_LOCK_DURATION", &cfg.MaxLockDuration},
+	}
+	for _, d := range durations {
+		v := os.Getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			return LockoutConfig{}, fmt.Errorf("%s: некорректная длительность %q", d.env, v)
+		}
+		*d.dst = parsed
+	}
+
+	if cfg.Window <= 0 || cfg.LockDuration <= 0 {
+		return LockoutConfig{}, errors.New("LOGIN_ATTEMPT_WINDOW и LOGIN_LOCK_DURATION должны быть больше нуля")
+	}
+	if cfg.DelayBase > 0 && cfg.MaxDelay < cfg.DelayBase {
+		return LockoutConfig{}, fmt.Errorf("LOGIN_MAX_DELAY (%s) меньше LOGIN_DELAY_BASE (%s)", cfg.MaxDelay, cfg.DelayBase)
+	}
+	if cfg.IPDelayBase > 0 && cfg.IPMaxDelay < cfg.IPDelayBase {
+		return LockoutConfig{}, fmt.Errorf("LOGIN_IP_MAX_DELAY (%s) меньше LOGIN_IP_DELAY_BASE (%s)", cfg.IPMaxDelay, cfg.IPDelayBase)
+	}
+	if cfg.MaxLockDuration < cfg.LockDuration {
+		return LockoutConfig{}, fmt.Errorf("LOGIN_MAX_LOCK_DURATION (%s) меньше LOGIN_LOCK_DURATION (%s)", cfg.MaxLockDuration, cfg.LockDuration)
+	}
+	return cfg, nil
+}
+
+// ErrInvalidCredentials — неверное имя или пароль, либо email не подтверждён.
+// Одна ошибка на все случаи, чтобы по ответу нельзя было перебирать имена
+// пользователей и проверять пароли неподтверждённых учётных записей.
+var ErrInvalidCredentials = errors.New("неверный логин или пароль")
+
+// LoginBlockedError — вход по имени или с IP временно запрещён:
+// после неудачной попытки не прошла задержка или сработала блокировка.
+type LoginBlockedError struct {
+	RetryAfter time.Duration // Через сколько можно повторить
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("слишком много неудачных попыток входа, повторите через %s", e.RetryAfter.Round(time.Second))
+}
+
+// Ключи Redis защиты входа. Имя пользователя хранится хэшем.
+func loginUserKey(part, username string) string {
+	return "login_" + part + ":user:" + hashToken(strings.ToLower(username))
+}
+
+func loginIPKey(part, ip string) string {
+	return "login_" + part + ":ip:" + ip
+}
+
+// dummyHash — bcrypt хэш, с которым сравнивается пароль несуществующего
+// пользователя: время ответа не выдаёт, есть ли учётная запись.
+var dummyHash = sync.OnceValue(func() string {
+	h, _ := hash.HashPassword("vira-id: нет такого пользователя")
+	return h
+})
+
+// reserveLogin проверяет блокировки и задержки имени и IP и, если вход
+// разрешён, сразу занимает слоты задержки: по имени DelayBase×2^(неудач
+// по имени), не больше MaxDelay; по IP, если с него уже были неудачи,
+// IPDelayBase×2^(неудач с IP−1), не больше IPMaxDelay. Проверка и слоты
+// атомарны, поэтому параллельные попытки с одним именем или с одного IP
+// не проходят к сравнению пароля одновременно. Возвращает, сколько
+// миллисекунд ждать (0 — можно проверять пароль).
+//
+// KEYS: lock по имени, delay по имени, lock по IP, delay по IP,
+// fails по имени, fails по IP.
+// ARGV: DelayBase, MaxDelay, IPDelayBase и IPMaxDelay в миллисекундах.
+var reserveLogin = redis.NewScript(`
+local wait = 0
+for i = 1, 4 do
+	local ttl = redis.call('PTTL', KEYS[i])
+	if ttl > wait then
+		wait = ttl
+	end
+end
+if wait > 0 then
+	return wait
+end
+
+local base = tonumber(ARGV[1])
+if base > 0 then
+	local fails = tonumber(redis.call('GET', KEYS[5]) or '0')
+	local delay = math.min(base * 2 ^ fails, tonumber(ARGV[2]))
+	redis.call('SET', KEYS[2], 1, 'PX', math.floor(delay))
+end
+
+local ipBase = tonumber(ARGV[3])
+if ipBase > 0 then
+	local fails = tonumber(redis.call('GET', KEYS[6]) or '0')
+	if fails > 0 then
+		local delay = math.min(ipBase * 2 ^ (fails - 1), tonumber(ARGV[4]))
+		redis.call('SET', KEYS[4], 1, 'PX', math.floor(delay))
+	end
+end
+return 0
+`)
+
+// checkLogin возвращает *LoginBlockedError, если вход по имени или с IP
+// сейчас запрещён, а иначе занимает слоты задержки (reserveLogin).
+// С LOGIN_DELAY_BASE=0 и LOGIN_IP_DELAY_BASE=0 слотов нет, и параллельные
+// попытки ограничены только порогами блокировок.
+func (s *AuthService) checkLogin(ctx context.Context, username, ip string) error {
+	cfg := s.Lockout
+	keys := []string{
+		loginUserKey("lock", username),
+		loginUserKey("delay", username),
+		loginIPKey("lock", ip),
+		loginIPKey("delay", ip),
+		loginUserKey("fails", username),
+		loginIPKey("fails", ip),
+	}
+	ms, err := reserveLogin.Run(ctx, s.Redis, keys,
+		cfg.DelayBase.Milliseconds(), cfg.MaxDelay.Milliseconds(),
+		cfg.IPDelayBase.Milliseconds(), cfg.IPMaxDelay.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return fmt.Errorf("ошибка Redis: %w", err)
+	}
+
+	if wait := time.Duration(ms) * time.Millisecond; wait > 0 {
+		return &LoginBlockedError{RetryAfter: max(wait, time.Second)}
+	}
+	return nil
+}
+
+// loginFailed учитывает неудачную попытку: при превышении порогов блокирует
+// имя или IP и отправляет события. Задержку до следующей попытки уже занял
+// checkLogin; следующий слот будет вдвое длиннее. user — nil, если
+// такого пользователя нет. Ошибки Redis только логируются: ответ клиенту
+// уже определён.
+func (s *AuthService) loginFailed(ctx context.Context, username, ip, userAgent string, user *db.User) {
+	cfg := s.Lockout
+	logger := s.Logger.WithContext(ctx).WithFields(map[string]any{"ip": ip})
+
+	pipe := s.Redis.TxPipeline()
+	userFails := pipe.Incr(ctx, loginUserKey("fails", username))
+	pipe.ExpireNX(ctx, loginUserKey("fails", username), cfg.Window)
+	ipFails := pipe.Incr(ctx, loginIPKey("fails", ip))
+	pipe.ExpireNX(ctx, loginIPKey("fails", ip), cfg.Window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.Error("Ошибка учёта неудачного входа: %v", err)
+		return
+	}
+	attempts := int(userFails.Val())
+
+	var lockedFor time.Duration
+	if attempts >= cfg.MaxAttempts {
+		// Каждая блокировка в пределах MaxLockDuration вдвое длиннее предыдущей
+		locks, err := s.Redis.Incr(ctx, loginUserKey("locks", username)).Result()
+		if err != nil {
+			logger.Error("Ошибка учёта блокировок входа: %v", err)
+			locks = 1
+		}
+		s.Redis.Expire(ctx, loginUserKey("locks", username), cfg.MaxLockDuration)
+
+		lockedFor = backoff(cfg.LockDuration, int(locks), cfg.MaxLockDuration)
+		pipe := s.Redis.TxPipeline()
+		pipe.Set(ctx, loginUserKey("lock", username), 1, lockedFor)
+		pipe.Del(ctx, loginUserKey("fails", username), loginUserKey("delay", username))
+		if _, err := pipe.Exec(ctx); err != nil {
+			logger.Error("Ошибка блокировки входа: %v", err)
+		}
+		metrics.LoginLockouts.WithLabelValues("account").Inc()
+		logger.Warn("🔒 Вход по имени %q заблокирован на %s после %d неудачных попыток", username, lockedFor, attempts)
+	}
+
+	if ipFails.Val() >= int64(cfg.IPMaxAttempts) {
+		pipe := s.Redis.TxPipeline()
+		pipe.Set(ctx, loginIPKey("lock", ip), 1, cfg.LockDuration)
+		pipe.Del(ctx, loginIPKey("fails", ip), loginIPKey("delay", ip))
+		if _, err := pipe.Exec(ctx); err != nil {
+			logger.Error("Ошибка блокировки IP: %v", err)
+		}
+		metrics.LoginLockouts.WithLabelValues("ip").Inc()
+		logger.Warn("🔒 Вход с IP %s заблокирован на %s после %d неудачных попыток", ip, cfg.LockDuration, ipFails.Val())
+	}
+
+	if user == nil {
+		return
+	}
+	emitEvent(ctx, s.Producer, s.Logger, events.UserLoginFailedEvent, events.UserEventPayload{
+		UserID: user.ID, Username: user.Username, IP: ip, Device: userAgent,
+		Metadata: events.Metadata{"attempts": attempts},
+	})
+	if lockedFor > 0 {
+		emitEvent(ctx, s.Producer, s.Logger, events.UserLockedEvent, events.UserEventPayload{
+			UserID: user.ID, Username: user.Username, IP: ip, Device: userAgent,
+			Metadata: events.Metadata{
+				"locked_for":   lockedFor.String(),
+				"locked_until": time.Now().Add(lockedFor).UTC().Format(time.RFC3339),
+			},
+		})
+	}
+}
+
+// loginSucceeded сбрасывает счётчик неудач и задержку по имени после
+// полностью успешного входа. Память о прошлых блокировках остаётся:
+// повторный подбор заблокируется дольше. Счётчик по IP не сбрасывается:
+// иначе вход в свою учётную запись обнулял бы перебор чужих с того же IP.
+func (s *AuthService) loginSucceeded(ctx context.Context, username string) {
+	if err := s.Redis.Del(ctx, loginUserKey("fails", username), loginUserKey("delay", username)).Err(); err != nil {
+		s.Logger.WithContext(ctx).Error("Ошибка сброса счётчика неудачных входов: %v", err)
+	}
+}
+
+// UnlockLogin снимает блокировку и обнуляет счётчики входа для имени
+// и (или) IP. Пустое значение пропускается.
+func (s *AuthService) UnlockLogin(ctx context.Context, username, ip string) error {
+	var keys []string
+	if username != "" {
+		for _, part := range []string{"fails", "delay", "lock", "locks"} {
+			keys = append(keys, loginUserKey(part, username))
+		}
+	}
+	if ip != "" {
+		keys = append(keys, loginIPKey("fails", ip), loginIPKey("delay", ip), loginIPKey("lock", ip))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := s.Redis.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("ошибка Redis: %w", err)
+	}
+	s.Logger.WithContext(ctx).Info("🔓 Вход разблокирован: имя %q, IP %q", username, ip)
+	return nil
+}
+
+// backoff — base, умноженное на 2^(n-1), но не больше limit.
+func backoff(base time.Duration, n int, limit time.Duration) time.Duration {
+	d := base
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
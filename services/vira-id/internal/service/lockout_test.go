@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"vira-id/internal/events"
+	"vira-id/internal/types"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	db "github.com/skrolikov/vira-db"
+	hash "github.com/skrolikov/vira-hash"
+	log "github.com/skrolikov/vira-logger"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
+)
+
+const password = "Верный-пароль-1"
+
+// fakeRepo — репозиторий пользователей в памяти. Не нужные тестам методы
+// не реализованы.
+type fakeRepo struct {
+	db.UserRepository
+	users map[string]*db.User
+}
+
+func (r fakeRepo) GetUserByUsername(username string) (*db.User, error) {
+	if u, ok := r.users[username]; ok {
+		return u, nil
+	}
+	return nil, db.ErrUserNotFound
+}
+
+// sentEvent — событие, перехваченное вместо отправки в Kafka.
+type sentEvent struct {
+	Type     events.EventType
+	Metadata events.Metadata
+}
+
+// newTestService создаёт AuthService поверх miniredis с пользователем alice
+// и пользователем bob с неподтверждённым email (у обоих пароль password). Отправленные события складываются в *[]sentEvent.
+func newTestService(t *testing.T, lockout LockoutConfig) (*AuthService, *miniredis.Miniredis, *[]sentEvent) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	passwordHash, err := hash.HashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := fakeRepo{users: map[string]*db.User{
+		"alice": {ID: "u1", Username: "alice", PasswordHash: passwordHash, Confirmed: true},
+		"bob":   {ID: "u2", Username: "bob", PasswordHash: passwordHash},
+	}}
+
+	var sent []sentEvent
+	orig := emitEvent
+	emitEvent = func(_ context.Context, _ *kafkatrace.Producer, _ *log.Logger, eventType events.EventType, payload events.UserEventPayload) {
+		sent = append(sent, sentEvent{Type: eventType, Metadata: payload.Metadata})
+	}
+	t.Cleanup(func() { emitEvent = orig })
+
+	return &AuthService{Repo: repo, Redis: rdb, Lockout: lockout, Logger: log.New(log.Config{})}, mr, &sent
+}
+
+// failLogin выполняет вход с неверным паролем.
+func failLogin(s *AuthService, username, ip string) error {
+	_, err := s.Login(context.Background(), types.LoginRequest{Username: username, Password: "неверный"}, ip, "test")
+	return err
+}
+
+// retryAfter возвращает RetryAfter, если вход заблокирован, иначе 0.
+func retryAfter(err error) time.Duration {
+	var blocked *LoginBlockedError
+	if errors.As(err, &blocked) {
+		return blocked.RetryAfter
+	}
+	return 0
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{0, time.Second},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoff(time.Second, tt.n, 30*time.Second); got != tt.want {
+			t.Errorf("backoff(1s, %d, 30s) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLoginDelay(t *testing.T) {
+	s, mr, _ := newTestService(t, LockoutConfig{
+		MaxAttempts:     100,
+		IPMaxAttempts:   100,
+		Window:          time.Hour,
+		DelayBase:       time.Second,
+		MaxDelay:        5 * time.Second,
+		LockDuration:    time.Hour,
+		MaxLockDuration: time.Hour,
+	})
+
+	// Интервал до следующей попытки после каждой неудачи подряд
+	tests := []struct {
+		name string
+		want time.Duration
+	}{
+		{"первая неудача", time.Second},
+		{"вторая неудача", 2 * time.Second},
+		{"третья неудача", 4 * time.Second},
+		{"предел MaxDelay", 5 * time.Second},
+		{"предел не растёт", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := failLogin(s, "alice", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Login() error = %v, want ErrInvalidCredentials", err)
+			}
+			if got := mr.TTL(loginUserKey("delay", "alice")); got != tt.want {
+				t.Fatalf("задержка = %s, want %s", got, tt.want)
+			}
+			// Повтор до конца задержки не доходит до проверки пароля, даже верный
+			_, err := s.Login(context.Background(), types.LoginRequest{Username: "alice", Password: password}, "192.0.2.1", "test")
+			if got := retryAfter(err); got != tt.want {
+				t.Fatalf("Login() до конца задержки: error = %v, want RetryAfter %s", err, tt.want)
+			}
+			mr.FastForward(tt.want)
+		})
+	}
+}
+
+func TestLoginIPDelay(t *testing.T) {
+	s, mr, _ := newTestService(t, LockoutConfig{
+		MaxAttempts:     100,
+		IPMaxAttempts:   100,
+		Window:          time.Hour,
+		IPDelayBase:     100 * time.Millisecond,
+		IPMaxDelay:      400 * time.Millisecond,
+		LockDuration:    time.Hour,
+		MaxLockDuration: time.Hour,
+	})
+
+	// Каждая попытка с новым именем: задержка растёт по неудачам с IP
+	tests := []struct {
+		name string
+		want time.Duration // Задержка, занятая попыткой (0 — нет)
+	}{
+		{"без неудач задержки нет", 0},
+		{"после первой неудачи", 100 * time.Millisecond},
+		{"после второй неудачи", 200 * time.Millisecond},
+		{"после третьей неудачи", 400 * time.Millisecond},
+		{"предел IPMaxDelay", 400 * time.Millisecond},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := failLogin(s, "user"+string(rune('a'+i)), "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Login() error = %v, want ErrInvalidCredentials", err)
+			}
+			if got := mr.TTL(loginIPKey("delay", "192.0.2.1")); got != tt.want {
+				t.Fatalf("задержка IP = %s, want %s", got, tt.want)
+			}
+			if tt.want == 0 {
+				return
+			}
+
+			// До конца задержки с этого IP не проходит и другое имя, с другого IP — проходит
+			if got := retryAfter(failLogin(s, "alice", "192.0.2.1")); got != time.Second {
+				t.Fatalf("с того же IP: RetryAfter = %s, want 1s", got)
+			}
+			if got := retryAfter(failLogin(s, "alice", "198.51.100.1")); got > 0 {
+				t.Fatalf("с другого IP: RetryAfter = %s, want 0", got)
+			}
+			mr.FastForward(tt.want)
+		})
+	}
+}
+
+func TestLoginUnconfirmed(t *testing.T) {
+	s, mr, sent := newTestService(t, LockoutConfig{
+		MaxAttempts:     100,
+		IPMaxAttempts:   100,
+		Window:          time.Hour,
+		LockDuration:    time.Hour,
+		MaxLockDuration: time.Hour,
+	})
+
+	failLogin(s, "bob", "192.0.2.1")
+	// Верный пароль неподтверждённой учётной записи неотличим от неверного
+	_, err := s.Login(context.Background(), types.LoginRequest{Username: "bob", Password: password}, "192.0.2.1", "test")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login() error = %v, want ErrInvalidCredentials", err)
+	}
+
+	// Счётчик неудач не сбрасывается, а растёт
+	if got, _ := mr.Get(loginUserKey("fails", "bob")); got != "2" {
+		t.Fatalf("неудач по имени = %q, want 2", got)
+	}
+	if len(*sent) != 1 || (*sent)[0].Type != events.UserLoginFailedEvent {
+		t.Fatalf("события = %+v, want одно %s", *sent, events.UserLoginFailedEvent)
+	}
+}
+
+func TestLoginLockGrowth(t *testing.T) {
+	s, mr, sent := newTestService(t, LockoutConfig{
+		MaxAttempts:     2,
+		IPMaxAttempts:   100,
+		Window:          time.Hour,
+		LockDuration:    time.Minute,
+		MaxLockDuration: 3 * time.Minute,
+	})
+
+	tests := []struct {
+		name string
+		want time.Duration
+	}{
+		{"первая блокировка", time.Minute},
+		{"вторая вдвое длиннее", 2 * time.Minute},
+		{"предел MaxLockDuration", 3 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*sent = nil
+			for range s.Lockout.MaxAttempts {
+				if err := failLogin(s, "alice", "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("Login() error = %v, want ErrInvalidCredentials", err)
+				}
+			}
+			if got := retryAfter(failLogin(s, "alice", "192.0.2.2")); got != tt.want {
+				t.Fatalf("блокировка = %s, want %s", got, tt.want)
+			}
+
+			want := []events.EventType{events.UserLoginFailedEvent, events.UserLoginFailedEvent, events.UserLockedEvent}
+			if len(*sent) != len(want) {
+				t.Fatalf("события = %+v, want %v", *sent, want)
+			}
+			for i, e := range *sent {
+				if e.Type != want[i] {
+					t.Fatalf("событие %d = %s, want %s", i, e.Type, want[i])
+				}
+			}
+			if got := (*sent)[1].Metadata["attempts"]; got != 2 {
+				t.Fatalf("attempts = %v, want 2", got)
+			}
+			if got := (*sent)[2].Metadata["locked_for"]; got != tt.want.String() {
+				t.Fatalf("locked_for = %v, want %s", got, tt.want)
+			}
+			mr.FastForward(tt.want)
+		})
+	}
+}
+
+func TestLoginIPLock(t *testing.T) {
+	tests := []struct {
+		name    string
+		fails   int
+		blocked bool
+	}{
+		{"ниже порога", 2, false},
+		{"порог", 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService(t, LockoutConfig{
+				MaxAttempts:     100,
+				IPMaxAttempts:   3,
+				Window:          time.Hour,
+				LockDuration:    time.Minute,
+				MaxLockDuration: time.Hour,
+			})
+
+			// Каждая попытка с новым именем: блокируется IP, а не имя
+			for i := range tt.fails {
+				failLogin(s, "user"+string(rune('a'+i)), "192.0.2.1")
+			}
+
+			if got := retryAfter(failLogin(s, "alice", "192.0.2.1")); (got > 0) != tt.blocked {
+				t.Fatalf("с того же IP: RetryAfter = %s, want blocked %t", got, tt.blocked)
+			}
+			if got := retryAfter(failLogin(s, "alice", "198.51.100.1")); got > 0 {
+				t.Fatalf("с другого IP: RetryAfter = %s, want 0", got)
+			}
+		})
+	}
+}
+
+func TestUnlockLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		ip       string
+		blocked  bool
+	}{
+		{"имя и IP", "alice", "192.0.2.1", false},
+		{"только имя", "alice", "", true},
+		{"только IP", "", "192.0.2.1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService(t, LockoutConfig{
+				MaxAttempts:     1,
+				IPMaxAttempts:   1,
+				Window:          time.Hour,
+				LockDuration:    time.Minute,
+				MaxLockDuration: time.Hour,
+			})
+
+			// Одна неудача блокирует и имя, и IP
+			failLogin(s, "alice", "192.0.2.1")
+			if err := s.UnlockLogin(context.Background(), tt.username, tt.ip); err != nil {
+				t.Fatalf("UnlockLogin() error = %v", err)
+			}
+
+			err := failLogin(s, "alice", "192.0.2.1")
+			if got := retryAfter(err); (got > 0) != tt.blocked {
+				t.Fatalf("Login() error = %v, want blocked %t", err, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestLoginFailedEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     []events.EventType
+	}{
+		{"существующий пользователь", "alice", []events.EventType{events.UserLoginFailedEvent, events.UserLockedEvent}},
+		{"неизвестное имя", "mallory", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mr, sent := newTestService(t, LockoutConfig{
+				MaxAttempts:     1,
+				IPMaxAttempts:   100,
+				Window:          time.Hour,
+				LockDuration:    time.Minute,
+				MaxLockDuration: time.Hour,
+			})
+
+			if err := failLogin(s, tt.username, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("Login() error = %v, want ErrInvalidCredentials", err)
+			}
+			// Неизвестное имя блокируется так же, как существующее
+			if !mr.Exists(loginUserKey("lock", tt.username)) {
+				t.Fatal("вход по имени не заблокирован")
+			}
+
+			if len(*sent) != len(tt.want) {
+				t.Fatalf("события = %+v, want %v", *sent, tt.want)
+			}
+			for i, e := range *sent {
+				if e.Type != tt.want[i] {
+					t.Fatalf("событие %d = %s, want %s", i, e.Type, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"vira-id/internal/events"
+	"vira-id/internal/validators"
+
+	"github.com/redis/go-redis/v9"
+	db "github.com/skrolikov/vira-db"
+	hash "github.com/skrolikov/vira-hash"
+)
+
+// resetKind — вид токенов сброса пароля в Redis.
+const resetKind = "password_reset"
+
+// PasswordResetFromEnv читает настройки сброса пароля: PASSWORD_RESET_URL,
+// PASSWORD_RESET_TOKEN_TTL (по умолчанию 30m), PASSWORD_RESET_RESEND_INTERVAL (1m)
+// и PASSWORD_RESET_RESEND_PER_HOUR (5).
+func PasswordResetFromEnv() (LinkConfig, error) {
+	return linkConfigFromEnv("PASSWORD_RESET", LinkConfig{
+		URL:            "http://localhost:3000/reset-password",
+		TTL:            30 * time.Minute,
+		ResendInterval: time.Minute,
+		ResendPerHour:  5,
+	})
+}
+
+// ErrWrongPassword — текущий пароль указан неверно.
+var ErrWrongPassword = errors.New("текущий пароль неверный")
+
+// ErrInvalidResetToken — ссылка сброса пароля неизвестна, истекла, уже использована
+// или заменена новой.
+var ErrInvalidResetToken = errors.New("ссылка сброса пароля недействительна или устарела")
+
+// ForgotPassword отправляет письмо со ссылкой сброса пароля. login — имя
+// пользователя или email. Для неизвестных пользователей и пользователей без
+// email тоже возвращает nil: по ответу нельзя узнать, есть ли учётная запись.
+// Частота ограничивается на пользователя, а не на строку login: чередование
+// имени, email и регистра не даёт лишних писем. Для неизвестного login
+// ограничение то же, чтобы ответ 429 не выдавал, есть ли учётная запись.
+func (s *AuthService) ForgotPassword(ctx context.Context, login, lang string) error {
+	login = strings.TrimSpace(login)
+
+	var (
+		user *db.User
+		err  error
+	)
+	if strings.Contains(login, "@") {
+		user, err = s.Repo.GetUserByEmail(login)
+	} else {
+		user, err = s.Repo.GetUserByUsername(login)
+	}
+	if err != nil && !errors.Is(err, db.ErrUserNotFound) {
+		return fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	subject := "login:" + login
+	if user != nil && err == nil {
+		subject = "user:" + user.ID
+	}
+	if err := s.throttle(ctx, resetKind, subject, s.Reset); err != nil {
+		return err
+	}
+	if errors.Is(err, db.ErrUserNotFound) || user.Email == "" {
+		return nil
+	}
+
+	// В фоне: время ответа не выдаёт, есть ли учётная запись
+	go s.sendLink(context.WithoutCancel(ctx), resetKind, "password_reset", s.Reset, user.ID, user.Username, user.Email, lang)
+	return nil
+}
+
+// ResetPassword задаёт новый пароль по токену из письма. Токен одноразовый.
+// После смены пароля закрываются все сессии пользователя: украденный
+// refresh токен перестаёт работать. Ссылка пришла на email, поэтому
+// неподтверждённый адрес заодно считается подтверждённым.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword, ip, userAgent string) error {
+	// Пароль проверяется до того, как токен будет израсходован: с ошибкой
+	// в пароле ссылку из письма можно открыть ещё раз. Владелец нужен,
+	// чтобы сравнить пароль с его именем.
+	userID, err := s.peekToken(ctx, resetKind, token)
+	if err == redis.Nil {
+		return ErrInvalidResetToken
+	} else if err != nil {
+		return fmt.Errorf("ошибка Redis: %w", err)
+	}
+
+	user, err := s.Repo.GetUserByID(userID)
+	if errors.Is(err, db.ErrUserNotFound) {
+		return ErrInvalidResetToken
+	} else if err != nil {
+		return fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	if err := s.validatePassword("password", newPassword, user.Username); err != nil {
+		return err
+	}
+
+	// Токен могли израсходовать параллельным запросом или выдать заново
+	if consumed, err := s.consumeToken(ctx, resetKind, token); err == redis.Nil || (err == nil && consumed != userID) {
+		return ErrInvalidResetToken
+	} else if err != nil {
+		return fmt.Errorf("ошибка Redis: %w", err)
+	}
+
+	newHash, err := hash.HashPassword(newPassword)
+	if err != nil {
+		s.Logger.Error("Ошибка хеширования пароля: %v", err)
+		return fmt.Errorf("ошибка сервера: %w", err)
+	}
+	if err := s.Repo.UpdatePassword(userID, newHash); err != nil {
+		return err
+	}
+
+	if !user.Confirmed {
+		user.Confirmed = true
+		if err := s.Repo.UpdateUser(user); err != nil {
+			s.Logger.WithContext(ctx).Error("Ошибка подтверждения email при сбросе пароля: %v", err)
+		}
+	}
+
+	if err := s.RevokeOtherSessions(ctx, userID, ""); err != nil {
+		// Пароль уже сменён: сообщаем об ошибке, чтобы клиент не считал сессии закрытыми
+		return err
+	}
+
+	emitEvent(ctx, s.Producer, s.Logger, events.UserPasswordResetEvent, events.UserEventPayload{
+		UserID: user.ID, Username: user.Username, IP: ip, Device: userAgent,
+	})
+	return nil
+}
+
+// ChangePassword — смена пароля пользователем, который знает текущий.
+// Новый пароль проходит те же проверки, что при регистрации. После смены
+// закрываются все сессии пользователя, кроме keepSessionID (пусто — все).
+// Неверный текущий пароль считается неудачным входом (LockoutConfig):
+// украденный access токен не даёт подбирать пароль без ограничений.
+func (s *AuthService) ChangePassword(ctx context.Context, userID, oldPassword, newPassword, keepSessionID, ip, userAgent string) error {
+	user, err := s.Repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkLogin(ctx, user.Username, ip); err != nil {
+		return err
+	}
+	if !hash.CheckPasswordHash(user.PasswordHash, oldPassword) {
+		s.loginFailed(ctx, user.Username, ip, userAgent, user)
+		return ErrWrongPassword
+	}
+	if newPassword == oldPassword {
+		var errs validators.Errors
+		errs.Add("new_password", "same_as_current", "новый пароль совпадает с текущим")
+		return errs
+	}
+	if err := s.validatePassword("new_password", newPassword, user.Username); err != nil {
+		return err
+	}
+
+	newHash, err := hash.HashPassword(newPassword)
+	if err != nil {
+		s.Logger.Error("Ошибка хеширования пароля: %v", err)
+		return fmt.Errorf("ошибка сервера: %w", err)
+	}
+	if err := s.Repo.UpdatePassword(userID, newHash); err != nil {
+		return err
+	}
+
+	if err := s.RevokeOtherSessions(ctx, userID, keepSessionID); err != nil {
+		return err
+	}
+
+	emitEvent(ctx, s.Producer, s.Logger, events.UserPasswordChangedEvent, events.UserEventPayload{
+		UserID: user.ID, Username: user.Username, IP: ip, Device: userAgent,
+	})
+	return nil
+}
@@ -3,12 +3,13 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 	"vira-id/internal/types"
 
-	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	jwt "github.com/skrolikov/vira-jwt"
 )
 
 // saveSession сохраняет информацию о сессии пользователя в Redis.
@@ -16,9 +17,8 @@ import (
 // 1) session:{userID}:{sessionID} — сериализованная структура сессии с информацией (IP, устройство, время и т.д.),
 // 2) refresh:{refreshToken} — содержит ссылку на sessionKey для быстрого поиска сессии по refresh токену.
 // TTL для обоих ключей берется из конфигурации (время жизни refresh токена).
-func (s *AuthService) saveSession(ctx context.Context, userID, refreshToken, ip, userAgent string) (string, error) {
-	// Генерируем уникальный ID сессии
-	sessionID := uuid.NewString()
+// ID сессии генерируется заранее, так как он зашивается в access токен.
+func (s *AuthService) saveSession(ctx context.Context, sessionID, userID, refreshToken, ip, userAgent string) error {
 	// Формируем ключ для сессии
 	sessionKey := "session:" + userID + ":" + sessionID
 	// Формируем ключ для индексации refresh токена
@@ -38,7 +38,7 @@ func (s *AuthService) saveSession(ctx context.Context, userID, refreshToken, ip,
 	sessionData, err := json.Marshal(session)
 	if err != nil {
 		s.Logger.Error("Ошибка сериализации сессии: %v", err)
-		return "", fmt.Errorf("ошибка сервера")
+		return fmt.Errorf("ошибка сервера")
 	}
 
 	// Создаем pipeline для пакетной отправки нескольких команд в Redis
@@ -50,11 +50,10 @@ func (s *AuthService) saveSession(ctx context.Context, userID, refreshToken, ip,
 	_, err = pipe.Exec(ctx)
 	if err != nil {
 		s.Logger.Error("Ошибка сохранения сессии и индекса refresh в Redis: %v", err)
-		return "", fmt.Errorf("ошибка сохранения сессии: %w", err)
+		return fmt.Errorf("ошибка сохранения сессии: %w", err)
 	}
 
-	// Возвращаем ID сессии
-	return sessionID, nil
+	return nil
 }
 
 // GetUserSessions возвращает все активные сессии пользователя, которые хранятся в Redis.
@@ -99,3 +98,84 @@ func (s *AuthService) GetUserSessions(ctx context.Context, userID string) ([]typ
 
 	return sessions, nil
 }
+
+var (
+	// ErrSessionNotFound — сессия не существует, истекла или уже закрыта.
+	ErrSessionNotFound = errors.New("сессия не найдена")
+	// ErrInvalidRefreshToken — refresh токен с неверной подписью, истёкший или другого типа.
+	ErrInvalidRefreshToken = errors.New("некорректный refresh токен")
+)
+
+// Logout закрывает сессию по её refresh токену: удаляет сессию
+// и индекс refresh:{refreshToken}.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	claims, err := jwt.ParseToken(refreshToken, s.Cfg.JwtSecret)
+	if err != nil || !jwt.IsTokenType(claims, "refresh") {
+		return ErrInvalidRefreshToken
+	}
+
+	sessionKey, err := s.Redis.Get(ctx, "refresh:"+refreshToken).Result()
+	if err == redis.Nil {
+		return ErrSessionNotFound
+	} else if err != nil {
+		return fmt.Errorf("ошибка Redis: %w", err)
+	}
+
+	pipe := s.Redis.Pipeline()
+	pipe.Del(ctx, sessionKey)
+	pipe.Del(ctx, "refresh:"+refreshToken)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("ошибка удаления сессии: %w", err)
+	}
+	return nil
+}
+
+// RevokeSession закрывает сессию sessionID пользователя userID
+// вместе с индексом её refresh токена.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	sessionKey := "session:" + userID + ":" + sessionID
+	data, err := s.Redis.Get(ctx, sessionKey).Result()
+	if err == redis.Nil {
+		return ErrSessionNotFound
+	} else if err != nil {
+		return fmt.Errorf("ошибка Redis: %w", err)
+	}
+
+	pipe := s.Redis.Pipeline()
+	pipe.Del(ctx, sessionKey)
+	var session types.SessionInfo
+	if err := json.Unmarshal([]byte(data), &session); err == nil && session.Token != "" {
+		pipe.Del(ctx, "refresh:"+session.Token)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("ошибка удаления сессии: %w", err)
+	}
+	return nil
+}
+
+// RevokeOtherSessions закрывает все сессии пользователя, кроме keepSessionID
+// (пусто — все), вместе с индексами их refresh токенов.
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, userID, keepSessionID string) error {
+	sessions, err := s.GetUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	pipe := s.Redis.Pipeline()
+	for _, session := range sessions {
+		if keepSessionID != "" && session.ID == keepSessionID {
+			continue
+		}
+		pipe.Del(ctx, "session:"+userID+":"+session.ID)
+		if session.Token != "" {
+			pipe.Del(ctx, "refresh:"+session.Token)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("ошибка удаления сессий: %w", err)
+	}
+	return nil
+}
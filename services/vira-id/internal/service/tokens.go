@@ -7,16 +7,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 	"vira-id/internal/events"
 	"vira-id/internal/types"
 
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	jwt "github.com/skrolikov/vira-jwt"
 )
 
 // generateTokens создает пару JWT-токенов — access и refresh для указанного userID.
-func (s *AuthService) generateTokens(userID string) (*types.TokenPair, error) {
-	access, err := jwt.GenerateAccessToken(userID, s.Cfg.JwtSecret, s.Cfg.JwtTTL)
+// В access токен дополнительно кладутся роль и ID сессии, чтобы gateway
+// мог передать их в upstream без обращения к vira-id.
+func (s *AuthService) generateTokens(userID, role, sessionID string) (*types.TokenPair, error) {
+	access, err := s.generateAccessToken(userID, role, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка генерации access токена: %w", err)
 	}
@@ -32,6 +37,20 @@ func (s *AuthService) generateTokens(userID string) (*types.TokenPair, error) {
 	}, nil
 }
 
+// generateAccessToken создает access токен в формате vira-jwt
+// (user_id, type, exp) с дополнительными claims role и sid.
+func (s *AuthService) generateAccessToken(userID, role, sessionID string) (string, error) {
+	claims := gojwt.MapClaims{
+		"user_id": userID,
+		"type":    "access",
+		"role":    role,
+		"sid":     sessionID,
+		"exp":     time.Now().Add(s.Cfg.JwtTTL).Unix(),
+	}
+	token := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.Cfg.JwtSecret))
+}
+
 // RefreshToken обновляет пару токенов — access и refresh,
 // при этом удаляет старую сессию (по старому refresh токену),
 // создает новую сессию и сохраняет её в Redis,
@@ -72,8 +91,15 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken, ip, userAg
 		return nil, errors.New("некорректный user_id в токене")
 	}
 
-	// Генерируем новую пару токенов
-	tokens, err := s.generateTokens(userID)
+	// Роль берём из БД: она могла измениться с момента прошлого входа
+	user, err := s.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения пользователя: %w", err)
+	}
+
+	// Генерируем новую пару токенов для новой сессии
+	sessionID := uuid.NewString()
+	tokens, err := s.generateTokens(userID, user.Role, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -88,26 +114,55 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken, ip, userAg
 	}
 
 	// Сохраняем новую сессию с новым refresh токеном
-	_, err = s.saveSession(ctx, userID, tokens.Refresh, ip, userAgent)
-	if err != nil {
+	if err := s.saveSession(ctx, sessionID, userID, tokens.Refresh, ip, userAgent); err != nil {
 		return nil, err
 	}
 
 	// Асинхронно отправляем событие об обновлении токена в Kafka
-	go events.EmitRefreshEvent(ctx, s.Producer, s.Logger, userID, oldSession)
+	go events.EmitRefreshEvent(context.WithoutCancel(ctx), s.Producer, s.Logger, userID, oldSession)
 
 	return tokens, nil
 }
 
-// generateConfirmToken создает случайный токен подтверждения (32 hex символа).
-func generateConfirmToken() string {
-	b := make([]byte, 16) // 16 байт = 32 hex символа
-	_, err := rand.Read(b)
-	if err != nil {
-		// При ошибке генерации безопаснее вернуть пустую строку и логировать,
-		// чем фиксированный токен, чтобы избежать конфликтов
-		// Но здесь для простоты возвращаем дефолтное значение
-		return "default-confirm-token"
+// IntrospectToken проверяет access токен: подпись, срок, тип и то, что
+// его сессия ещё открыта. Неверный токен — не ошибка: Active остаётся false.
+func (s *AuthService) IntrospectToken(ctx context.Context, token string) (*types.TokenInfo, error) {
+	claims, err := jwt.ParseToken(token, s.Cfg.JwtSecret)
+	if err != nil || !jwt.IsTokenType(claims, "access") {
+		return &types.TokenInfo{}, nil
+	}
+
+	info := &types.TokenInfo{}
+	info.UserID, _ = claims["user_id"].(string)
+	info.Role, _ = claims["role"].(string)
+	info.SessionID, _ = claims["sid"].(string)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		info.ExpiresAt = exp.Time
+	}
+	if info.UserID == "" {
+		return &types.TokenInfo{}, nil
+	}
+
+	// Токены без sid выданы до появления сессий в access токене
+	if info.SessionID != "" {
+		n, err := s.Redis.Exists(ctx, "session:"+info.UserID+":"+info.SessionID).Result()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка Redis: %w", err)
+		}
+		if n == 0 {
+			return &types.TokenInfo{}, nil
+		}
+	}
+
+	info.Active = true
+	return info, nil
+}
+
+// generateLinkToken создаёт случайный одноразовый токен для ссылки из письма (64 hex символа).
+func generateLinkToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ошибка генерации токена подтверждения: %w", err)
 	}
-	return hex.EncodeToString(b)
+	return hex.EncodeToString(b), nil
 }
@@ -1,25 +1,18 @@
 package service
 
 import (
-	"errors"
-	"regexp"
-	"strings"
 	"vira-id/internal/types"
 )
 
+// validateRegistration проверяет данные регистрации по политике паролей.
+// Email обязателен: без подтверждения по почте вход невозможен.
+// Ошибки возвращаются как validators.Errors — по полям запроса.
 func (s *AuthService) validateRegistration(req types.RegisterRequest) error {
-	if strings.TrimSpace(req.Username) == "" {
-		return errors.New("имя пользователя обязательно")
-	}
-	if len(req.Password) < 6 {
-		return errors.New("пароль должен быть не менее 6 символов")
-	}
-	if req.Email != "" {
-		// простой regex для проверки email (можно сделать лучше)
-		emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
-		if !emailRegex.MatchString(req.Email) {
-			return errors.New("некорректный email")
-		}
-	}
-	return nil
+	return s.Policy.CheckRegistration(req.Username, req.Password, req.Email)
+}
+
+// validatePassword — требования к новому паролю (сброс и смена).
+// username нужен для проверки, что пароль не содержит имя пользователя.
+func (s *AuthService) validatePassword(field, password, username string) error {
+	return s.Policy.CheckPassword(field, password, username).Err()
 }
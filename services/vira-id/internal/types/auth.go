@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // AuthRequest содержит данные для входа
 // swagger:model AuthRequest
 type AuthRequest struct {
@@ -36,6 +38,15 @@ type UserInfo struct {
 	Role     string `json:"role" example:"user"`                               // Роль пользователя
 }
 
+// TokenInfo — результат проверки access токена
+type TokenInfo struct {
+	Active    bool      // Токен действителен и его сессия открыта
+	UserID    string    // ID пользователя
+	Role      string    // Роль пользователя
+	SessionID string    // ID сессии
+	ExpiresAt time.Time // Срок действия токена
+}
+
 // RefreshRequest содержит refresh токен для обновления access токена
 // swagger:model RefreshRequest
 type RefreshRequest struct {
@@ -45,9 +56,25 @@ type RefreshRequest struct {
 // RegisterRequest содержит данные для регистрации нового пользователя
 // swagger:model RegisterRequest
 type RegisterRequest struct {
-	Username string `json:"username" example:"john_doe"`                // Имя пользователя
-	Password string `json:"password" example:"secret123"`               // Пароль
-	Email    string `json:"email,omitempty" example:"john@example.com"` // Email (опционально)
+	Username string `json:"username" example:"john_doe"`      // Имя пользователя
+	Password string `json:"password" example:"Secret123"`     // Пароль: требования задаёт PASSWORD_*
+	Email    string `json:"email" example:"john@example.com"` // Email: на него приходит ссылка подтверждения
+	Language string `json:"language,omitempty" example:"ru"`  // Язык писем: ru или en (по умолчанию из Accept-Language)
+}
+
+// FieldError описывает ошибку проверки одного поля запроса
+// swagger:model FieldError
+type FieldError struct {
+	Field   string `json:"field" example:"password"`                                      // Поле запроса
+	Code    string `json:"code" example:"too_short"`                                      // Машинный код ошибки
+	Message string `json:"message" example:"пароль должен содержать не менее 8 символов"` // Текст для пользователя
+}
+
+// ValidationErrorResponse содержит ошибки проверки по полям
+// swagger:model ValidationErrorResponse
+type ValidationErrorResponse struct {
+	Error  string       `json:"error" example:"пароль должен содержать не менее 8 символов"` // Все ошибки одной строкой
+	Fields []FieldError `json:"fields"`                                                      // Ошибки по полям
 }
 
 // ErrorResponse стандартная структура ошибки
@@ -56,8 +83,44 @@ type ErrorResponse struct {
 	Error string `json:"error" example:"Неверный токен"` // Текст ошибки
 }
 
+// ResendConfirmRequest содержит email для повторного письма подтверждения
+// swagger:model ResendConfirmRequest
+type ResendConfirmRequest struct {
+	Email    string `json:"email" example:"john@example.com"` // Email, указанный при регистрации
+	Language string `json:"language,omitempty" example:"ru"`  // Язык письма: ru или en (по умолчанию из Accept-Language)
+}
+
+// ForgotPasswordRequest содержит имя пользователя или email для сброса пароля
+// swagger:model ForgotPasswordRequest
+type ForgotPasswordRequest struct {
+	Login    string `json:"login" example:"john@example.com"` // Имя пользователя или email
+	Language string `json:"language,omitempty" example:"ru"`  // Язык письма: ru или en (по умолчанию из Accept-Language)
+}
+
+// ResetPasswordRequest содержит токен из письма и новый пароль
+// swagger:model ResetPasswordRequest
+type ResetPasswordRequest struct {
+	Token    string `json:"token" example:"9f86d081884c7d659a2feaa0c55ad015..."` // Токен из ссылки в письме
+	Password string `json:"password" example:"NewSecret123"`                     // Новый пароль
+}
+
+// ChangePasswordRequest содержит текущий и новый пароли
+// swagger:model ChangePasswordRequest
+type ChangePasswordRequest struct {
+	OldPassword        string `json:"old_password" example:"secret123"`    // Текущий пароль
+	NewPassword        string `json:"new_password" example:"NewSecret123"` // Новый пароль
+	KeepCurrentSession bool   `json:"keep_current_session" example:"true"` // Не закрывать сессию, из которой пришёл запрос
+}
+
 // LogoutRequest содержит refresh токен сессии для выхода
 // swagger:model LogoutRequest
 type LogoutRequest struct {
 	RefreshToken string `json:"refresh_token" example:"eyJhbGciOiJIUzI1NiIsIn..."` // Refresh токен
 }
+
+// UnlockLoginRequest — запрос администратора на снятие блокировки входа
+// swagger:model UnlockLoginRequest
+type UnlockLoginRequest struct {
+	Username string `json:"username,omitempty" example:"john_doe"` // Имя пользователя (как при входе)
+	IP       string `json:"ip,omitempty" example:"203.0.113.7"`    // IP-адрес
+}
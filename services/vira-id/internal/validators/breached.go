@@ -0,0 +1,77 @@
+package validators
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// prefixLen — длина префикса SHA-1 (в hex символах), по которому хэши
+// разложены на корзины, как в k-anonymity API Have I Been Pwned.
+const prefixLen = 5
+
+// Breached — локальный список утёкших паролей. Хранятся только SHA-1 хэши:
+// корзина по первым 5 hex символам и отсортированные остатки в ней.
+type Breached struct {
+	buckets map[string][]string
+	count   int
+}
+
+// LoadBreached загружает список из файла: по строке на пароль, SHA-1 в hex
+// и, как в выгрузках Have I Been Pwned, необязательный «:число утечек».
+// Пустые строки и строки с # пропускаются.
+func LoadBreached(path string) (*Breached, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := &Breached{buckets: make(map[string][]string)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: ожидается SHA-1 в hex", path, line)
+		}
+		prefix, suffix := hash[:prefixLen], hash[prefixLen:]
+		b.buckets[prefix] = append(b.buckets[prefix], suffix)
+		b.count++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, bucket := range b.buckets {
+		slices.Sort(bucket)
+	}
+	return b, nil
+}
+
+// Contains сообщает, есть ли пароль в списке. Для nil списка — false.
+func (b *Breached) Contains(password string) bool {
+	if b == nil {
+		return false
+	}
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	_, found := slices.BinarySearch(b.buckets[hash[:prefixLen]], hash[prefixLen:])
+	return found
+}
+
+// Len — число хэшей в списке.
+func (b *Breached) Len() int {
+	if b == nil {
+		return 0
+	}
+	return b.count
+}
@@ -0,0 +1,32 @@
+package validators
+
+import (
+	"strings"
+
+	"vira-id/internal/types"
+)
+
+// Errors — ошибки проверки по полям запроса. Пустой список — ошибок нет,
+// поэтому возвращать его нужно через Err.
+type Errors []types.FieldError
+
+// Add добавляет ошибку поля field с машинным кодом code.
+func (e *Errors) Add(field, code, message string) {
+	*e = append(*e, types.FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err возвращает ошибки как error или nil, если их нет.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
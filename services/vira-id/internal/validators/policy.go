@@ -0,0 +1,164 @@
+package validators
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcryptMaxBytes — bcrypt не принимает пароли длиннее 72 байт.
+const bcryptMaxBytes = 72
+
+// Policy — требования к новым паролям (регистрация, сброс и смена пароля).
+type Policy struct {
+	MinLength      int       // Минимальная длина в символах
+	MaxLength      int       // Максимальная длина в символах (не больше 72 байт в любом случае)
+	RequireUpper   bool      // Нужна заглавная буква
+	RequireLower   bool      // Нужна строчная буква
+	RequireDigit   bool      // Нужна цифра
+	RequireSpecial bool      // Нужен символ, кроме букв и цифр
+	RejectUsername bool      // Пароль не должен содержать имя пользователя
+	Breached       *Breached // Список утёкших паролей (nil — без проверки)
+}
+
+// DefaultPolicy — требования по умолчанию.
+var DefaultPolicy = Policy{
+	MinLength:      8,
+	MaxLength:      64,
+	RequireUpper:   true,
+	RequireLower:   true,
+	RequireDigit:   true,
+	RejectUsername: true,
+}
+
+// PolicyFromEnv читает требования к паролю поверх DefaultPolicy. Переменные
+// загружает config.Load (в том числе из .env), поэтому вызывать после него:
+// PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH, PASSWORD_REQUIRE_UPPER,
+// PASSWORD_REQUIRE_LOWER, PASSWORD_REQUIRE_DIGIT, PASSWORD_REQUIRE_SPECIAL,
+// PASSWORD_REJECT_USERNAME и PASSWORD_BREACHED_FILE (файл утёкших паролей,
+// пусто — без проверки).
+func PolicyFromEnv() (Policy, error) {
+	p := DefaultPolicy
+
+	ints := []struct {
+		env string
+		dst *int
+	}{
+		{"PASSWORD_MIN_LENGTH", &p.MinLength},
+		{"PASSWORD_MAX_LENGTH", &p.MaxLength},
+	}
+	for _, i := range ints {
+		v := os.Getenv(i.env)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return Policy{}, fmt.Errorf("%s: некорректное значение %q", i.env, v)
+		}
+		*i.dst = n
+	}
+
+	bools := []struct {
+		env string
+		dst *bool
+	}{
+		{"PASSWORD_REQUIRE_UPPER", &p.RequireUpper},
+		{"PASSWORD_REQUIRE_LOWER", &p.RequireLower},
+		{"PASSWORD_REQUIRE_DIGIT", &p.RequireDigit},
+		{"PASSWORD_REQUIRE_SPECIAL", &p.RequireSpecial},
+		{"PASSWORD_REJECT_USERNAME", &p.RejectUsername},
+	}
+	for _, b := range bools {
+		v := os.Getenv(b.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return Policy{}, fmt.Errorf("%s: некорректное значение %q", b.env, v)
+		}
+		*b.dst = parsed
+	}
+
+	if p.MinLength > p.MaxLength {
+		return Policy{}, fmt.Errorf("PASSWORD_MIN_LENGTH (%d) больше PASSWORD_MAX_LENGTH (%d)", p.MinLength, p.MaxLength)
+	}
+
+	if path := os.Getenv("PASSWORD_BREACHED_FILE"); path != "" {
+		breached, err := LoadBreached(path)
+		if err != nil {
+			return Policy{}, fmt.Errorf("PASSWORD_BREACHED_FILE: %w", err)
+		}
+		p.Breached = breached
+	}
+	return p, nil
+}
+
+// CheckPassword проверяет пароль поля field. username — имя владельца
+// для RejectUsername (пусто — не проверять). Возвращает все нарушения сразу,
+// чтобы клиент мог показать их вместе.
+func (p Policy) CheckPassword(field, password, username string) Errors {
+	var errs Errors
+	if password == "" {
+		errs.Add(field, "required", "пароль обязателен")
+		return errs
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		errs.Add(field, "too_short", fmt.Sprintf("пароль должен содержать не менее %d символов", p.MinLength))
+	}
+	if length > p.MaxLength {
+		errs.Add(field, "too_long", fmt.Sprintf("пароль не должен превышать %d символов", p.MaxLength))
+	} else if len(password) > bcryptMaxBytes {
+		errs.Add(field, "too_long", fmt.Sprintf("пароль не должен превышать %d байт", bcryptMaxBytes))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, ch := range password {
+		switch {
+		case unicode.IsUpper(ch):
+			hasUpper = true
+		case unicode.IsLower(ch):
+			hasLower = true
+		case unicode.IsDigit(ch):
+			hasDigit = true
+		case !unicode.IsLetter(ch):
+			hasSpecial = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		errs.Add(field, "missing_upper", "пароль должен содержать хотя бы одну заглавную букву")
+	}
+	if p.RequireLower && !hasLower {
+		errs.Add(field, "missing_lower", "пароль должен содержать хотя бы одну строчную букву")
+	}
+	if p.RequireDigit && !hasDigit {
+		errs.Add(field, "missing_digit", "пароль должен содержать хотя бы одну цифру")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		errs.Add(field, "missing_special", "пароль должен содержать хотя бы один специальный символ (!@#$%^&* и т.д.)")
+	}
+
+	if p.RejectUsername && len(username) >= 3 && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		errs.Add(field, "contains_username", "пароль не должен содержать имя пользователя")
+	}
+
+	if p.Breached.Contains(password) {
+		errs.Add(field, "breached", "этот пароль есть в базах утёкших паролей, выберите другой")
+	}
+	return errs
+}
+
+// CheckRegistration проверяет все поля регистрации.
+func (p Policy) CheckRegistration(username, password, email string) error {
+	var errs Errors
+	errs = append(errs, CheckUsername("username", username)...)
+	errs = append(errs, p.CheckPassword("password", password, username)...)
+	errs = append(errs, CheckEmail("email", email)...)
+	return errs.Err()
+}
@@ -2,20 +2,27 @@ package main
 
 import (
 	"context"
-	"net/http"
+	"flag"
+	"net"
+	"os"
 	"time"
 
-	"vira-id/internal/handlers"
+	"vira-id/docs"
+	"vira-id/internal/grpcapi"
+	"vira-id/internal/mailer"
 	"vira-id/internal/service"
+	"vira-id/internal/validators"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	config "github.com/skrolikov/vira-config"
 	db "github.com/skrolikov/vira-db"
 	kafka "github.com/skrolikov/vira-kafka"
 	log "github.com/skrolikov/vira-logger"
-	middleware "github.com/skrolikov/vira-middleware"
 	redisdb "github.com/skrolikov/vira-redisdb"
+	"github.com/skrolikov/vira/pkg/apidocs"
+	"github.com/skrolikov/vira/pkg/identity"
+	"github.com/skrolikov/vira/pkg/kafkatrace"
+	"github.com/skrolikov/vira/pkg/server"
+	"github.com/skrolikov/vira/pkg/tracing"
 
 	kafkago "github.com/segmentio/kafka-go"
 )
@@ -32,10 +39,17 @@ import (
 // @license.name MIT
 // @license.url https://opensource.org/licenses/MIT
 
-// @host vira-id:8000
+// @host vira-id:8080
 // @BasePath /
+
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+// @description Access токен: "Bearer <token>"
 func main() {
-	cfg := config.Load()
+	checkOpenAPI := flag.Bool("check-openapi", false, "сверить OpenAPI документ с маршрутами и выйти")
+	flag.Parse()
+
 	ctx := context.Background()
 
 	baseLogger := log.New(log.Config{
@@ -50,8 +64,36 @@ func main() {
 		Compress:   true,
 	})
 
+	// Проверка документации не требует окружения и подключений
+	if *checkOpenAPI {
+		os.Exit(checkDocs(baseLogger))
+	}
+
+	cfg := config.Load()
 	baseLogger.Info("🚀 Запуск Vira-ID")
 
+	// Трассировка OpenTelemetry
+	shutdownTracing, err := tracing.Init(ctx, "vira-id", baseLogger.WithFields(map[string]any{"component": "tracing"}))
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка инициализации трассировки: %v", err)
+	}
+
+	// HTTP сервер: таймауты, лимит тела, плавная остановка
+	srvCfg, err := server.FromEnv(":" + cfg.Port)
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек сервера: %v", err)
+	}
+	srv := server.New(srvCfg, baseLogger)
+
+	// Подписанная gateway личность: без неё запросы мимо gateway не проходят
+	identityCfg, err := identity.FromEnv()
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек личности: %v", err)
+	}
+	if identityCfg.Secret == "" {
+		baseLogger.Warn("⚠️ IDENTITY_SECRET не задан: пользователь определяется только по JWT")
+	}
+
 	redisConn, err := redisdb.New(ctx, redisdb.Config{
 		Addr:     cfg.RedisAddr,
 		Password: "",
@@ -60,7 +102,6 @@ func main() {
 	if err != nil {
 		baseLogger.Fatal("❌ Ошибка подключения к Redis: %v", err)
 	}
-	defer redisConn.Close()
 	rdb := redisConn.Client()
 
 	if _, err := db.Init(ctx, cfg); err != nil {
@@ -76,8 +117,8 @@ func main() {
 
 	kafkaLogger := baseLogger.WithFields(map[string]any{"component": "kafka"})
 
-	// ✅ Конфигурация Kafka Producer
-	producer := kafka.NewProducer(kafka.ProducerConfig{
+	// ✅ Конфигурация Kafka Producer (контекст трассировки идёт в заголовках сообщений)
+	producer := kafkatrace.NewProducer("vira-id", kafka.ProducerConfig{
 		Brokers:      []string{cfg.KafkaAddr},
 		Topic:        "vira-events",
 		BatchTimeout: 100 * time.Millisecond,
@@ -88,47 +129,117 @@ func main() {
 		MaxAttempts:  5,
 
 		Logger: kafkaLogger,
-		Tracer: nil, // или otel.Tracer("vira-id"), если уже подключён
 	})
-	defer producer.Close()
 
-	authService := service.NewAuthService(cfg, userRepo, rdb, producer, baseLogger)
+	// Почта: SMTP или, для разработки, письма файлами в каталоге
+	mailCfg, err := mailer.FromEnv()
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек почты: %v", err)
+	}
+	mail, err := mailer.New(mailCfg, baseLogger.WithFields(map[string]any{"component": "mailer"}))
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка инициализации почты: %v", err)
+	}
+	if mailCfg.Transport == "maildir" {
+		baseLogger.Warn("⚠️ Письма не отправляются, а сохраняются в %s (MAIL_TRANSPORT=maildir)", mailCfg.Maildir)
+	}
 
-	r := chi.NewRouter()
+	confirmCfg, err := service.ConfirmFromEnv()
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек подтверждения email: %v", err)
+	}
+	resetCfg, err := service.PasswordResetFromEnv()
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек сброса пароля: %v", err)
+	}
 
-	r.Use(middleware.RequestID())
-	r.Use(middleware.ContextLogger(baseLogger))
+	// Требования к паролям и список утёкших паролей
+	policy, err := validators.PolicyFromEnv()
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек пароля: %v", err)
+	}
+	if policy.Breached != nil {
+		baseLogger.Info("🔐 Загружено %d хэшей утёкших паролей", policy.Breached.Len())
+	} else {
+		baseLogger.Warn("⚠️ Пароли не проверяются по списку утёкших (PASSWORD_BREACHED_FILE не задан)")
+	}
 
-	r.Post("/login", handlers.LoginHandler(authService))
-	r.Post("/register", handlers.RegisterHandler(authService))
-	r.Post("/refresh", handlers.RefreshHandler(authService))
+	lockoutCfg, err := service.LockoutFromEnv()
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настроек защиты входа: %v", err)
+	}
 
-	// Новый маршрут подтверждения
-	r.Get("/confirm", handlers.ConfirmUserHandler(authService))
+	authService := service.NewAuthService(cfg, userRepo, rdb, producer, mail, confirmCfg, resetCfg, policy, lockoutCfg, baseLogger)
 
-	r.Get("/metrics", promhttp.Handler().ServeHTTP)
+	// gRPC API и его HTTP/JSON шлюз работают через тот же AuthService, что и REST
+	rpc := grpcapi.New(authService, identityCfg, baseLogger.WithFields(map[string]any{"component": "grpc"}))
+	rpcGateway, err := grpcapi.Gateway(ctx, rpc)
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка настройки HTTP шлюза gRPC: %v", err)
+	}
 
-	r.Get("/redis-test", func(w http.ResponseWriter, r *http.Request) {
-		logger := baseLogger.WithContext(r.Context())
-		if err := rdb.Set(r.Context(), "test_key", "123", 0).Err(); err != nil {
-			logger.WithFields(map[string]any{"err": err}).Error("Ошибка при записи в Redis")
-			http.Error(w, "ошибка Redis", http.StatusInternalServerError)
-			return
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
+	lis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		baseLogger.Fatal("❌ Ошибка открытия порта gRPC: %v", err)
+	}
+	grpcServer := grpcapi.NewGRPCServer(rpc)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			baseLogger.Error("❌ gRPC сервер остановлен с ошибкой: %v", err)
 		}
-		val, _ := rdb.Get(r.Context(), "test_key").Result()
-		w.Write([]byte("Redis работает, значение: " + val))
+	}()
+	baseLogger.Info("✅ gRPC API Vira-ID запущен на порту %s", grpcPort)
+
+	r := newRouter(routerDeps{
+		cfg:      cfg,
+		logger:   baseLogger,
+		identity: identityCfg,
+		auth:     authService,
+		rpc:      rpcGateway,
+		users:    userRepo,
+		rdb:      rdb,
+		ready:    srv.Ready,
 	})
 
-	r.Group(func(r chi.Router) {
-		r.Use(middleware.Auth(cfg, baseLogger))
-		r.Get("/me", handlers.MeHandler(userRepo))
-		r.Post("/logout", handlers.LogoutHandler(cfg, rdb))
-		r.Get("/sessions", handlers.SessionsHandler(cfg, rdb))
-		r.Delete("/sessions/{id}", handlers.DeleteSessionHandler(cfg, rdb))
+	// Порядок закрытия: gRPC дожидается текущих вызовов, продюсер дописывает
+	// события, затем хранилища
+	srv.OnShutdown("gRPC сервер", func() error {
+		grpcServer.GracefulStop()
+		return nil
+	})
+	srv.OnShutdown("Kafka продюсер", producer.Close)
+	srv.OnShutdown("Redis", redisConn.Close)
+	srv.OnShutdown("БД", db.Close)
+	srv.OnShutdown("трассировка", func() error {
+		return shutdownTracing(context.Background())
 	})
 
 	baseLogger.Info("✅ Vira-ID запущен на порту %s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
+	if err := srv.Run(r); err != nil {
 		baseLogger.Fatal("❌ Ошибка запуска сервера: %v", err)
 	}
 }
+
+// checkDocs сверяет OpenAPI документ с маршрутами роутера. Возвращает код выхода.
+func checkDocs(logger *log.Logger) int {
+	r := newRouter(routerDeps{cfg: &config.Config{}, logger: logger})
+	// /v1/* — HTTP/JSON шлюз gRPC API, он описан в identity.proto
+	drift, err := apidocs.Check(r, docs.SwaggerInfo.ReadDoc(), "/v1/*")
+	if err != nil {
+		logger.Error("❌ %v", err)
+		return 1
+	}
+	for _, d := range drift {
+		logger.Error("❌ OpenAPI: %s", d)
+	}
+	if len(drift) > 0 {
+		logger.Error("❌ OpenAPI документ расходится с маршрутами, обновите аннотации и выполните swag init")
+		return 1
+	}
+	logger.Info("✅ OpenAPI документ совпадает с маршрутами")
+	return 0
+}
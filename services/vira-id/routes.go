@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+
+	"vira-id/docs"
+	"vira-id/internal/handlers"
+	"vira-id/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	config "github.com/skrolikov/vira-config"
+	db "github.com/skrolikov/vira-db"
+	log "github.com/skrolikov/vira-logger"
+	middleware "github.com/skrolikov/vira-middleware"
+	"github.com/skrolikov/vira/pkg/apidocs"
+	"github.com/skrolikov/vira/pkg/identity"
+	"github.com/skrolikov/vira/pkg/tracing"
+)
+
+// routerDeps — зависимости обработчиков. В режиме -check-openapi роутер
+// собирается без подключений к БД, Redis и Kafka, и поля остаются пустыми.
+type routerDeps struct {
+	cfg      *config.Config
+	logger   *log.Logger
+	identity identity.Config
+	auth     *service.AuthService
+	rpc      http.Handler // HTTP/JSON шлюз gRPC API (/v1/...)
+	users    db.UserRepository
+	rdb      *redis.Client
+	ready    func() bool
+}
+
+func newRouter(d routerDeps) *chi.Mux {
+	r := chi.NewRouter()
+
+	r.Use(tracing.Middleware("vira-id"))
+	r.Use(middleware.RequestID())
+	r.Use(middleware.ContextLogger(d.logger))
+
+	// Публичные маршруты: пользователя нет, но IP клиента, по которому
+	// ограничиваются попытки входа, берётся из подписи gateway
+	public := r.With(identity.Optional(d.identity, d.logger))
+	public.Post("/login", handlers.LoginHandler(d.auth))
+	public.Post("/register", handlers.RegisterHandler(d.auth))
+	public.Post("/refresh", handlers.RefreshHandler(d.auth))
+
+	// gRPC API по HTTP/JSON: пути описаны в api/viraid/v1/identity.proto
+	if d.rpc != nil {
+		r.Handle("/v1/*", d.rpc)
+	}
+
+	// Подтверждение email по ссылке из письма
+	r.Get("/confirm", handlers.ConfirmUserHandler(d.auth))
+	public.Post("/confirm/resend", handlers.ResendConfirmHandler(d.auth))
+
+	// Восстановление доступа по ссылке из письма
+	public.Post("/password/forgot", handlers.ForgotPasswordHandler(d.auth))
+	public.Post("/password/reset", handlers.ResetPasswordHandler(d.auth))
+
+	r.Get("/metrics", promhttp.Handler().ServeHTTP)
+
+	// OpenAPI документ для каталога gateway
+	r.Get(apidocs.Path, apidocs.Handler(docs.SwaggerInfo.ReadDoc()))
+
+	// Проверка здоровья для gateway: БД и Redis должны отвечать,
+	// а во время остановки — 503, чтобы gateway снял экземпляр
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if !d.ready() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		if err := db.HealthCheck(r.Context()); err != nil {
+			http.Error(w, "db: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err := d.rdb.Ping(r.Context()).Err(); err != nil {
+			http.Error(w, "redis: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	r.Get("/redis-test", func(w http.ResponseWriter, r *http.Request) {
+		logger := d.logger.WithContext(r.Context())
+		if err := d.rdb.Set(r.Context(), "test_key", "123", 0).Err(); err != nil {
+			logger.WithFields(map[string]any{"err": err}).Error("Ошибка при записи в Redis")
+			http.Error(w, "ошибка Redis", http.StatusInternalServerError)
+			return
+		}
+		val, _ := d.rdb.Get(r.Context(), "test_key").Result()
+		w.Write([]byte("Redis работает, значение: " + val))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(identity.Auth(d.identity, d.cfg, d.logger))
+		r.Get("/me", handlers.MeHandler(d.users))
+		r.Post("/logout", handlers.LogoutHandler(d.auth))
+		r.Get("/sessions", handlers.SessionsHandler(d.cfg, d.rdb))
+		r.Delete("/sessions/{id}", handlers.DeleteSessionHandler(d.auth))
+		r.Post("/password/change", handlers.ChangePasswordHandler(d.auth))
+
+		// Администрирование: только роль admin
+		r.With(handlers.AdminOnly(d.auth)).Post("/admin/login/unlock", handlers.UnlockLoginHandler(d.auth))
+	})
+
+	return r
+}